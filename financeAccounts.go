@@ -0,0 +1,279 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+type AccountKind string
+
+const (
+	AccountChecking   AccountKind = "checking"
+	AccountSavings    AccountKind = "savings"
+	AccountCreditCard AccountKind = "credit_card"
+	AccountBroker     AccountKind = "broker"
+	AccountCash       AccountKind = "cash"
+)
+
+func parseAccountKind(value string) (AccountKind, error) {
+	switch kind := AccountKind(strings.ToLower(strings.ReplaceAll(strings.TrimSpace(value), "-", "_"))); kind {
+	case AccountChecking, AccountSavings, AccountCreditCard, AccountBroker, AccountCash:
+		return kind, nil
+	}
+	return "", fmt.Errorf("invalid account kind %q, expected checking, savings, credit_card, broker or cash", value)
+}
+
+// Account is a named place money is kept. Entries attached to it move its
+// balance: income adds, expenses subtract. A credit card's balance is
+// negative while money is owed on it.
+type Account struct {
+	Name           string      `json:"name"`
+	Kind           AccountKind `json:"kind"`
+	OpeningBalance Money       `json:"opening_balance"`
+	Opened         time.Time   `json:"opened"`
+}
+
+func (a Account) currency() string {
+	return a.OpeningBalance.Currency
+}
+
+// ledgerAccount is the double-entry account that mirrors a.
+func (a Account) ledgerAccount() string {
+	if a.Kind == AccountCreditCard {
+		return "Liabilities:" + accountSegment(a.Name, "Card")
+	}
+	return "Assets:" + accountSegment(a.Name, "Account")
+}
+
+// AddAccount opens a new account. Its currency is that of the opening
+// balance, which may be zero.
+func (fm *FinanceManager) AddAccount(account Account) error {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	account.Name = strings.TrimSpace(account.Name)
+	if account.Name == "" {
+		return invalid(fmt.Errorf("account name is required"))
+	}
+	if fm.accountIndex(account.Name) >= 0 {
+		return invalid(fmt.Errorf("account %q already exists", account.Name))
+	}
+	if _, err := parseAccountKind(string(account.Kind)); err != nil {
+		return invalid(err)
+	}
+	if err := validateCurrency(account.currency()); err != nil {
+		return invalid(err)
+	}
+	fm.accounts = append(fm.accounts, account)
+	fm.book(openingTransactionID(account.Name))
+	return fm.commit(func() { fm.accounts = fm.accounts[:len(fm.accounts)-1] })
+}
+
+func (fm *FinanceManager) GetAccount(name string) (Account, error) {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+	i := fm.accountIndex(name)
+	if i < 0 {
+		return Account{}, &NotFoundError{Kind: "account", ID: name}
+	}
+	return fm.accounts[i], nil
+}
+
+func (fm *FinanceManager) ListAccounts() []Account {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+	return append([]Account(nil), fm.accounts...)
+}
+
+// RemoveAccount deletes an account that no entry refers to.
+func (fm *FinanceManager) RemoveAccount(name string) error {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	i := fm.accountIndex(name)
+	if i < 0 {
+		return &NotFoundError{Kind: "account", ID: name}
+	}
+	old := fm.accounts
+	fm.accounts = append(append([]Account(nil), old[:i]...), old[i+1:]...)
+	fm.book(openingTransactionID(old[i].Name))
+	return fm.commit(func() { fm.accounts = old })
+}
+
+func (fm *FinanceManager) accountIndex(name string) int {
+	name = strings.TrimSpace(name)
+	for i, account := range fm.accounts {
+		if strings.EqualFold(account.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// checkAccounts runs before every save: each entry must name an existing
+// account, if any, and be in that account's currency.
+func (fm *FinanceManager) checkAccounts() error {
+	check := func(id, account string, amount Money) error {
+		if account == "" {
+			return nil
+		}
+		i := fm.accountIndex(account)
+		if i < 0 {
+			return fmt.Errorf("%s: unknown account %q", id, account)
+		}
+		if currency := fm.accounts[i].currency(); amount.Currency != currency {
+			return fmt.Errorf("%s: amount is in %s but account %q is in %s", id, amount.Currency, fm.accounts[i].Name, currency)
+		}
+		return nil
+	}
+	for _, income := range fm.incomes {
+		if err := check(income.ID, income.Account, income.Amount); err != nil {
+			return err
+		}
+	}
+	for _, expense := range fm.expenses {
+		if err := check(expense.ID, expense.Account, expense.Amount); err != nil {
+			return err
+		}
+	}
+	for _, investment := range fm.investments {
+		if err := check(investment.ID, investment.Account, investment.Value); err != nil {
+			return err
+		}
+	}
+	for _, t := range fm.recurring {
+		if err := check(t.ID, t.Account, t.Amount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisterLine is one movement of an account with the balance after it.
+type RegisterLine struct {
+	Date        time.Time `json:"date"`
+	ID          string    `json:"id"`
+	Description string    `json:"description"`
+	Amount      Money     `json:"amount"`
+	Balance     Money     `json:"balance"`
+}
+
+// AccountRegister lists the movements of an account in [start, end) with
+// running balances. A zero start or end leaves that side open.
+func (fm *FinanceManager) AccountRegister(name string, start, end time.Time) ([]RegisterLine, error) {
+	fm = fm.view()
+	account, err := fm.GetAccount(name)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := []RegisterLine{{Date: account.Opened, ID: "", Description: "Opening balance", Amount: account.OpeningBalance}}
+	belongs := func(entryAccount string) bool {
+		return strings.EqualFold(strings.TrimSpace(entryAccount), account.Name)
+	}
+	for _, income := range fm.incomes {
+		if belongs(income.Account) {
+			lines = append(lines, RegisterLine{Date: income.Date, ID: income.ID, Description: income.Source, Amount: income.Amount})
+		}
+	}
+	for _, expense := range fm.expenses {
+		if belongs(expense.Account) {
+			lines = append(lines, RegisterLine{Date: expense.Date, ID: expense.ID, Description: expense.importDescription(), Amount: expense.Amount.Neg()})
+		}
+	}
+	for _, investment := range fm.investments {
+		if belongs(investment.Account) {
+			amount := investment.Value.Neg()
+			if investment.kind() == InvestmentSell {
+				amount = investment.Value
+			}
+			description := fmt.Sprintf("%s %s %s", investment.kind(), investment.units(), investment.Asset)
+			lines = append(lines, RegisterLine{Date: investment.Date, ID: investment.ID, Description: description, Amount: amount})
+		}
+	}
+	// Transfers reach named accounts through their ledger account.
+	if fm.ledger != nil {
+		for _, txn := range fm.ledger.Transactions {
+			if !txn.posted() {
+				continue
+			}
+			for _, posting := range txn.Postings {
+				if strings.EqualFold(posting.Account, account.ledgerAccount()) && posting.Amount.Currency == account.currency() {
+					lines = append(lines, RegisterLine{Date: txn.Date, ID: txn.ID, Description: txn.Description, Amount: posting.Amount})
+				}
+			}
+		}
+	}
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].Date.Before(lines[j].Date) })
+
+	var register []RegisterLine
+	balance := Money{Currency: account.currency()}
+	for _, line := range lines {
+		if !end.IsZero() && !line.Date.Before(end) {
+			break
+		}
+		if balance, err = balance.Add(line.Amount); err != nil {
+			return nil, err
+		}
+		line.Balance = balance
+		if start.IsZero() || !line.Date.Before(start) {
+			register = append(register, line)
+		}
+	}
+	return register, nil
+}
+
+// AccountBalance returns the balance of an account including every
+// movement up to and including asOf.
+func (fm *FinanceManager) AccountBalance(name string, asOf time.Time) (Money, error) {
+	fm = fm.view()
+	account, err := fm.GetAccount(name)
+	if err != nil {
+		return Money{}, err
+	}
+	register, err := fm.AccountRegister(name, time.Time{}, asOf.Add(time.Nanosecond))
+	if err != nil {
+		return Money{}, err
+	}
+	if len(register) == 0 {
+		return Money{Currency: account.currency()}, nil
+	}
+	return register[len(register)-1].Balance, nil
+}
+
+// forAccount returns a snapshot of fm that only sees the entries of
+// account, for building reports.
+func (fm *FinanceManager) forAccount(account string) (*FinanceManager, error) {
+	view := fm.Snapshot()
+	a, err := view.GetAccount(account)
+	if err != nil {
+		return nil, err
+	}
+	belongs := func(entryAccount string) bool { return strings.EqualFold(strings.TrimSpace(entryAccount), a.Name) }
+
+	incomes, expenses, investments := view.incomes, view.expenses, view.investments
+	view.incomes, view.expenses, view.investments = nil, nil, nil
+	for _, income := range incomes {
+		if belongs(income.Account) {
+			view.incomes = append(view.incomes, income)
+		}
+	}
+	for _, expense := range expenses {
+		if belongs(expense.Account) {
+			view.expenses = append(view.expenses, expense)
+		}
+	}
+	for _, investment := range investments {
+		if belongs(investment.Account) {
+			view.investments = append(view.investments, investment)
+		}
+	}
+	return view, nil
+}
+
+func formatRegister(w io.Writer, register []RegisterLine) {
+	for _, line := range register {
+		fmt.Fprintf(w, "%s %-8s %-30s %14s %14s\n", line.Date.Format("2006-01-02"), line.ID, line.Description, line.Amount, line.Balance)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAccountRegisterAndBalance(t *testing.T) {
+	fm := NewFinanceManager()
+	if err := fm.AddAccount(Account{Name: "Checking", Kind: AccountChecking, OpeningBalance: usd("1000.00"), Opened: date(2024, 1, 1)}); err != nil {
+		t.Fatalf("Failed to add account: %v", err)
+	}
+	if err := fm.AddAccount(Account{Name: "Visa", Kind: AccountCreditCard, OpeningBalance: usd("0"), Opened: date(2024, 1, 1)}); err != nil {
+		t.Fatalf("Failed to add account: %v", err)
+	}
+	if err := fm.AddAccount(Account{Name: "checking", Kind: AccountSavings, OpeningBalance: usd("0")}); err == nil {
+		t.Error("Expected error for a duplicate account name")
+	}
+
+	fm.CreateIncome(Income{Date: date(2024, 1, 5), Source: "Salary", Amount: usd("3000.00"), Account: "Checking"})
+	fm.CreateExpense(Expense{Date: date(2024, 1, 10), Category: "Rent", Amount: usd("1200.00"), Account: "checking"})
+	fm.CreateExpense(Expense{Date: date(2024, 1, 12), Category: "Food", Amount: usd("80.00"), Account: "Visa"})
+	fm.CreateExpense(Expense{Date: date(2024, 1, 12), Category: "Cash", Amount: usd("20.00")})
+
+	register, err := fm.AccountRegister("Checking", date(2024, 1, 6), date(2024, 2, 1))
+	if err != nil {
+		t.Fatalf("Failed to build register: %v", err)
+	}
+	if len(register) != 1 || register[0].Amount != usd("-1200.00") || register[0].Balance != usd("2800.00") {
+		t.Errorf("Expected one rent line with balance $2800.00, got %+v", register)
+	}
+
+	for _, tc := range []struct {
+		account string
+		day     int
+		want    Money
+	}{
+		{"Checking", 4, usd("1000.00")},
+		{"Checking", 5, usd("4000.00")},
+		{"Checking", 31, usd("2800.00")},
+		{"Visa", 31, usd("-80.00")},
+	} {
+		balance, err := fm.AccountBalance(tc.account, date(2024, 1, tc.day))
+		if err != nil {
+			t.Fatalf("Failed to get balance: %v", err)
+		}
+		if balance != tc.want {
+			t.Errorf("Expected %s balance %s on Jan %d, got %s", tc.account, tc.want, tc.day, balance)
+		}
+	}
+}
+
+func TestAccountChecksRollBack(t *testing.T) {
+	fm := NewFinanceManager()
+	fm.AddAccount(Account{Name: "Euro", Kind: AccountSavings, OpeningBalance: Money{Currency: "EUR"}})
+
+	if _, err := fm.CreateExpense(Expense{Date: date(2024, 1, 1), Category: "Rent", Amount: usd("10.00"), Account: "Missing"}); err == nil {
+		t.Error("Expected error for an unknown account")
+	}
+	if _, err := fm.CreateExpense(Expense{Date: date(2024, 1, 1), Category: "Rent", Amount: usd("10.00"), Account: "Euro"}); err == nil {
+		t.Error("Expected error for a currency mismatch")
+	}
+	if len(fm.ListExpenses()) != 0 {
+		t.Errorf("Expected rejected expenses to be rolled back, got %+v", fm.ListExpenses())
+	}
+
+	income, err := fm.CreateIncome(Income{Date: date(2024, 1, 1), Source: "Interest", Amount: Money{Minor: 500, Currency: "EUR"}, Account: "Euro"})
+	if err != nil {
+		t.Fatalf("Failed to add income: %v", err)
+	}
+	if err := fm.RemoveAccount("Euro"); err == nil {
+		t.Error("Expected error removing an account that is still used")
+	}
+	fm.DeleteIncome(income.ID)
+	if err := fm.RemoveAccount("Euro"); err != nil {
+		t.Errorf("Expected unused account to be removed, got %v", err)
+	}
+}
+
+func TestReportFilteredByAccount(t *testing.T) {
+	fm := NewFinanceManager()
+	fm.AddAccount(Account{Name: "Checking", Kind: AccountChecking, OpeningBalance: usd("0")})
+	fm.AddAccount(Account{Name: "Savings", Kind: AccountSavings, OpeningBalance: usd("0")})
+	fm.CreateIncome(Income{Date: date(2024, 3, 1), Source: "Salary", Amount: usd("3000.00"), Account: "Checking"})
+	fm.CreateIncome(Income{Date: date(2024, 3, 31), Source: "Interest", Amount: usd("5.00"), Account: "Savings"})
+	fm.CreateExpense(Expense{Date: date(2024, 3, 2), Category: "Rent", Amount: usd("1200.00"), Account: "Checking"})
+
+	r := MonthRange(2024, 3)
+	r.Account = "Savings"
+	report, err := fm.BuildReport(r)
+	if err != nil {
+		t.Fatalf("Failed to build report: %v", err)
+	}
+	if report.TotalIncome != usd("5.00") || !report.TotalExpenses.IsZero() {
+		t.Errorf("Expected only the savings interest, got income %s and expenses %s", report.TotalIncome, report.TotalExpenses)
+	}
+	if !strings.Contains(formatReportText(report), "account Savings") {
+		t.Error("Expected the text report to name the account")
+	}
+
+	r.Account = "Brokerage"
+	if _, err := fm.BuildReport(r); err == nil {
+		t.Error("Expected error for an unknown account")
+	}
+}
+
+func TestTransferBetweenAccounts(t *testing.T) {
+	fm := NewFinanceManager()
+	fm.EnableLedger()
+	fm.AddAccount(Account{Name: "Checking", Kind: AccountChecking, OpeningBalance: usd("500.00"), Opened: date(2024, 1, 1)})
+	fm.AddAccount(Account{Name: "Visa", Kind: AccountCreditCard, OpeningBalance: usd("0"), Opened: date(2024, 1, 1)})
+	fm.CreateExpense(Expense{Date: date(2024, 1, 5), Category: "Food", Amount: usd("120.00"), Account: "Visa"})
+
+	if _, err := fm.Transfer(date(2024, 1, 20), "Checking", "Visa", usd("120.00"), "Card payment"); err != nil {
+		t.Fatalf("Failed to transfer: %v", err)
+	}
+	checking, _ := fm.AccountBalance("Checking", date(2024, 1, 31))
+	visa, _ := fm.AccountBalance("Visa", date(2024, 1, 31))
+	if checking != usd("380.00") || !visa.IsZero() {
+		t.Errorf("Expected Checking $380.00 and Visa $0.00, got %s and %s", checking, visa)
+	}
+
+	tb, err := fm.TrialBalance()
+	if err != nil {
+		t.Fatalf("Failed to compute trial balance: %v", err)
+	}
+	if !tb.Balanced {
+		t.Errorf("Expected balanced books, got %+v", tb.Accounts)
+	}
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AssetStats summarizes the value of one asset, or of the whole portfolio,
+// over a period. Percentages are in percent; MoneyWeightedReturn is
+// annualized and nil when it cannot be solved for.
+type AssetStats struct {
+	Asset               string   `json:"asset"`
+	Value               Money    `json:"value"`
+	Allocation          float64  `json:"allocation"`
+	Min                 Money    `json:"min"`
+	Max                 Money    `json:"max"`
+	Mean                Money    `json:"mean"`
+	TimeWeightedReturn  float64  `json:"time_weighted_return"`
+	MoneyWeightedReturn *float64 `json:"money_weighted_return,omitempty"`
+	MaxDrawdown         float64  `json:"max_drawdown"`
+}
+
+type InvestmentAnalytics struct {
+	Label     string       `json:"label"`
+	Start     time.Time    `json:"start"`
+	End       time.Time    `json:"end"`
+	Currency  string       `json:"currency"`
+	Portfolio AssetStats   `json:"portfolio"`
+	Assets    []AssetStats `json:"assets"`
+}
+
+type valuePoint struct {
+	date  time.Time
+	value Money
+}
+
+type cashFlow struct {
+	date   time.Time
+	amount float64
+}
+
+// returnSeries follows one value through a period. Its index chains the
+// returns between cash flows, so buying and selling never count as gains
+// or losses.
+type returnSeries struct {
+	points   []valuePoint
+	last     Money
+	index    float64
+	peak     float64
+	drawdown float64
+	flows    []cashFlow
+}
+
+func newReturnSeries(start time.Time, value Money) *returnSeries {
+	s := &returnSeries{index: 1, peak: 1}
+	s.record(start, value)
+	if value.IsPositive() {
+		s.flows = append(s.flows, cashFlow{start, -float64(value.Minor)})
+	}
+	return s
+}
+
+// reprice applies the return earned since the last event, measured on a
+// value taken before any cash flow of the current event.
+func (s *returnSeries) reprice(value Money) {
+	if !s.last.IsPositive() {
+		return
+	}
+	s.index *= float64(value.Minor) / float64(s.last.Minor)
+	if s.index > s.peak {
+		s.peak = s.index
+	}
+	if drawdown := (s.peak - s.index) / s.peak; drawdown > s.drawdown {
+		s.drawdown = drawdown
+	}
+}
+
+func (s *returnSeries) record(date time.Time, value Money) {
+	s.points = append(s.points, valuePoint{date, value})
+	s.last = value
+}
+
+func (s *returnSeries) stats(name string, end time.Time) AssetStats {
+	stats := AssetStats{
+		Asset:              name,
+		Value:              s.last,
+		Min:                s.points[0].value,
+		Max:                s.points[0].value,
+		TimeWeightedReturn: (s.index - 1) * 100,
+		MaxDrawdown:        s.drawdown * 100,
+	}
+
+	var weighted float64
+	for i, point := range s.points {
+		if point.value.Minor < stats.Min.Minor {
+			stats.Min = point.value
+		}
+		if point.value.Minor > stats.Max.Minor {
+			stats.Max = point.value
+		}
+		next := end
+		if i+1 < len(s.points) {
+			next = s.points[i+1].date
+		}
+		weighted += float64(point.value.Minor) * next.Sub(point.date).Seconds()
+	}
+	stats.Mean = Money{Currency: s.last.Currency}
+	if total := end.Sub(s.points[0].date).Seconds(); total > 0 {
+		stats.Mean.Minor = int64(math.Round(weighted / total))
+	}
+
+	if irr, ok := xirr(s.flows); ok {
+		percent := irr * 100
+		stats.MoneyWeightedReturn = &percent
+	}
+	return stats
+}
+
+// xirr solves for the annual rate that discounts flows to a net present
+// value of zero, by bisection.
+func xirr(flows []cashFlow) (float64, bool) {
+	var hasIn, hasOut bool
+	for _, flow := range flows {
+		hasIn = hasIn || flow.amount < 0
+		hasOut = hasOut || flow.amount > 0
+	}
+	if !hasIn || !hasOut {
+		return 0, false
+	}
+	npv := func(rate float64) float64 {
+		var total float64
+		for _, flow := range flows {
+			years := flow.date.Sub(flows[0].date).Hours() / 24 / 365
+			total += flow.amount / math.Pow(1+rate, years)
+		}
+		return total
+	}
+
+	low, high := -0.9999, 1.0
+	for npv(low)*npv(high) > 0 {
+		if high *= 2; high > 1e9 {
+			return 0, false
+		}
+	}
+	for i := 0; i < 200; i++ {
+		mid := (low + high) / 2
+		if npv(low)*npv(mid) <= 0 {
+			high = mid
+		} else {
+			low = mid
+		}
+	}
+	return (low + high) / 2, true
+}
+
+// assetTrack is the replayed state of one asset: units held and the last
+// known price, kept as an amount for priceUnits units to avoid rounding.
+type assetTrack struct {
+	name       string
+	quantity   Quantity
+	price      Money
+	priceUnits Quantity
+	series     *returnSeries
+}
+
+func (a *assetTrack) value() (Money, error) {
+	if a.quantity == 0 || a.priceUnits == 0 {
+		return Money{}, nil
+	}
+	return scaleMoney(a.price, a.quantity, a.priceUnits)
+}
+
+type analyticsEvent struct {
+	date  time.Time
+	asset string
+	trade *Investment
+	price Money
+	units Quantity
+}
+
+// InvestmentAnalytics replays trades and quotes through r and summarizes
+// each asset and the portfolio in the reporting currency.
+func (fm *FinanceManager) InvestmentAnalytics(r ReportRange) (*InvestmentAnalytics, error) {
+	fm = fm.view()
+	if _, _, err := fm.replayInvestments(r.End); err != nil {
+		return nil, err
+	}
+
+	var events []analyticsEvent
+	for i := range fm.investments {
+		trade := &fm.investments[i]
+		events = append(events, analyticsEvent{trade.Date, strings.TrimSpace(trade.Asset), trade, trade.Value, trade.units()})
+	}
+	for _, quote := range fm.prices {
+		events = append(events, analyticsEvent{date: quote.Date, asset: strings.TrimSpace(quote.Asset), price: quote.Price, units: oneUnit})
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].date.Before(events[j].date) })
+
+	convert := func(m Money, date time.Time) (Money, error) {
+		if m.Currency == "" {
+			return Money{Currency: fm.currency}, nil
+		}
+		return fm.toReporting(m, date)
+	}
+
+	index := make(map[string]*assetTrack)
+	var tracks []*assetTrack
+	// portfolioValue converts every asset at date and also returns the
+	// value of each track.
+	portfolioValue := func(date time.Time) (Money, []Money, error) {
+		total := Money{Currency: fm.currency}
+		values := make([]Money, len(tracks))
+		for i, a := range tracks {
+			value, err := a.value()
+			if err != nil {
+				return Money{}, nil, err
+			}
+			if values[i], err = convert(value, date); err != nil {
+				return Money{}, nil, err
+			}
+			if total, err = total.Add(values[i]); err != nil {
+				return Money{}, nil, err
+			}
+		}
+		return total, values, nil
+	}
+
+	var portfolio *returnSeries
+	start := func() error {
+		total, values, err := portfolioValue(r.Start)
+		if err != nil {
+			return err
+		}
+		portfolio = newReturnSeries(r.Start, total)
+		for i, a := range tracks {
+			a.series = newReturnSeries(r.Start, values[i])
+		}
+		return nil
+	}
+
+	for _, event := range events {
+		if !event.date.Before(r.End) {
+			break
+		}
+		if portfolio == nil && !event.date.Before(r.Start) {
+			if err := start(); err != nil {
+				return nil, err
+			}
+		}
+
+		key := strings.ToLower(event.asset)
+		a, ok := index[key]
+		if !ok {
+			if event.trade == nil {
+				continue // a quote for something never held
+			}
+			a = &assetTrack{name: event.asset}
+			index[key] = a
+			tracks = append(tracks, a)
+			if portfolio != nil {
+				a.series = newReturnSeries(r.Start, Money{Currency: fm.currency})
+			}
+		}
+		a.price, a.priceUnits = event.price, event.units
+
+		if portfolio != nil {
+			total, values, err := portfolioValue(event.date)
+			if err != nil {
+				return nil, err
+			}
+			portfolio.reprice(total)
+			for i, t := range tracks {
+				t.series.reprice(values[i])
+			}
+		}
+
+		var flow Money
+		if event.trade != nil {
+			if event.trade.kind() == InvestmentSell {
+				a.quantity -= event.units
+			} else {
+				a.quantity += event.units
+			}
+			var err error
+			if flow, err = convert(event.trade.Value, event.date); err != nil {
+				return nil, err
+			}
+			if event.trade.kind() == InvestmentBuy {
+				flow = flow.Neg()
+			}
+		}
+
+		if portfolio != nil {
+			total, values, err := portfolioValue(event.date)
+			if err != nil {
+				return nil, err
+			}
+			portfolio.record(event.date, total)
+			for i, t := range tracks {
+				t.series.record(event.date, values[i])
+			}
+			if !flow.IsZero() {
+				portfolio.flows = append(portfolio.flows, cashFlow{event.date, float64(flow.Minor)})
+				a.series.flows = append(a.series.flows, cashFlow{event.date, float64(flow.Minor)})
+			}
+		}
+	}
+	if portfolio == nil {
+		if err := start(); err != nil {
+			return nil, err
+		}
+	}
+
+	// The holdings are sold at the final value on paper.
+	last := r.End.Add(-time.Nanosecond)
+	total, values, err := portfolioValue(last)
+	if err != nil {
+		return nil, err
+	}
+	finish := func(s *returnSeries, value Money) {
+		s.reprice(value)
+		s.record(r.End, value)
+		if value.IsPositive() {
+			s.flows = append(s.flows, cashFlow{r.End, float64(value.Minor)})
+		}
+	}
+
+	analytics := &InvestmentAnalytics{Label: r.Label, Start: r.Start, End: r.End, Currency: fm.currency}
+	finish(portfolio, total)
+	analytics.Portfolio = portfolio.stats("Portfolio", r.End)
+	if total.IsPositive() {
+		analytics.Portfolio.Allocation = 100
+	}
+	for i, a := range tracks {
+		finish(a.series, values[i])
+		stats := a.series.stats(a.name, r.End)
+		if stats.Max.IsZero() && len(a.series.flows) == 0 {
+			continue // not held during the period
+		}
+		if total.IsPositive() {
+			stats.Allocation = float64(values[i].Minor) / float64(total.Minor) * 100
+		}
+		analytics.Assets = append(analytics.Assets, stats)
+	}
+	sort.SliceStable(analytics.Assets, func(i, j int) bool {
+		return analytics.Assets[i].Value.Minor > analytics.Assets[j].Value.Minor
+	})
+	return analytics, nil
+}
+
+func formatInvestmentAnalytics(a *InvestmentAnalytics) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Investment Analytics for %s (%s)\n", a.Label, a.Currency)
+	fmt.Fprintf(&b, "%-20s %14s %7s %14s %14s %14s %9s %9s %9s\n",
+		"Asset", "Value", "Alloc", "Min", "Max", "Mean", "TWR", "IRR", "Drawdown")
+	row := func(s AssetStats) {
+		irr := "n/a"
+		if s.MoneyWeightedReturn != nil {
+			irr = fmt.Sprintf("%.2f%%", *s.MoneyWeightedReturn)
+		}
+		fmt.Fprintf(&b, "%-20s %14s %6.1f%% %14s %14s %14s %8.2f%% %9s %8.2f%%\n", s.Asset, s.Value, s.Allocation,
+			s.Min, s.Max, s.Mean, s.TimeWeightedReturn, irr, s.MaxDrawdown)
+	}
+	for _, s := range a.Assets {
+		row(s)
+	}
+	row(a.Portfolio)
+	return b.String()
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestInvestmentAnalytics(t *testing.T) {
+	fm := NewFinanceManager()
+	fm.BuyInvestment(date(2024, 1, 1), "ACME", 10*oneUnit, usd("100.00"))
+	fm.AddPrice(date(2024, 2, 1), "ACME", usd("120.00"))
+	// Doubling the position at the new price is a cash flow, not a return.
+	fm.BuyInvestment(date(2024, 3, 1), "ACME", 10*oneUnit, usd("120.00"))
+	fm.AddPrice(date(2024, 4, 1), "ACME", usd("90.00"))
+	fm.AddPrice(date(2024, 5, 1), "ACME", usd("99.00"))
+	fm.BuyInvestment(date(2024, 5, 1), "Bonds", 1*oneUnit, usd("600.00"))
+
+	r, _ := CustomRange(date(2024, 1, 1), date(2024, 6, 1))
+	analytics, err := fm.InvestmentAnalytics(r)
+	if err != nil {
+		t.Fatalf("Failed to compute analytics: %v", err)
+	}
+	if len(analytics.Assets) != 2 {
+		t.Fatalf("Expected 2 assets, got %d", len(analytics.Assets))
+	}
+
+	acme := analytics.Assets[0]
+	if acme.Asset != "ACME" || acme.Value != usd("1980.00") {
+		t.Errorf("Expected ACME first at $1980.00, got %s at %s", acme.Asset, acme.Value)
+	}
+	if acme.Min != usd("0.00") || acme.Max != usd("2400.00") {
+		t.Errorf("Expected ACME between $0.00 and $2400.00, got %s and %s", acme.Min, acme.Max)
+	}
+	// 100 -> 120 -> 90 -> 99 is -1% whatever was added along the way.
+	if math.Abs(acme.TimeWeightedReturn-(-1)) > 1e-9 {
+		t.Errorf("Expected ACME time-weighted return of -1%%, got %.4f%%", acme.TimeWeightedReturn)
+	}
+	if math.Abs(acme.MaxDrawdown-25) > 1e-9 {
+		t.Errorf("Expected ACME drawdown of 25%%, got %.4f%%", acme.MaxDrawdown)
+	}
+	if acme.MoneyWeightedReturn == nil || *acme.MoneyWeightedReturn >= 0 {
+		t.Errorf("Expected a negative money-weighted return for ACME, got %v", acme.MoneyWeightedReturn)
+	}
+	if math.Abs(acme.Allocation+analytics.Assets[1].Allocation-100) > 1e-9 || math.Abs(acme.Allocation-76.744186) > 1e-4 {
+		t.Errorf("Expected allocations of 76.74%% and 23.26%%, got %.4f%% and %.4f%%", acme.Allocation, analytics.Assets[1].Allocation)
+	}
+	if analytics.Portfolio.Value != usd("2580.00") {
+		t.Errorf("Expected portfolio value $2580.00, got %s", analytics.Portfolio.Value)
+	}
+}
+
+func TestXIRR(t *testing.T) {
+	flows := []cashFlow{
+		{date(2023, 1, 1), -1000},
+		{date(2024, 1, 1), 1100},
+	}
+	rate, ok := xirr(flows)
+	if !ok || math.Abs(rate-0.1) > 1e-6 {
+		t.Errorf("Expected 10%% a year, got %v (%v)", rate, ok)
+	}
+	if _, ok := xirr(flows[:1]); ok {
+		t.Error("Expected no solution without money coming back")
+	}
+}
@@ -61,37 +61,116 @@
 package main
 
 import (
-	"bufio"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 type Income struct {
-	Date   time.Time
-	Source string
-	Amount float64
+	ID      string    `json:"id"`
+	Date    time.Time `json:"date"`
+	Source  string    `json:"source"`
+	Amount  Money     `json:"amount"`
+	Account string    `json:"account,omitempty"`
+	Tags    []string  `json:"tags,omitempty"`
+	Notes   string    `json:"notes,omitempty"`
+
+	RecurringID string `json:"recurring_id,omitempty"`
 }
 
 type Expense struct {
-	Date     time.Time
-	Category string
-	Amount   float64
+	ID       string    `json:"id"`
+	Date     time.Time `json:"date"`
+	Category string    `json:"category"`
+	Payee    string    `json:"payee,omitempty"`
+	Amount   Money     `json:"amount"`
+	Account  string    `json:"account,omitempty"`
+	Tags     []string  `json:"tags,omitempty"`
+	Notes    string    `json:"notes,omitempty"`
+
+	RecurringID string `json:"recurring_id,omitempty"`
 }
 
+// Investment is a buy or sell of Quantity units of Asset. Value is the total
+// paid or received.
 type Investment struct {
-	Date  time.Time
-	Asset string
-	Value float64
+	ID       string         `json:"id"`
+	Date     time.Time      `json:"date"`
+	Asset    string         `json:"asset"`
+	Type     InvestmentType `json:"type,omitempty"`
+	Quantity Quantity       `json:"quantity,omitempty"`
+	Value    Money          `json:"value"`
+	Account  string         `json:"account,omitempty"`
+	Tags     []string       `json:"tags,omitempty"`
+	Notes    string         `json:"notes,omitempty"`
+}
+
+// ID prefixes identify which kind of entry an ID belongs to.
+const (
+	incomePrefix     = "inc"
+	expensePrefix    = "exp"
+	investmentPrefix = "inv"
+)
+
+// NotFoundError is returned when no entry has the requested ID.
+type NotFoundError struct {
+	Kind string
+	ID   string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s %q not found", e.Kind, e.ID)
+}
+
+// ValidationError is returned when a change is rejected because of its
+// input, as opposed to a failure to store it.
+type ValidationError struct {
+	Err error
+}
+
+func (e *ValidationError) Error() string { return e.Err.Error() }
+func (e *ValidationError) Unwrap() error { return e.Err }
+
+func invalid(err error) error {
+	return &ValidationError{Err: err}
 }
 
+// FinanceManager is safe for concurrent use. Changes hold mu for writing;
+// reports and other computations run on a Snapshot so they see one
+// consistent state without holding the lock.
 type FinanceManager struct {
+	mu     sync.RWMutex
+	frozen bool
+
 	incomes     []Income
 	expenses    []Expense
 	investments []Investment
+	budgets     []Budget
+	recurring   []RecurringTemplate
+	prices      []AssetPrice
+	ledger      *LedgerData
+	unbooked    []string
+	accounts    []Account
+	nextID      int
+	currency    string
+	rates       RateProvider
+	costBasis   CostBasisMethod
+
+	categoryRules *CategoryRules
+	store         FinanceStore
+
+	// The audit history, and the states around each change of this session
+	// for undo and redo. committed is the state as last saved.
+	history   []AuditEvent
+	committed *FinanceData
+	undo      []*FinanceData
+	redo      []*FinanceData
+	actor     string
 }
 
 func NewFinanceManager() *FinanceManager {
@@ -99,157 +178,761 @@ func NewFinanceManager() *FinanceManager {
 		incomes:     make([]Income, 0),
 		expenses:    make([]Expense, 0),
 		investments: make([]Investment, 0),
+		budgets:     make([]Budget, 0),
+		recurring:   make([]RecurringTemplate, 0),
+		prices:      make([]AssetPrice, 0),
+		accounts:    make([]Account, 0),
+		currency:    defaultCurrency,
+		costBasis:   CostBasisFIFO,
+		store:       memoryStore{},
+		committed:   &FinanceData{},
+		actor:       currentActor(),
 	}
 }
 
-// Income methods
-func (fm *FinanceManager) AddIncome(date time.Time, source string, amount float64) error {
-	if amount <= 0 {
-		return fmt.Errorf("amount must be positive")
+// NewFinanceManagerWithStore loads the manager's state from store and saves
+// it back after every change.
+func NewFinanceManagerWithStore(store FinanceStore) (*FinanceManager, error) {
+	data, err := store.Load()
+	if err != nil {
+		return nil, fmt.Errorf("loading finance data: %w", err)
 	}
-	income := Income{
-		Date:   date,
-		Source: source,
-		Amount: amount,
+	fm := NewFinanceManager()
+	fm.store = store
+	fm.incomes = append(fm.incomes, data.Incomes...)
+	fm.expenses = append(fm.expenses, data.Expenses...)
+	fm.investments = append(fm.investments, data.Investments...)
+	fm.budgets = append(fm.budgets, data.Budgets...)
+	fm.recurring = append(fm.recurring, data.Recurring...)
+	fm.prices = append(fm.prices, data.Prices...)
+	fm.ledger = data.Ledger
+	fm.accounts = append(fm.accounts, data.Accounts...)
+	fm.nextID = data.NextID
+	fm.history = data.History
+
+	// Data written before entries had IDs gets them on first load.
+	for i := range fm.incomes {
+		if fm.incomes[i].ID == "" {
+			fm.incomes[i].ID = fm.newID(incomePrefix)
+		}
+	}
+	for i := range fm.expenses {
+		if fm.expenses[i].ID == "" {
+			fm.expenses[i].ID = fm.newID(expensePrefix)
+		}
+	}
+	for i := range fm.investments {
+		if fm.investments[i].ID == "" {
+			fm.investments[i].ID = fm.newID(investmentPrefix)
+		}
+	}
+	// Ledgers saved before entries were booked get their transactions now.
+	fm.bookMissing()
+	if err := fm.bookLedger(); err != nil {
+		return nil, fmt.Errorf("booking ledger: %w", err)
+	}
+	fm.committed = copyData(fm.data())
+	return fm, nil
+}
+
+func (fm *FinanceManager) data() *FinanceData {
+	return &FinanceData{
+		Incomes:     fm.incomes,
+		Expenses:    fm.expenses,
+		Investments: fm.investments,
+		Budgets:     fm.budgets,
+		Recurring:   fm.recurring,
+		Prices:      fm.prices,
+		Ledger:      fm.ledger,
+		Accounts:    fm.accounts,
+		NextID:      fm.nextID,
+		History:     fm.history,
+	}
+}
+
+// Snapshot returns a read-only copy of the current state. Changing it
+// fails; reading it needs no locking.
+func (fm *FinanceManager) Snapshot() *FinanceManager {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+	d := copyData(fm.data())
+	return &FinanceManager{
+		frozen:        true,
+		incomes:       d.Incomes,
+		expenses:      d.Expenses,
+		investments:   d.Investments,
+		budgets:       d.Budgets,
+		recurring:     d.Recurring,
+		prices:        d.Prices,
+		ledger:        d.Ledger,
+		accounts:      d.Accounts,
+		nextID:        d.NextID,
+		currency:      fm.currency,
+		rates:         fm.rates,
+		costBasis:     fm.costBasis,
+		categoryRules: fm.categoryRules,
+		store:         memoryStore{},
+		history:       append([]AuditEvent(nil), fm.history...),
+		actor:         fm.actor,
+	}
+}
+
+// view returns fm itself when it is a snapshot and a new snapshot
+// otherwise, so nested computations share one state.
+func (fm *FinanceManager) view() *FinanceManager {
+	if fm.frozen {
+		return fm
+	}
+	return fm.Snapshot()
+}
+
+func (fm *FinanceManager) save() error {
+	if err := fm.store.Save(fm.data()); err != nil {
+		return fmt.Errorf("saving finance data: %w", err)
 	}
-	fm.incomes = append(fm.incomes, income)
 	return nil
 }
 
-func (fm *FinanceManager) GetTotalIncome(startDate, endDate time.Time) float64 {
-	var total float64
-	for _, income := range fm.incomes {
-		if (income.Date.After(startDate) || income.Date.Equal(startDate)) &&
-			(income.Date.Before(endDate) || income.Date.Equal(endDate)) {
-			total += income.Amount
+// commit checks accounts and the ledger, records the change in the
+// history and saves the current state, calling rollback if any step fails,
+// so the in-memory state never runs ahead of the store.
+func (fm *FinanceManager) commit(rollback func()) error {
+	return fm.commitAs(auditChange, rollback)
+}
+
+func (fm *FinanceManager) commitAs(action string, rollback func()) error {
+	if fm.frozen {
+		rollback()
+		return fmt.Errorf("a snapshot cannot be changed")
+	}
+	if fm.ledger != nil {
+		// Booking replaces the transactions, so the old ones come back
+		// before the change itself is rolled back.
+		ledger, txns, undo := fm.ledger, fm.ledger.Transactions, rollback
+		rollback = func() {
+			fm.unbooked = nil
+			ledger.Transactions = txns
+			undo()
+		}
+	}
+	if err := fm.checkAccounts(); err != nil {
+		rollback()
+		return invalid(err)
+	}
+	if err := fm.checkLedger(); err != nil {
+		rollback()
+		return invalid(err)
+	}
+	events := len(fm.history)
+	state, err := fm.record(action)
+	if err != nil {
+		rollback()
+		return err
+	}
+	if err := fm.save(); err != nil {
+		fm.history = fm.history[:events]
+		rollback()
+		return err
+	}
+	if state == nil {
+		return nil
+	}
+	if action == auditChange {
+		if fm.undo = append(fm.undo, fm.committed); len(fm.undo) > maxUndo {
+			fm.undo = fm.undo[1:]
 		}
+		fm.redo = nil
 	}
-	return total
+	fm.committed = state
+	return nil
 }
 
-// Expense methods
-func (fm *FinanceManager) AddExpense(date time.Time, category string, amount float64) error {
-	if amount <= 0 {
+// newID returns an ID that is never reused, even after deletes.
+func (fm *FinanceManager) newID(prefix string) string {
+	fm.nextID++
+	return fmt.Sprintf("%s-%d", prefix, fm.nextID)
+}
+
+// Income methods
+func (fm *FinanceManager) AddIncome(date time.Time, source string, amount Money) error {
+	_, err := fm.CreateIncome(Income{Date: date, Source: source, Amount: amount})
+	return err
+}
+
+// CreateIncome stores income under a new ID and returns the stored entry.
+func (fm *FinanceManager) CreateIncome(income Income) (Income, error) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	if err := validateIncome(income); err != nil {
+		return Income{}, invalid(err)
+	}
+	income.Tags = normalizeTags(income.Tags)
+	income.ID = fm.newID(incomePrefix)
+	fm.incomes = append(fm.incomes, income)
+	fm.book(income.ID)
+	err := fm.commit(func() { fm.incomes = fm.incomes[:len(fm.incomes)-1] })
+	if err != nil {
+		return Income{}, err
+	}
+	return income, nil
+}
+
+func (fm *FinanceManager) GetIncome(id string) (Income, error) {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+	i := fm.incomeIndex(id)
+	if i < 0 {
+		return Income{}, &NotFoundError{Kind: "income", ID: id}
+	}
+	return fm.incomes[i], nil
+}
+
+func (fm *FinanceManager) ListIncomes() []Income {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+	return append([]Income(nil), fm.incomes...)
+}
+
+// UpdateIncome replaces the stored income that has the same ID.
+func (fm *FinanceManager) UpdateIncome(income Income) error {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	i := fm.incomeIndex(income.ID)
+	if i < 0 {
+		return &NotFoundError{Kind: "income", ID: income.ID}
+	}
+	if err := validateIncome(income); err != nil {
+		return invalid(err)
+	}
+	income.Tags = normalizeTags(income.Tags)
+	old := fm.incomes[i]
+	fm.incomes[i] = income
+	fm.book(income.ID)
+	return fm.commit(func() { fm.incomes[i] = old })
+}
+
+func (fm *FinanceManager) DeleteIncome(id string) error {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	i := fm.incomeIndex(id)
+	if i < 0 {
+		return &NotFoundError{Kind: "income", ID: id}
+	}
+	old := fm.incomes
+	fm.incomes = append(append([]Income(nil), old[:i]...), old[i+1:]...)
+	fm.book(old[i].ID)
+	return fm.commit(func() { fm.incomes = old })
+}
+
+func (fm *FinanceManager) incomeIndex(id string) int {
+	for i, income := range fm.incomes {
+		if income.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func validateIncome(income Income) error {
+	if income.Date.IsZero() {
+		return fmt.Errorf("date is required")
+	}
+	if strings.TrimSpace(income.Source) == "" {
+		return fmt.Errorf("source is required")
+	}
+	if err := validateCurrency(income.Amount.Currency); err != nil {
+		return err
+	}
+	if !income.Amount.IsPositive() {
 		return fmt.Errorf("amount must be positive")
 	}
-	expense := Expense{
-		Date:     date,
-		Category: category,
-		Amount:   amount,
+	return validateTags(income.Tags)
+}
+
+// GetTotalIncome totals the income dated in [startDate, endDate).
+func (fm *FinanceManager) GetTotalIncome(startDate, endDate time.Time) (Money, error) {
+	fm = fm.view()
+	var amounts []Money
+	for _, income := range fm.incomes {
+		if inPeriod(income.Date, startDate, endDate) {
+			amount, err := fm.toReporting(income.Amount, income.Date)
+			if err != nil {
+				return Money{}, err
+			}
+			amounts = append(amounts, amount)
+		}
+	}
+	return sumMoney(fm.currency, amounts...)
+}
+
+// Expense methods
+func (fm *FinanceManager) AddExpense(date time.Time, category string, amount Money) error {
+	_, err := fm.CreateExpense(Expense{Date: date, Category: category, Amount: amount})
+	return err
+}
+
+// CreateExpense stores expense under a new ID and returns the stored entry.
+func (fm *FinanceManager) CreateExpense(expense Expense) (Expense, error) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	expense = fm.categorize(expense)
+	if err := validateExpense(expense); err != nil {
+		return Expense{}, invalid(err)
 	}
+	expense.Tags = normalizeTags(expense.Tags)
+	expense.ID = fm.newID(expensePrefix)
 	fm.expenses = append(fm.expenses, expense)
-	return nil
+	fm.book(expense.ID)
+	err := fm.commit(func() { fm.expenses = fm.expenses[:len(fm.expenses)-1] })
+	if err != nil {
+		return Expense{}, err
+	}
+	return expense, nil
+}
+
+func (fm *FinanceManager) GetExpense(id string) (Expense, error) {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+	i := fm.expenseIndex(id)
+	if i < 0 {
+		return Expense{}, &NotFoundError{Kind: "expense", ID: id}
+	}
+	return fm.expenses[i], nil
+}
+
+func (fm *FinanceManager) ListExpenses() []Expense {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+	return append([]Expense(nil), fm.expenses...)
+}
+
+// UpdateExpense replaces the stored expense that has the same ID.
+func (fm *FinanceManager) UpdateExpense(expense Expense) error {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	i := fm.expenseIndex(expense.ID)
+	if i < 0 {
+		return &NotFoundError{Kind: "expense", ID: expense.ID}
+	}
+	expense = fm.categorize(expense)
+	if err := validateExpense(expense); err != nil {
+		return invalid(err)
+	}
+	expense.Tags = normalizeTags(expense.Tags)
+	old := fm.expenses[i]
+	fm.expenses[i] = expense
+	fm.book(expense.ID)
+	return fm.commit(func() { fm.expenses[i] = old })
+}
+
+func (fm *FinanceManager) DeleteExpense(id string) error {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	i := fm.expenseIndex(id)
+	if i < 0 {
+		return &NotFoundError{Kind: "expense", ID: id}
+	}
+	old := fm.expenses
+	fm.expenses = append(append([]Expense(nil), old[:i]...), old[i+1:]...)
+	fm.book(old[i].ID)
+	return fm.commit(func() { fm.expenses = old })
+}
+
+func (fm *FinanceManager) expenseIndex(id string) int {
+	for i, expense := range fm.expenses {
+		if expense.ID == id {
+			return i
+		}
+	}
+	return -1
 }
 
-func (fm *FinanceManager) GetTotalExpenses(startDate, endDate time.Time) float64 {
-	var total float64
+func validateExpense(expense Expense) error {
+	if expense.Date.IsZero() {
+		return fmt.Errorf("date is required")
+	}
+	if strings.TrimSpace(expense.Category) == "" {
+		return fmt.Errorf("category is required")
+	}
+	if err := validateCurrency(expense.Amount.Currency); err != nil {
+		return err
+	}
+	if !expense.Amount.IsPositive() {
+		return fmt.Errorf("amount must be positive")
+	}
+	return validateTags(expense.Tags)
+}
+
+// GetTotalExpenses totals the expenses dated in [startDate, endDate).
+func (fm *FinanceManager) GetTotalExpenses(startDate, endDate time.Time) (Money, error) {
+	return fm.GetTotalExpensesByCategory(startDate, endDate, "")
+}
+
+// GetTotalExpensesByCategory totals the expenses in category, ignoring case.
+// An empty category matches every expense.
+func (fm *FinanceManager) GetTotalExpensesByCategory(startDate, endDate time.Time, category string) (Money, error) {
+	fm = fm.view()
+	var amounts []Money
 	for _, expense := range fm.expenses {
-		if (expense.Date.After(startDate) || expense.Date.Equal(startDate)) &&
-			(expense.Date.Before(endDate) || expense.Date.Equal(endDate)) {
-			total += expense.Amount
+		if category != "" && !strings.EqualFold(expense.Category, category) {
+			continue
+		}
+		if inPeriod(expense.Date, startDate, endDate) {
+			amount, err := fm.toReporting(expense.Amount, expense.Date)
+			if err != nil {
+				return Money{}, err
+			}
+			amounts = append(amounts, amount)
 		}
 	}
-	return total
+	return sumMoney(fm.currency, amounts...)
 }
 
 // Investment methods
-func (fm *FinanceManager) AddInvestment(date time.Time, asset string, value float64) error {
-	if value <= 0 {
-		return fmt.Errorf("value must be positive")
-	}
-	investment := Investment{
-		Date:  date,
-		Asset: asset,
-		Value: value,
+func (fm *FinanceManager) AddInvestment(date time.Time, asset string, value Money) error {
+	_, err := fm.CreateInvestment(Investment{Date: date, Asset: asset, Value: value})
+	return err
+}
+
+// CreateInvestment stores investment under a new ID and returns the stored entry.
+func (fm *FinanceManager) CreateInvestment(investment Investment) (Investment, error) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	if err := validateInvestment(investment); err != nil {
+		return Investment{}, invalid(err)
 	}
+	investment.Tags = normalizeTags(investment.Tags)
+	investment.ID = fm.newID(investmentPrefix)
 	fm.investments = append(fm.investments, investment)
-	return nil
+	fm.book(investment.ID)
+	err := fm.commitInvestments(func() { fm.investments = fm.investments[:len(fm.investments)-1] })
+	if err != nil {
+		return Investment{}, err
+	}
+	return investment, nil
 }
 
-func (fm *FinanceManager) GetTotalInvestments() float64 {
-	var total float64
-	for _, investment := range fm.investments {
-		total += investment.Value
+func (fm *FinanceManager) GetInvestment(id string) (Investment, error) {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+	i := fm.investmentIndex(id)
+	if i < 0 {
+		return Investment{}, &NotFoundError{Kind: "investment", ID: id}
 	}
-	return total
+	return fm.investments[i], nil
 }
 
-// Report generation
-func (fm *FinanceManager) GenerateMonthlyReport(year int, month time.Month) string {
-	startDate := time.Date(year, month, 1, 0, 0, 0, 0, time.Local)
-	endDate := startDate.AddDate(0, 1, -1)
+func (fm *FinanceManager) ListInvestments() []Investment {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+	return append([]Investment(nil), fm.investments...)
+}
+
+// UpdateInvestment replaces the stored investment that has the same ID.
+func (fm *FinanceManager) UpdateInvestment(investment Investment) error {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	i := fm.investmentIndex(investment.ID)
+	if i < 0 {
+		return &NotFoundError{Kind: "investment", ID: investment.ID}
+	}
+	if err := validateInvestment(investment); err != nil {
+		return invalid(err)
+	}
+	investment.Tags = normalizeTags(investment.Tags)
+	old := fm.investments[i]
+	fm.investments[i] = investment
+	fm.book(investment.ID)
+	return fm.commitInvestments(func() { fm.investments[i] = old })
+}
+
+func (fm *FinanceManager) DeleteInvestment(id string) error {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	i := fm.investmentIndex(id)
+	if i < 0 {
+		return &NotFoundError{Kind: "investment", ID: id}
+	}
+	old := fm.investments
+	fm.investments = append(append([]Investment(nil), old[:i]...), old[i+1:]...)
+	fm.book(old[i].ID)
+	return fm.commitInvestments(func() { fm.investments = old })
+}
+
+func (fm *FinanceManager) investmentIndex(id string) int {
+	for i, investment := range fm.investments {
+		if investment.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func validateInvestment(investment Investment) error {
+	if investment.Date.IsZero() {
+		return fmt.Errorf("date is required")
+	}
+	if strings.TrimSpace(investment.Asset) == "" {
+		return fmt.Errorf("asset is required")
+	}
+	if err := validateCurrency(investment.Value.Currency); err != nil {
+		return err
+	}
+	if !investment.Value.IsPositive() {
+		return fmt.Errorf("value must be positive")
+	}
+	if investment.Quantity < 0 {
+		return fmt.Errorf("quantity must not be negative")
+	}
+	switch investment.Type {
+	case "", InvestmentBuy, InvestmentSell:
+	default:
+		return fmt.Errorf("invalid investment type %q, expected buy or sell", investment.Type)
+	}
+	return validateTags(investment.Tags)
+}
 
-	totalIncome := fm.GetTotalIncome(startDate, endDate)
-	totalExpenses := fm.GetTotalExpenses(startDate, endDate)
-	totalInvestments := fm.GetTotalInvestments()
-	netProfit := totalIncome - totalExpenses
+// GetTotalInvestments returns the market value of the current holdings.
+func (fm *FinanceManager) GetTotalInvestments() (Money, error) {
+	fm = fm.view()
+	holdings, err := fm.Holdings(time.Time{})
+	if err != nil {
+		return Money{}, err
+	}
+	var amounts []Money
+	for _, holding := range holdings {
+		value, err := fm.toReporting(holding.MarketValue, time.Now())
+		if err != nil {
+			return Money{}, err
+		}
+		amounts = append(amounts, value)
+	}
+	return sumMoney(fm.currency, amounts...)
+}
 
-	report := fmt.Sprintf(`
-Financial Report for %s %d
--------------------------
-Total Income:     $%.2f
-Total Expenses:   $%.2f
-Total Investments: $%.2f
-Net Profit/Loss:  $%.2f
-`, month.String(), year, totalIncome, totalExpenses, totalInvestments, netProfit)
+// Delete removes the entry with the given ID, whatever its kind.
+func (fm *FinanceManager) Delete(id string) error {
+	prefix, _, _ := strings.Cut(id, "-")
+	switch prefix {
+	case incomePrefix:
+		return fm.DeleteIncome(id)
+	case expensePrefix:
+		return fm.DeleteExpense(id)
+	case investmentPrefix:
+		return fm.DeleteInvestment(id)
+	case recurringPrefix:
+		return fm.DeleteRecurring(id)
+	case transactionPrefix:
+		return fm.DeleteTransaction(id)
+	default:
+		return &NotFoundError{Kind: "entry", ID: id}
+	}
+}
 
-	return report
+// Report generation
+func (fm *FinanceManager) GenerateMonthlyReport(year int, month time.Month) (string, error) {
+	return fm.GenerateReport(MonthRange(year, month))
 }
 
 func financeApp() {
-	fm := NewFinanceManager()
-	reader := bufio.NewReader(os.Stdin)
+	p := newPrompter(os.Stdin, os.Stdout)
 
 	// Command line flags
-	mode := flag.String("mode", "interactive", "Mode of operation: interactive or report")
+	mode := flag.String("mode", "interactive", "Mode of operation when no command is given: interactive or report")
+	dataFile := flag.String("data", "finance.json", "Path to the finance data file")
+	storeKind := flag.String("store", "json", "Storage backend: json or journal")
+	currency := flag.String("currency", defaultCurrency, "Reporting currency")
+	ratesFile := flag.String("rates", "", "CSV file of dated exchange rates (date,from,to,rate)")
+	rulesFile := flag.String("rules", "", "JSON file of expense categories and categorization rules")
+	costBasis := flag.String("cost-basis", "fifo", "Cost basis for investment sales: fifo or average")
+	ledger := flag.Bool("ledger", false, "Enable the double-entry ledger; it stays enabled once saved")
+	encrypt := flag.Bool("encrypt", false, "Encrypt the data file with a password; encrypted files are detected without it")
+	passwordEnv := flag.String("password-env", "FINANCE_PASSWORD", "Environment variable holding the data file password, asked for when unset")
 	flag.Parse()
 
-	if *mode == "interactive" {
-		for {
-			fmt.Println("\nFinance Manager")
-			fmt.Println("1. Add Income")
-			fmt.Println("2. Add Expense")
-			fmt.Println("3. Add Investment")
-			fmt.Println("4. Generate Monthly Report")
-			fmt.Println("5. Exit")
-			fmt.Print("Choose an option: ")
-
-			input, _ := reader.ReadString('\n')
-			input = strings.TrimSpace(input)
-
-			switch input {
-			case "1":
-				handleAddIncome(fm, reader)
-			case "2":
-				handleAddExpense(fm, reader)
-			case "3":
-				handleAddInvestment(fm, reader)
-			case "4":
-				handleGenerateReport(fm, reader)
-			case "5":
-				fmt.Println("Goodbye!")
-				return
-			default:
-				fmt.Println("Invalid option")
+	fm, err := openFinanceManager(p, *storeKind, *dataFile, *encrypt, os.Getenv(*passwordEnv))
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if err := fm.SetReportingCurrency(strings.ToUpper(*currency)); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if *ratesFile != "" {
+		rates, err := NewFileRateProvider(*ratesFile)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		fm.SetRateProvider(rates)
+	}
+	if err := fm.SetCostBasisMethod(CostBasisMethod(strings.ToLower(*costBasis))); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if *ledger {
+		if err := fm.EnableLedger(); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+	}
+	if *rulesFile != "" {
+		rules, err := LoadCategoryRules(*rulesFile)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		fm.SetCategoryRules(rules)
+	}
+	if _, err := fm.MaterializeRecurring(time.Now()); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	if flag.NArg() > 0 {
+		if err := runFinanceCommand(fm, p, flag.Args(), os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	switch *mode {
+	case "report":
+		handleGenerateReport(fm)
+	case "interactive":
+		runInteractive(fm, p)
+	default:
+		fmt.Printf("Unknown mode %q\n", *mode)
+	}
+}
+
+// passwordAttempts is how many times a mistyped password may be entered.
+const passwordAttempts = 3
+
+// openFinanceManager loads the data file, asking for its password when the
+// file is encrypted or encrypt is set; a non-empty password is used
+// instead of asking. A file that is not encrypted yet is encrypted at once.
+func openFinanceManager(p *prompter, kind, path string, encrypt bool, password string) (*FinanceManager, error) {
+	if !encrypt && !isEncryptedFile(path) {
+		store, err := newFinanceStore(kind, path)
+		if err != nil {
+			return nil, err
+		}
+		return NewFinanceManagerWithStore(store)
+	}
+	if kind != "json" {
+		return nil, fmt.Errorf("encryption is only supported by the json store")
+	}
+	fresh := !isEncryptedFile(path)
+	for attempt := 1; ; attempt++ {
+		secret := password
+		if secret == "" {
+			var err error
+			if secret, err = p.password("Password", fresh); err != nil {
+				return nil, err
+			}
+		}
+		store := NewEncryptedFileStore(path, secret)
+		fm, err := NewFinanceManagerWithStore(store)
+		if errors.Is(err, ErrWrongPassword) && password == "" && attempt < passwordAttempts {
+			fmt.Fprintln(p.out, "Wrong password, try again.")
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !store.encrypted() {
+			if err := fm.ChangePassword(secret); err != nil {
+				return nil, err
 			}
 		}
+		return fm, nil
 	}
 }
 
-func handleAddIncome(fm *FinanceManager, reader *bufio.Reader) {
-	fmt.Print("Enter source: ")
-	source, _ := reader.ReadString('\n')
-	source = strings.TrimSpace(source)
+// runInteractive shows the menu until the user exits or the input ends.
+func runInteractive(fm *FinanceManager, p *prompter) {
+	for {
+		fmt.Println("\nFinance Manager")
+		fmt.Println("1. Add Income")
+		fmt.Println("2. Add Expense")
+		fmt.Println("3. Add Investment")
+		fmt.Println("4. List Entries")
+		fmt.Println("5. Update Entry")
+		fmt.Println("6. Delete Entry")
+		fmt.Println("7. Generate Monthly Report")
+		fmt.Println("8. Set Budget")
+		fmt.Println("9. Import Bank Statement")
+		fmt.Println("10. Undo")
+		fmt.Println("11. Redo")
+		fmt.Println("12. Show History")
+		fmt.Println("13. Exit")
 
-	fmt.Print("Enter amount: ")
-	amountStr, _ := reader.ReadString('\n')
-	amount, err := strconv.ParseFloat(strings.TrimSpace(amountStr), 64)
+		input, err := p.line("Choose an option: ")
+		if err != nil {
+			fmt.Println("Goodbye!")
+			return
+		}
+
+		switch input {
+		case "1":
+			handleAddIncome(fm, p)
+		case "2":
+			handleAddExpense(fm, p)
+		case "3":
+			handleAddInvestment(fm, p)
+		case "4":
+			handleListEntries(fm)
+		case "5":
+			handleUpdateEntry(fm, p)
+		case "6":
+			handleDeleteEntry(fm, p)
+		case "7":
+			handleGenerateReport(fm)
+		case "8":
+			handleSetBudget(fm, p)
+		case "9":
+			handleImportStatement(fm, p)
+		case "10":
+			handleUndo(fm)
+		case "11":
+			handleRedo(fm)
+		case "12":
+			handleShowHistory(fm, p)
+		case "13":
+			fmt.Println("Goodbye!")
+			return
+		default:
+			fmt.Println("Invalid option")
+		}
+	}
+}
+
+func handleAddIncome(fm *FinanceManager, p *prompter) {
+	date, err := p.date("Enter date", time.Now())
+	if err != nil {
+		return
+	}
+	source, err := p.text("Enter source", true, maxNameLength)
+	if err != nil {
+		return
+	}
+	amount, err := p.amount("Enter amount", fm.currency)
+	if err != nil {
+		return
+	}
+	account, err := promptAccount(fm, p)
+	if err != nil {
+		return
+	}
+	tags, notes, err := promptNotes(p)
 	if err != nil {
-		fmt.Println("Invalid amount")
 		return
 	}
 
-	err = fm.AddIncome(time.Now(), source, amount)
+	_, err = fm.CreateIncome(Income{Date: date, Source: source, Amount: amount, Account: account, Tags: tags, Notes: notes})
 	if err != nil {
 		fmt.Println("Error:", err)
 		return
@@ -257,20 +940,34 @@ func handleAddIncome(fm *FinanceManager, reader *bufio.Reader) {
 	fmt.Println("Income added successfully")
 }
 
-func handleAddExpense(fm *FinanceManager, reader *bufio.Reader) {
-	fmt.Print("Enter category: ")
-	category, _ := reader.ReadString('\n')
-	category = strings.TrimSpace(category)
-
-	fmt.Print("Enter amount: ")
-	amountStr, _ := reader.ReadString('\n')
-	amount, err := strconv.ParseFloat(strings.TrimSpace(amountStr), 64)
+func handleAddExpense(fm *FinanceManager, p *prompter) {
+	date, err := p.date("Enter date", time.Now())
+	if err != nil {
+		return
+	}
+	// With rules, a blank category is left to them and to validation.
+	category, err := p.text("Enter category", !fm.HasCategoryRules(), maxNameLength)
+	if err != nil {
+		return
+	}
+	payee, err := p.text("Enter payee", false, maxNameLength)
+	if err != nil {
+		return
+	}
+	amount, err := p.amount("Enter amount", fm.currency)
+	if err != nil {
+		return
+	}
+	account, err := promptAccount(fm, p)
+	if err != nil {
+		return
+	}
+	tags, notes, err := promptNotes(p)
 	if err != nil {
-		fmt.Println("Invalid amount")
 		return
 	}
 
-	err = fm.AddExpense(time.Now(), category, amount)
+	_, err = fm.CreateExpense(Expense{Date: date, Category: category, Payee: payee, Amount: amount, Account: account, Tags: tags, Notes: notes})
 	if err != nil {
 		fmt.Println("Error:", err)
 		return
@@ -278,20 +975,33 @@ func handleAddExpense(fm *FinanceManager, reader *bufio.Reader) {
 	fmt.Println("Expense added successfully")
 }
 
-func handleAddInvestment(fm *FinanceManager, reader *bufio.Reader) {
-	fmt.Print("Enter asset name: ")
-	asset, _ := reader.ReadString('\n')
-	asset = strings.TrimSpace(asset)
-
-	fmt.Print("Enter value: ")
-	valueStr, _ := reader.ReadString('\n')
-	value, err := strconv.ParseFloat(strings.TrimSpace(valueStr), 64)
+func handleAddInvestment(fm *FinanceManager, p *prompter) {
+	date, err := p.date("Enter date", time.Now())
+	if err != nil {
+		return
+	}
+	asset, err := p.text("Enter asset name", true, maxNameLength)
+	if err != nil {
+		return
+	}
+	kind, err := p.choice("Buy or sell?", []string{string(InvestmentBuy), string(InvestmentSell)}, string(InvestmentBuy))
+	if err != nil {
+		return
+	}
+	quantity, err := p.quantity("Enter quantity", 0)
+	if err != nil {
+		return
+	}
+	price, err := p.amount("Enter unit price", fm.currency)
 	if err != nil {
-		fmt.Println("Invalid value")
 		return
 	}
 
-	err = fm.AddInvestment(time.Now(), asset, value)
+	if InvestmentType(kind) == InvestmentSell {
+		_, err = fm.SellInvestment(date, asset, quantity, price)
+	} else {
+		_, err = fm.BuyInvestment(date, asset, quantity, price)
+	}
 	if err != nil {
 		fmt.Println("Error:", err)
 		return
@@ -299,8 +1009,269 @@ func handleAddInvestment(fm *FinanceManager, reader *bufio.Reader) {
 	fmt.Println("Investment added successfully")
 }
 
-func handleGenerateReport(fm *FinanceManager, reader *bufio.Reader) {
+func handleSetBudget(fm *FinanceManager, p *prompter) {
+	category, err := p.text("Enter category", true, maxNameLength)
+	if err != nil {
+		return
+	}
+	period, err := p.choice("Enter period", []string{string(BudgetMonthly), string(BudgetYearly)}, string(BudgetMonthly))
+	if err != nil {
+		return
+	}
+	limit, err := p.amount("Enter limit", fm.currency)
+	if err != nil {
+		return
+	}
+
+	if err := fm.SetBudget(category, BudgetPeriod(period), limit); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Println("Budget set successfully")
+}
+
+func handleImportStatement(fm *FinanceManager, p *prompter) {
+	path, err := p.text("Enter statement file (.csv or .ofx)", true, maxNotesLength)
+	if err != nil {
+		return
+	}
+	mappingFile, err := p.text("Enter CSV mapping file (blank for date,description,amount)", false, maxNotesLength)
+	if err != nil {
+		return
+	}
+
+	mapping := DefaultCSVMapping()
+	if mappingFile != "" {
+		if mapping, err = LoadCSVMapping(mappingFile); err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+	}
+	transactions, err := ReadStatement(path, "", mapping, fm.currency)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+
+	plan := fm.PlanImport(transactions)
+	formatImportPlan(os.Stdout, plan)
+	if added, _, _ := plan.counts(); added == 0 {
+		fmt.Println("Nothing to import")
+		return
+	}
+
+	ok, err := p.confirm("Import the new entries?")
+	if err != nil {
+		return
+	}
+	if !ok {
+		fmt.Println("Import cancelled")
+		return
+	}
+	added, err := fm.CommitImport(plan)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Printf("Imported %d entries\n", added)
+}
+
+func handleListEntries(fm *FinanceManager) {
+	fmt.Println("Incomes:")
+	for _, income := range fm.ListIncomes() {
+		fmt.Printf("  %-8s %s  %-20s %s\n", income.ID, income.Date.Format("2006-01-02"), income.Source, income.Amount)
+	}
+	fmt.Println("Expenses:")
+	for _, expense := range fm.ListExpenses() {
+		fmt.Printf("  %-8s %s  %-20s %s\n", expense.ID, expense.Date.Format("2006-01-02"), expense.Category, expense.Amount)
+	}
+	fmt.Println("Investments:")
+	for _, investment := range fm.ListInvestments() {
+		fmt.Printf("  %-8s %s  %-20s %-4s %12s  %s\n", investment.ID, investment.Date.Format("2006-01-02"), investment.Asset,
+			investment.kind(), investment.units(), investment.Value)
+	}
+}
+
+func handleUpdateEntry(fm *FinanceManager, p *prompter) {
+	id, err := p.text("Enter entry ID", true, maxNameLength)
+	if err != nil {
+		return
+	}
+
+	prefix, _, _ := strings.Cut(id, "-")
+	switch prefix {
+	case incomePrefix:
+		var income Income
+		if income, err = fm.GetIncome(id); err == nil {
+			if err = promptIncome(p, &income); err == errInputClosed {
+				return
+			} else if err == nil {
+				err = fm.UpdateIncome(income)
+			}
+		}
+	case expensePrefix:
+		var expense Expense
+		if expense, err = fm.GetExpense(id); err == nil {
+			if err = promptExpense(p, &expense); err == errInputClosed {
+				return
+			} else if err == nil {
+				err = fm.UpdateExpense(expense)
+			}
+		}
+	case investmentPrefix:
+		var investment Investment
+		if investment, err = fm.GetInvestment(id); err == nil {
+			if err = promptInvestment(p, &investment); err == errInputClosed {
+				return
+			} else if err == nil {
+				err = fm.UpdateInvestment(investment)
+			}
+		}
+	default:
+		err = &NotFoundError{Kind: "entry", ID: id}
+	}
+
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Println("Entry updated successfully")
+}
+
+// promptIncome asks for each editable field of income, keeping the
+// current value on a blank answer.
+func promptIncome(p *prompter, income *Income) (err error) {
+	if income.Date, err = p.date("Enter date", income.Date); err != nil {
+		return err
+	}
+	if income.Source, err = p.textDefault("Enter source", income.Source, maxNameLength); err != nil {
+		return err
+	}
+	if income.Tags, err = p.tags("Enter tags", income.Tags); err != nil {
+		return err
+	}
+	if income.Notes, err = p.textDefault("Enter notes", income.Notes, maxNotesLength); err != nil {
+		return err
+	}
+	income.Amount, err = p.amountDefault("Enter amount", income.Amount)
+	return err
+}
+
+func promptExpense(p *prompter, expense *Expense) (err error) {
+	if expense.Date, err = p.date("Enter date", expense.Date); err != nil {
+		return err
+	}
+	if expense.Category, err = p.textDefault("Enter category", expense.Category, maxNameLength); err != nil {
+		return err
+	}
+	if expense.Tags, err = p.tags("Enter tags", expense.Tags); err != nil {
+		return err
+	}
+	if expense.Notes, err = p.textDefault("Enter notes", expense.Notes, maxNotesLength); err != nil {
+		return err
+	}
+	expense.Amount, err = p.amountDefault("Enter amount", expense.Amount)
+	return err
+}
+
+func promptInvestment(p *prompter, investment *Investment) (err error) {
+	if investment.Date, err = p.date("Enter date", investment.Date); err != nil {
+		return err
+	}
+	if investment.Asset, err = p.textDefault("Enter asset name", investment.Asset, maxNameLength); err != nil {
+		return err
+	}
+	if investment.Tags, err = p.tags("Enter tags", investment.Tags); err != nil {
+		return err
+	}
+	if investment.Notes, err = p.textDefault("Enter notes", investment.Notes, maxNotesLength); err != nil {
+		return err
+	}
+	if investment.Quantity, err = p.quantity("Enter quantity", investment.units()); err != nil {
+		return err
+	}
+	investment.Value, err = p.amountDefault("Enter total value", investment.Value)
+	return err
+}
+
+func handleDeleteEntry(fm *FinanceManager, p *prompter) {
+	id, err := p.text("Enter entry ID", true, maxNameLength)
+	if err != nil {
+		return
+	}
+
+	if err := fm.Delete(id); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Println("Entry deleted successfully")
+}
+
+func handleUndo(fm *FinanceManager) {
+	if err := fm.Undo(); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Println("Last change undone")
+}
+
+func handleRedo(fm *FinanceManager) {
+	if err := fm.Redo(); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Println("Change redone")
+}
+
+func handleShowHistory(fm *FinanceManager, p *prompter) {
+	count, err := promptFor(p, "How many recent changes? [10]: ", func(input string) (int, error) {
+		if input == "" {
+			return 10, nil
+		}
+		n, err := strconv.Atoi(input)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("expected a positive number")
+		}
+		return n, nil
+	})
+	if err != nil {
+		return
+	}
+	history := fm.History()
+	if len(history) > count {
+		history = history[len(history)-count:]
+	}
+	formatHistory(os.Stdout, history)
+}
+
+// promptAccount asks for an account only once some exist.
+func promptAccount(fm *FinanceManager, p *prompter) (string, error) {
+	accounts := fm.ListAccounts()
+	if len(accounts) == 0 {
+		return "", nil
+	}
+	names := make([]string, len(accounts))
+	for i, account := range accounts {
+		names[i] = account.Name
+	}
+	return p.choice("Enter account", names, "")
+}
+
+func promptNotes(p *prompter) ([]string, string, error) {
+	tags, err := p.tags("Enter tags (comma separated, optional)", nil)
+	if err != nil {
+		return nil, "", err
+	}
+	notes, err := p.text("Enter notes (optional)", false, maxNotesLength)
+	return tags, notes, err
+}
+
+func handleGenerateReport(fm *FinanceManager) {
 	currentTime := time.Now()
-	report := fm.GenerateMonthlyReport(currentTime.Year(), currentTime.Month())
+	report, err := fm.GenerateMonthlyReport(currentTime.Year(), currentTime.Month())
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
 	fmt.Println(report)
 }
@@ -2,6 +2,8 @@
 package main
 
 import (
+	"errors"
+	"sync"
 	"testing"
 	"time"
 )
@@ -10,13 +12,13 @@ func TestIncomeManagement(t *testing.T) {
 	fm := NewFinanceManager()
 
 	// Test adding valid income
-	err := fm.AddIncome(time.Now(), "Salary", 5000.0)
+	err := fm.AddIncome(time.Now(), "Salary", usd("5000.00"))
 	if err != nil {
 		t.Errorf("Failed to add valid income: %v", err)
 	}
 
 	// Test adding invalid income
-	err = fm.AddIncome(time.Now(), "Bonus", -100.0)
+	err = fm.AddIncome(time.Now(), "Bonus", usd("-100.00"))
 	if err == nil {
 		t.Error("Expected error for negative income amount")
 	}
@@ -24,9 +26,9 @@ func TestIncomeManagement(t *testing.T) {
 	// Test total income calculation
 	startDate := time.Now().AddDate(0, -1, 0)
 	endDate := time.Now().AddDate(0, 0, 1)
-	total := fm.GetTotalIncome(startDate, endDate)
-	if total != 5000.0 {
-		t.Errorf("Expected total income of 5000.0, got %f", total)
+	total, err := fm.GetTotalIncome(startDate, endDate)
+	if err != nil || total != usd("5000.00") {
+		t.Errorf("Expected total income of $5000.00, got %s (%v)", total, err)
 	}
 }
 
@@ -34,13 +36,13 @@ func TestExpenseManagement(t *testing.T) {
 	fm := NewFinanceManager()
 
 	// Test adding valid expense
-	err := fm.AddExpense(time.Now(), "Rent", 1000.0)
+	err := fm.AddExpense(time.Now(), "Rent", usd("1000.00"))
 	if err != nil {
 		t.Errorf("Failed to add valid expense: %v", err)
 	}
 
 	// Test adding invalid expense
-	err = fm.AddExpense(time.Now(), "Food", -50.0)
+	err = fm.AddExpense(time.Now(), "Food", usd("-50.00"))
 	if err == nil {
 		t.Error("Expected error for negative expense amount")
 	}
@@ -48,9 +50,9 @@ func TestExpenseManagement(t *testing.T) {
 	// Test total expenses calculation
 	startDate := time.Now().AddDate(0, -1, 0)
 	endDate := time.Now().AddDate(0, 0, 1)
-	total := fm.GetTotalExpenses(startDate, endDate)
-	if total != 1000.0 {
-		t.Errorf("Expected total expenses of 1000.0, got %f", total)
+	total, err := fm.GetTotalExpenses(startDate, endDate)
+	if err != nil || total != usd("1000.00") {
+		t.Errorf("Expected total expenses of $1000.00, got %s (%v)", total, err)
 	}
 }
 
@@ -58,21 +60,21 @@ func TestInvestmentManagement(t *testing.T) {
 	fm := NewFinanceManager()
 
 	// Test adding valid investment
-	err := fm.AddInvestment(time.Now(), "Stocks", 2000.0)
+	err := fm.AddInvestment(time.Now(), "Stocks", usd("2000.00"))
 	if err != nil {
 		t.Errorf("Failed to add valid investment: %v", err)
 	}
 
 	// Test adding invalid investment
-	err = fm.AddInvestment(time.Now(), "Bonds", -500.0)
+	err = fm.AddInvestment(time.Now(), "Bonds", usd("-500.00"))
 	if err == nil {
 		t.Error("Expected error for negative investment value")
 	}
 
 	// Test total investments calculation
-	total := fm.GetTotalInvestments()
-	if total != 2000.0 {
-		t.Errorf("Expected total investments of 2000.0, got %f", total)
+	total, err := fm.GetTotalInvestments()
+	if err != nil || total != usd("2000.00") {
+		t.Errorf("Expected total investments of $2000.00, got %s (%v)", total, err)
 	}
 }
 
@@ -81,13 +83,158 @@ func TestMonthlyReport(t *testing.T) {
 
 	// Add some test data
 	currentTime := time.Now()
-	fm.AddIncome(currentTime, "Salary", 5000.0)
-	fm.AddExpense(currentTime, "Rent", 1000.0)
-	fm.AddInvestment(currentTime, "Stocks", 2000.0)
+	fm.AddIncome(currentTime, "Salary", usd("5000.00"))
+	fm.AddExpense(currentTime, "Rent", usd("1000.00"))
+	fm.AddInvestment(currentTime, "Stocks", usd("2000.00"))
 
 	// Generate and check report
-	report := fm.GenerateMonthlyReport(currentTime.Year(), currentTime.Month())
+	report, err := fm.GenerateMonthlyReport(currentTime.Year(), currentTime.Month())
+	if err != nil {
+		t.Fatalf("Failed to generate report: %v", err)
+	}
 	if report == "" {
 		t.Error("Expected non-empty report")
 	}
 }
+
+func TestEntryCRUD(t *testing.T) {
+	fm := NewFinanceManager()
+
+	income, err := fm.CreateIncome(Income{Date: time.Now(), Source: "Salary", Amount: usd("5000.00")})
+	if err != nil {
+		t.Fatalf("Failed to create income: %v", err)
+	}
+	if income.ID == "" {
+		t.Fatal("Expected created income to have an ID")
+	}
+
+	got, err := fm.GetIncome(income.ID)
+	if err != nil || got.Source != "Salary" {
+		t.Errorf("Expected to get income back, got %+v (%v)", got, err)
+	}
+
+	income.Amount = usd("5500.00")
+	if err := fm.UpdateIncome(income); err != nil {
+		t.Errorf("Failed to update income: %v", err)
+	}
+	if got, _ := fm.GetIncome(income.ID); got.Amount != usd("5500.00") {
+		t.Errorf("Expected updated amount $5500.00, got %s", got.Amount)
+	}
+
+	income.Amount = usd("-1.00")
+	if err := fm.UpdateIncome(income); err == nil {
+		t.Error("Expected error for negative income amount on update")
+	}
+
+	expense, _ := fm.CreateExpense(Expense{Date: time.Now(), Category: "Rent", Amount: usd("1000.00")})
+	if expense.ID == income.ID {
+		t.Errorf("Expected distinct IDs, both are %q", expense.ID)
+	}
+
+	if err := fm.Delete(income.ID); err != nil {
+		t.Errorf("Failed to delete income: %v", err)
+	}
+	if len(fm.ListIncomes()) != 0 {
+		t.Errorf("Expected no incomes after delete, got %d", len(fm.ListIncomes()))
+	}
+
+	var notFound *NotFoundError
+	if _, err := fm.GetIncome(income.ID); !errors.As(err, &notFound) {
+		t.Errorf("Expected NotFoundError, got %v", err)
+	}
+	if err := fm.DeleteExpense("exp-999"); !errors.As(err, &notFound) {
+		t.Errorf("Expected NotFoundError, got %v", err)
+	}
+}
+
+func TestConcurrentAddsDuringReports(t *testing.T) {
+	fm := NewFinanceManager()
+	const writers, perWriter = 8, 25
+	r := MonthRange(2024, time.March)
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				if _, err := fm.CreateIncome(Income{Date: date(2024, 3, 1+i%28), Source: "Salary", Amount: usd("10.00")}); err != nil {
+					t.Errorf("Failed to add income: %v", err)
+				}
+				if _, err := fm.CreateExpense(Expense{Date: date(2024, 3, 1+i%28), Category: "Food", Amount: usd("4.00")}); err != nil {
+					t.Errorf("Failed to add expense: %v", err)
+				}
+			}
+		}()
+	}
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for round := 0; round < 20; round++ {
+				snapshot := fm.Snapshot()
+				report, err := snapshot.BuildReport(r)
+				if err != nil {
+					t.Errorf("Failed to build report: %v", err)
+					return
+				}
+				incomes, expenses := len(snapshot.ListIncomes()), len(snapshot.ListExpenses())
+				if report.TotalIncome.Minor != int64(incomes)*1000 || report.TotalExpenses.Minor != int64(expenses)*400 {
+					t.Errorf("Expected report of %d incomes and %d expenses, got %s and %s", incomes, expenses,
+						report.TotalIncome, report.TotalExpenses)
+				}
+				if _, err := fm.GenerateReport(r); err != nil {
+					t.Errorf("Failed to generate report: %v", err)
+				}
+				fm.Search(EntryQuery{Text: "food", Limit: 5})
+			}
+		}()
+	}
+	wg.Wait()
+
+	ids := make(map[string]bool)
+	for _, income := range fm.ListIncomes() {
+		ids[income.ID] = true
+	}
+	if len(ids) != writers*perWriter {
+		t.Errorf("Expected %d incomes with unique IDs, got %d", writers*perWriter, len(ids))
+	}
+	if total, _ := fm.GetTotalExpenses(r.Start, r.End); total != usd("800.00") {
+		t.Errorf("Expected total expenses $800.00, got %s", total)
+	}
+}
+
+func TestSnapshotIsReadOnly(t *testing.T) {
+	fm := NewFinanceManager()
+	fm.AddIncome(date(2024, 3, 1), "Salary", usd("5000.00"))
+	snapshot := fm.Snapshot()
+	fm.AddIncome(date(2024, 3, 2), "Bonus", usd("500.00"))
+
+	if len(snapshot.ListIncomes()) != 1 {
+		t.Errorf("Expected the snapshot to keep 1 income, got %d", len(snapshot.ListIncomes()))
+	}
+	if err := snapshot.AddIncome(date(2024, 3, 3), "Gift", usd("20.00")); err == nil {
+		t.Error("Expected error changing a snapshot")
+	}
+	if len(snapshot.ListIncomes()) != 1 || len(fm.ListIncomes()) != 2 {
+		t.Errorf("Expected 1 and 2 incomes, got %d and %d", len(snapshot.ListIncomes()), len(fm.ListIncomes()))
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	fm := NewFinanceManager()
+	var verr *ValidationError
+	if err := fm.SetBudget("Rent", BudgetMonthly, usd("-1.00")); !errors.As(err, &verr) {
+		t.Errorf("Expected a validation error for a negative budget, got %v", err)
+	}
+	if err := fm.AddAccount(Account{Name: " ", Kind: AccountChecking}); !errors.As(err, &verr) {
+		t.Errorf("Expected a validation error for an unnamed account, got %v", err)
+	}
+	if _, err := fm.Transfer(date(2024, 3, 1), "Checking", "Savings", usd("0.00"), ""); !errors.As(err, &verr) {
+		t.Errorf("Expected a validation error for an empty transfer, got %v", err)
+	}
+	if err := fm.AddExpense(time.Time{}, "Rent", usd("10.00")); !errors.As(err, &verr) {
+		t.Errorf("Expected a validation error for a missing date, got %v", err)
+	}
+}
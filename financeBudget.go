@@ -0,0 +1,192 @@
+package main
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+type BudgetPeriod string
+
+const (
+	BudgetMonthly BudgetPeriod = "monthly"
+	BudgetYearly  BudgetPeriod = "yearly"
+)
+
+// Budget caps the expenses of one category per month or per year.
+type Budget struct {
+	Category string       `json:"category"`
+	Period   BudgetPeriod `json:"period"`
+	Limit    Money        `json:"limit"`
+}
+
+// BudgetStatus compares a budget with the spending in the period that
+// contains the date it was computed for. Amounts are in the reporting
+// currency; Projected extrapolates the spending pace to the whole period.
+type BudgetStatus struct {
+	Budget
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	Spent      Money     `json:"spent"`
+	Remaining  Money     `json:"remaining"`
+	Projected  Money     `json:"projected"`
+	Percent    float64   `json:"percent"`
+	OverBudget bool      `json:"over_budget"`
+	AtRisk     bool      `json:"at_risk"`
+}
+
+func parseBudgetPeriod(value string) (BudgetPeriod, error) {
+	switch period := BudgetPeriod(strings.ToLower(value)); period {
+	case BudgetMonthly, BudgetYearly:
+		return period, nil
+	}
+	return "", fmt.Errorf("invalid budget period %q, expected monthly or yearly", value)
+}
+
+// periodRange returns the [start, end) bounds of the budget period containing date.
+func (p BudgetPeriod) periodRange(date time.Time) (time.Time, time.Time) {
+	if p == BudgetYearly {
+		start := time.Date(date.Year(), time.January, 1, 0, 0, 0, 0, date.Location())
+		return start, start.AddDate(1, 0, 0)
+	}
+	start := time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, date.Location())
+	return start, start.AddDate(0, 1, 0)
+}
+
+// SetBudget creates or replaces the budget for category and period.
+func (fm *FinanceManager) SetBudget(category string, period BudgetPeriod, limit Money) error {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	category = strings.TrimSpace(category)
+	if category == "" {
+		return invalid(fmt.Errorf("category is required"))
+	}
+	if _, err := parseBudgetPeriod(string(period)); err != nil {
+		return invalid(err)
+	}
+	if err := validateCurrency(limit.Currency); err != nil {
+		return invalid(err)
+	}
+	if !limit.IsPositive() {
+		return invalid(fmt.Errorf("limit must be positive"))
+	}
+
+	budget := Budget{Category: category, Period: period, Limit: limit}
+	if i := fm.budgetIndex(category, period); i >= 0 {
+		old := fm.budgets[i]
+		fm.budgets[i] = budget
+		return fm.commit(func() { fm.budgets[i] = old })
+	}
+	fm.budgets = append(fm.budgets, budget)
+	return fm.commit(func() { fm.budgets = fm.budgets[:len(fm.budgets)-1] })
+}
+
+func (fm *FinanceManager) RemoveBudget(category string, period BudgetPeriod) error {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	i := fm.budgetIndex(category, period)
+	if i < 0 {
+		return &NotFoundError{Kind: "budget", ID: fmt.Sprintf("%s/%s", category, period)}
+	}
+	old := fm.budgets
+	fm.budgets = append(append([]Budget(nil), old[:i]...), old[i+1:]...)
+	return fm.commit(func() { fm.budgets = old })
+}
+
+func (fm *FinanceManager) Budgets() []Budget {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+	return append([]Budget(nil), fm.budgets...)
+}
+
+func (fm *FinanceManager) budgetIndex(category string, period BudgetPeriod) int {
+	for i, budget := range fm.budgets {
+		if budget.Period == period && strings.EqualFold(budget.Category, category) {
+			return i
+		}
+	}
+	return -1
+}
+
+// BudgetProgress reports every budget for the period containing asOf.
+func (fm *FinanceManager) BudgetProgress(asOf time.Time) ([]BudgetStatus, error) {
+	fm = fm.view()
+	statuses := make([]BudgetStatus, 0, len(fm.budgets))
+	for _, budget := range fm.budgets {
+		status, err := fm.budgetStatus(budget, asOf)
+		if err != nil {
+			return nil, fmt.Errorf("budget %s (%s): %w", budget.Category, budget.Period, err)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+func (fm *FinanceManager) budgetStatus(budget Budget, asOf time.Time) (BudgetStatus, error) {
+	start, end := budget.Period.periodRange(asOf)
+	status := BudgetStatus{Budget: budget, Start: start, End: end}
+
+	limit, err := fm.toReporting(budget.Limit, start)
+	if err != nil {
+		return status, err
+	}
+	status.Limit = limit
+
+	if status.Spent, err = fm.GetTotalExpensesByCategory(start, end, budget.Category); err != nil {
+		return status, err
+	}
+	if status.Remaining, err = limit.Sub(status.Spent); err != nil {
+		return status, err
+	}
+	switch {
+	case limit.Minor > 0:
+		status.Percent = float64(status.Spent.Minor) / float64(limit.Minor) * 100
+	case status.Spent.Minor > 0:
+		// A limit that converts to less than one minor unit is used up by
+		// any spending.
+		status.Percent = 100
+	}
+
+	// Project the pace so far over the whole period, counting asOf's day as elapsed.
+	status.Projected = status.Spent
+	totalDays := daysBetween(start, end)
+	elapsedDays := daysBetween(start, asOf) + 1
+	if elapsedDays < totalDays {
+		projected, err := status.Spent.Convert(status.Spent.Currency, big.NewRat(int64(totalDays), int64(elapsedDays)))
+		if err != nil {
+			return status, err
+		}
+		status.Projected = projected
+	}
+
+	status.OverBudget = status.Spent.Minor > limit.Minor
+	status.AtRisk = !status.OverBudget && status.Projected.Minor > limit.Minor
+	return status, nil
+}
+
+// daysBetween counts calendar days, so DST changes do not skew the result.
+func daysBetween(from, to time.Time) int {
+	a := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	b := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(b.Sub(a).Hours() / 24)
+}
+
+func (s BudgetStatus) flag() string {
+	switch {
+	case s.OverBudget:
+		return "OVER BUDGET"
+	case s.AtRisk:
+		return "projected to overspend"
+	}
+	return "on track"
+}
+
+func formatBudgetStatuses(statuses []BudgetStatus) string {
+	var b strings.Builder
+	for _, s := range statuses {
+		fmt.Fprintf(&b, "%-20s %s of %s (%.0f%%), projected %s - %s\n",
+			fmt.Sprintf("%s (%s):", s.Category, s.Period), s.Spent, s.Limit, s.Percent, s.Projected, s.flag())
+	}
+	return b.String()
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBudgetProgress(t *testing.T) {
+	fm := NewFinanceManager()
+	fm.SetBudget("Groceries", BudgetMonthly, usd("300.00"))
+	fm.SetBudget("Rent", BudgetMonthly, usd("1000.00"))
+	fm.SetBudget("Travel", BudgetYearly, usd("2000.00"))
+
+	fm.AddExpense(time.Date(2024, 4, 2, 0, 0, 0, 0, time.Local), "groceries", usd("100.00"))
+	fm.AddExpense(time.Date(2024, 4, 5, 0, 0, 0, 0, time.Local), "Groceries", usd("50.00"))
+	fm.AddExpense(time.Date(2024, 4, 1, 0, 0, 0, 0, time.Local), "Rent", usd("1100.00"))
+	fm.AddExpense(time.Date(2024, 2, 1, 0, 0, 0, 0, time.Local), "Travel", usd("500.00"))
+	fm.AddExpense(time.Date(2024, 3, 31, 12, 0, 0, 0, time.Local), "Groceries", usd("999.00"))
+
+	statuses, err := fm.BudgetProgress(time.Date(2024, 4, 10, 0, 0, 0, 0, time.Local))
+	if err != nil {
+		t.Fatalf("Failed to compute budget progress: %v", err)
+	}
+	if len(statuses) != 3 {
+		t.Fatalf("Expected 3 budget statuses, got %d", len(statuses))
+	}
+
+	groceries := statuses[0]
+	if groceries.Spent != usd("150.00") {
+		t.Errorf("Expected $150.00 spent on groceries, got %s", groceries.Spent)
+	}
+	// 150 over 10 of 30 days projects to 450, above the 300 limit.
+	if groceries.Projected != usd("450.00") || !groceries.AtRisk || groceries.OverBudget {
+		t.Errorf("Expected groceries to be projected at $450.00 and at risk, got %+v", groceries)
+	}
+
+	if rent := statuses[1]; !rent.OverBudget {
+		t.Errorf("Expected rent to be over budget, got %+v", rent)
+	}
+	if travel := statuses[2]; travel.Spent != usd("500.00") || travel.Remaining != usd("1500.00") {
+		t.Errorf("Expected $500.00 of yearly travel budget used, got %+v", travel)
+	}
+
+	report, err := fm.GenerateMonthlyReport(2024, time.April)
+	if err != nil {
+		t.Fatalf("Failed to generate report: %v", err)
+	}
+	if !strings.Contains(report, "OVER BUDGET") {
+		t.Errorf("Expected report to flag rent as over budget:\n%s", report)
+	}
+
+	if err := fm.RemoveBudget("rent", BudgetMonthly); err != nil {
+		t.Errorf("Failed to remove budget: %v", err)
+	}
+	if len(fm.Budgets()) != 2 {
+		t.Errorf("Expected 2 budgets after removal, got %d", len(fm.Budgets()))
+	}
+}
+
+func TestBudgetLimitRoundingToZero(t *testing.T) {
+	rates := &FileRateProvider{}
+	rates.Add(date(2024, 1, 1), "JPY", "USD", big.NewRat(1, 1000))
+	fm := NewFinanceManager()
+	fm.SetRateProvider(rates)
+	fm.SetBudget("Snacks", BudgetMonthly, NewMoney(1, "JPY"))
+
+	statuses, err := fm.BudgetProgress(date(2024, 4, 10))
+	if err != nil {
+		t.Fatalf("Failed to compute budget progress: %v", err)
+	}
+	if s := statuses[0]; s.Limit.Minor != 0 || s.Percent != 0 {
+		t.Errorf("Expected a zero limit at 0%%, got %s at %v%%", s.Limit, s.Percent)
+	}
+
+	fm.AddExpense(date(2024, 4, 2), "Snacks", usd("1.00"))
+	statuses, _ = fm.BudgetProgress(date(2024, 4, 10))
+	if s := statuses[0]; s.Percent != 100 || !s.OverBudget {
+		t.Errorf("Expected spending against a zero limit to be over budget at 100%%, got %+v", s)
+	}
+}
@@ -0,0 +1,1271 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Non-interactive subcommands, e.g.
+//
+//	finance -data ledger.json add-expense -date 2024-03-01 -amount 950 -category Rent
+//	finance report -year 2024 -month 3
+//
+// The few commands that ask for anything, like change-password, read the
+// answers from the prompter they are given.
+type financeCommand struct {
+	name  string
+	usage string
+	run   func(fm *FinanceManager, p *prompter, args []string, out io.Writer) error
+}
+
+var financeCommands = []financeCommand{
+	{"add-income", "Add an income entry", runAddIncome},
+	{"add-expense", "Add an expense entry", runAddExpense},
+	{"add-investment", "Add an investment entry", runAddInvestment},
+	{"list", "List entries", runList},
+	{"report", "Print the financial report for a month", runReport},
+	{"chart", "Chart income vs expenses, categories or investment value as text or SVG", runChart},
+	{"delete", "Delete an entry by ID", runDelete},
+	{"set-budget", "Set a monthly or yearly category budget", runSetBudget},
+	{"budgets", "Show budget progress", runBudgets},
+	{"forecast", "Project income, expenses and balance for the coming months", runForecast},
+	{"import", "Import a CSV or OFX bank statement", runImport},
+	{"categorize", "Re-run the category rules over stored expenses", runCategorize},
+	{"add-recurring", "Add a recurring income or expense series", runAddRecurring},
+	{"list-recurring", "List recurring series", runListRecurring},
+	{"end-recurring", "End a recurring series after a date", runEndRecurring},
+	{"materialize", "Create the entries of recurring series due up to a date", runMaterialize},
+	{"holdings", "Show investment holdings with cost basis and unrealized gains", runHoldings},
+	{"gains", "List realized gains from investment sales in a period", runGains},
+	{"add-price", "Record the price of an asset on a date", runAddPrice},
+	{"import-prices", "Load asset prices from a CSV file (date,asset,price[,currency])", runImportPrices},
+	{"valuation", "Value the investment holdings as of a date", runValuation},
+	{"analytics", "Show investment statistics, returns and drawdown for a period", runAnalytics},
+	{"transfer", "Move money between ledger accounts", runTransfer},
+	{"ledger", "List the ledger transactions", runLedger},
+	{"trial-balance", "Show the balance of every ledger account", runTrialBalance},
+	{"add-account", "Open a checking, savings, credit card, broker or cash account", runAddAccount},
+	{"accounts", "List accounts with their balances as of a date", runAccounts},
+	{"register", "Show the movements of an account with running balances", runRegister},
+	{"search", "Find entries by text, tag, amount, date and type", runSearch},
+	{"history", "Show who changed what and when", runHistory},
+	{"change-password", "Re-encrypt the data file with a new password", runChangePassword},
+	{"serve", "Serve incomes, expenses, investments and reports as a JSON REST API", runServe},
+}
+
+func runFinanceCommand(fm *FinanceManager, p *prompter, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("no command given")
+	}
+	for _, cmd := range financeCommands {
+		if cmd.name == args[0] {
+			return cmd.run(fm, p, args[1:], out)
+		}
+	}
+	printFinanceCommands(out)
+	return fmt.Errorf("unknown command %q", args[0])
+}
+
+func printFinanceCommands(out io.Writer) {
+	fmt.Fprintln(out, "Commands:")
+	for _, cmd := range financeCommands {
+		fmt.Fprintf(out, "  %-16s %s\n", cmd.name, cmd.usage)
+	}
+}
+
+func newCommandFlags(name string, out io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(out)
+	return fs
+}
+
+// dateLayouts are the day formats parseDate accepts besides RFC 3339.
+var dateLayouts = []string{
+	"2006-01-02",
+	"2006/01/02",
+	"02.01.2006",
+	"2 Jan 2006",
+	"2 January 2006",
+	"Jan 2, 2006",
+	"January 2, 2006",
+}
+
+// parseDate accepts a calendar date in any of dateLayouts or a full RFC
+// 3339 timestamp.
+func parseDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD, DD.MM.YYYY or 2 Jan 2006", value)
+}
+
+// entryFlags are shared by the add-* commands.
+type entryFlags struct {
+	date   *string
+	amount *string
+	format *string
+}
+
+func addEntryFlags(fs *flag.FlagSet, amountName string) entryFlags {
+	return entryFlags{
+		date:   fs.String("date", "", "Entry date (YYYY-MM-DD), defaults to today"),
+		amount: fs.String(amountName, "", "Amount, optionally followed by a currency code"),
+		format: fs.String("format", "text", "Output format: text or json"),
+	}
+}
+
+// noteFlags are the descriptive flags of the add-* commands for entries.
+type noteFlags struct {
+	tags  *string
+	notes *string
+}
+
+func addNoteFlags(fs *flag.FlagSet) noteFlags {
+	return noteFlags{
+		tags:  fs.String("tags", "", "Comma separated tags, e.g. travel,berlin"),
+		notes: fs.String("notes", "", "Free-form notes"),
+	}
+}
+
+func (f entryFlags) parse(fm *FinanceManager, amountName string) (time.Time, Money, error) {
+	date, err := f.entryDate()
+	if err != nil {
+		return time.Time{}, Money{}, err
+	}
+	if *f.amount == "" {
+		return time.Time{}, Money{}, fmt.Errorf("-%s is required", amountName)
+	}
+	amount, err := ParseMoney(*f.amount, fm.currency)
+	if err != nil {
+		return time.Time{}, Money{}, err
+	}
+	return date, amount, nil
+}
+
+func (f entryFlags) entryDate() (time.Time, error) {
+	if *f.date == "" {
+		return time.Now(), nil
+	}
+	return parseDate(*f.date)
+}
+
+func requireFlag(name, value string) error {
+	if strings.TrimSpace(value) == "" {
+		return fmt.Errorf("-%s is required", name)
+	}
+	return nil
+}
+
+func runAddIncome(fm *FinanceManager, p *prompter, args []string, out io.Writer) error {
+	fs := newCommandFlags("add-income", out)
+	entry := addEntryFlags(fs, "amount")
+	source := fs.String("source", "", "Income source")
+	account := fs.String("account", "", "Account the income is paid into")
+	notes := addNoteFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	date, amount, err := entry.parse(fm, "amount")
+	if err != nil {
+		return err
+	}
+	if err := requireFlag("source", *source); err != nil {
+		return err
+	}
+	income, err := fm.CreateIncome(Income{Date: date, Source: *source, Amount: amount, Account: *account,
+		Tags: splitTags(*notes.tags), Notes: *notes.notes})
+	if err != nil {
+		return err
+	}
+	return writeCreated(out, *entry.format, income.ID, income)
+}
+
+func runAddExpense(fm *FinanceManager, p *prompter, args []string, out io.Writer) error {
+	fs := newCommandFlags("add-expense", out)
+	entry := addEntryFlags(fs, "amount")
+	category := fs.String("category", "", "Expense category, assigned by the category rules when omitted")
+	payee := fs.String("payee", "", "Who was paid")
+	account := fs.String("account", "", "Account the expense is paid from")
+	notes := addNoteFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	date, amount, err := entry.parse(fm, "amount")
+	if err != nil {
+		return err
+	}
+	// A missing category is left to the category rules.
+	expense, err := fm.CreateExpense(Expense{Date: date, Category: *category, Payee: *payee, Amount: amount, Account: *account,
+		Tags: splitTags(*notes.tags), Notes: *notes.notes})
+	if err != nil {
+		return err
+	}
+	return writeCreated(out, *entry.format, expense.ID, expense)
+}
+
+func runAddInvestment(fm *FinanceManager, p *prompter, args []string, out io.Writer) error {
+	fs := newCommandFlags("add-investment", out)
+	entry := addEntryFlags(fs, "value")
+	asset := fs.String("asset", "", "Asset name")
+	kindStr := fs.String("type", "buy", "Trade type: buy or sell")
+	quantityStr := fs.String("quantity", "", "Units bought or sold (default 1)")
+	priceStr := fs.String("price", "", "Unit price, instead of the total -value")
+	account := fs.String("account", "", "Account the trade is settled in")
+	notes := addNoteFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlag("asset", *asset); err != nil {
+		return err
+	}
+	kind, err := parseInvestmentType(*kindStr)
+	if err != nil {
+		return err
+	}
+	investment := Investment{Asset: *asset, Type: kind, Account: *account, Tags: splitTags(*notes.tags), Notes: *notes.notes}
+	if *quantityStr != "" {
+		if investment.Quantity, err = ParseQuantity(*quantityStr); err != nil {
+			return err
+		}
+		if investment.Quantity == 0 {
+			return errors.New("quantity must be positive")
+		}
+	}
+
+	if *priceStr != "" {
+		if *entry.amount != "" {
+			return errors.New("-value and -price cannot be combined")
+		}
+		price, err := ParseMoney(*priceStr, fm.currency)
+		if err != nil {
+			return err
+		}
+		if investment.Value, err = scaleMoney(price, investment.units(), oneUnit); err != nil {
+			return err
+		}
+		if investment.Date, err = entry.entryDate(); err != nil {
+			return err
+		}
+	} else if investment.Date, investment.Value, err = entry.parse(fm, "value"); err != nil {
+		return err
+	}
+
+	investment, err = fm.CreateInvestment(investment)
+	if err != nil {
+		return err
+	}
+	return writeCreated(out, *entry.format, investment.ID, investment)
+}
+
+// writeCreated prints the new entry's ID so scripts can capture it.
+func writeCreated(out io.Writer, format, id string, entry any) error {
+	switch format {
+	case "text":
+		_, err := fmt.Fprintln(out, id)
+		return err
+	case "json":
+		return writeJSON(out, entry)
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
+
+func writeJSON(out io.Writer, v any) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// entryRow is the common shape used to list entries of every kind.
+type entryRow struct {
+	ID          string    `json:"id"`
+	Type        string    `json:"type"`
+	Date        time.Time `json:"date"`
+	Description string    `json:"description"`
+	Amount      Money     `json:"amount"`
+	Account     string    `json:"account,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	Notes       string    `json:"notes,omitempty"`
+}
+
+func (fm *FinanceManager) entryRows(kind string) ([]entryRow, error) {
+	fm = fm.view()
+	switch kind {
+	case "all", "income", "expense", "investment":
+	default:
+		return nil, fmt.Errorf("unknown entry type %q", kind)
+	}
+
+	var rows []entryRow
+	if kind == "all" || kind == "income" {
+		for _, income := range fm.ListIncomes() {
+			rows = append(rows, entryRow{income.ID, "income", income.Date, income.Source, income.Amount,
+				income.Account, income.Tags, income.Notes})
+		}
+	}
+	if kind == "all" || kind == "expense" {
+		for _, expense := range fm.ListExpenses() {
+			rows = append(rows, entryRow{expense.ID, "expense", expense.Date, expense.Category, expense.Amount,
+				expense.Account, expense.Tags, expense.Notes})
+		}
+	}
+	if kind == "all" || kind == "investment" {
+		for _, investment := range fm.ListInvestments() {
+			rows = append(rows, entryRow{investment.ID, "investment", investment.Date, investment.Asset, investment.Value,
+				investment.Account, investment.Tags, investment.Notes})
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].Date.Before(rows[j].Date) })
+	return rows, nil
+}
+
+func runList(fm *FinanceManager, p *prompter, args []string, out io.Writer) error {
+	fs := newCommandFlags("list", out)
+	kind := fs.String("type", "all", "Entry type: all, income, expense or investment")
+	from := fs.String("from", "", "Only entries on or after this date (YYYY-MM-DD)")
+	to := fs.String("to", "", "Only entries before this date (YYYY-MM-DD)")
+	account := fs.String("account", "", "Only entries of this account")
+	format := fs.String("format", "text", "Output format: text, json or csv")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	view := fm
+	if *account != "" {
+		var err error
+		if view, err = fm.forAccount(*account); err != nil {
+			return err
+		}
+	}
+	rows, err := view.entryRows(*kind)
+	if err != nil {
+		return err
+	}
+	if *from != "" || *to != "" {
+		rows, err = filterRowsByDate(rows, *from, *to)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch *format {
+	case "text":
+		for _, row := range rows {
+			fmt.Fprintf(out, "%-8s %-10s %s  %-20s %s\n", row.ID, row.Type, row.Date.Format("2006-01-02"), row.Description, row.Amount)
+		}
+		return nil
+	case "json":
+		if rows == nil {
+			rows = []entryRow{}
+		}
+		return writeJSON(out, rows)
+	case "csv":
+		w := csv.NewWriter(out)
+		w.Write([]string{"id", "type", "date", "description", "amount", "currency", "account"})
+		for _, row := range rows {
+			w.Write([]string{row.ID, row.Type, row.Date.Format("2006-01-02"), row.Description, row.Amount.Decimal(), row.Amount.Currency, row.Account})
+		}
+		w.Flush()
+		return w.Error()
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+}
+
+func filterRowsByDate(rows []entryRow, from, to string) ([]entryRow, error) {
+	var start, end time.Time
+	var err error
+	if from != "" {
+		if start, err = parseDate(from); err != nil {
+			return nil, err
+		}
+	}
+	if to != "" {
+		if end, err = parseDate(to); err != nil {
+			return nil, err
+		}
+	}
+	var filtered []entryRow
+	for _, row := range rows {
+		if from != "" && row.Date.Before(start) {
+			continue
+		}
+		if to != "" && !row.Date.Before(end) {
+			continue
+		}
+		filtered = append(filtered, row)
+	}
+	return filtered, nil
+}
+
+func runReport(fm *FinanceManager, p *prompter, args []string, out io.Writer) error {
+	fs := newCommandFlags("report", out)
+	period := addReportRangeFlags(fs)
+	format := fs.String("format", "text", "Output format: "+reportFormats())
+	outFile := fs.String("out", "", "Write the report to this file instead of standard output")
+	account := fs.String("account", "", "Only report the entries of this account")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	r, err := period.reportRange()
+	if err != nil {
+		return err
+	}
+	r.Account = *account
+	if *outFile != "" {
+		return fm.writeReportFile(*outFile, r, *format)
+	}
+	return fm.WriteReport(out, r, *format)
+}
+
+func runChart(fm *FinanceManager, p *prompter, args []string, out io.Writer) error {
+	fs := newCommandFlags("chart", out)
+	period := addReportRangeFlags(fs)
+	kindStr := fs.String("type", string(ChartIncomeExpense), "Chart: income-expense, categories or investments")
+	format := fs.String("format", "text", "Output format: text, ascii or svg")
+	outFile := fs.String("out", "", "Write the chart to this file instead of standard output")
+	account := fs.String("account", "", "Only chart the entries of this account")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	kind, err := parseChartKind(*kindStr)
+	if err != nil {
+		return err
+	}
+	r, err := period.reportRange()
+	if err != nil {
+		return err
+	}
+	r.Account = *account
+	if *outFile != "" {
+		var buf bytes.Buffer
+		if err := fm.WriteChart(&buf, r, kind, *format); err != nil {
+			return err
+		}
+		return writeFileAtomic(*outFile, buf.Bytes(), 0644)
+	}
+	return fm.WriteChart(out, r, kind, *format)
+}
+
+type reportRangeFlags struct {
+	fs      *flag.FlagSet
+	year    *int
+	month   *int
+	quarter *int
+	from    *string
+	to      *string
+}
+
+func addReportRangeFlags(fs *flag.FlagSet) *reportRangeFlags {
+	now := time.Now()
+	return &reportRangeFlags{
+		fs:      fs,
+		year:    fs.Int("year", now.Year(), "Report year"),
+		month:   fs.Int("month", int(now.Month()), "Report month (1-12)"),
+		quarter: fs.Int("quarter", 0, "Report quarter (1-4)"),
+		from:    fs.String("from", "", "Start of a custom range (YYYY-MM-DD), inclusive"),
+		to:      fs.String("to", "", "End of a custom range (YYYY-MM-DD), exclusive"),
+	}
+}
+
+// reportRange picks the period from -from/-to, -quarter, -month or -year
+// alone, in that order; without any of them it is the current month.
+func (f *reportRangeFlags) reportRange() (ReportRange, error) {
+	set := make(map[string]bool)
+	f.fs.Visit(func(fl *flag.Flag) { set[fl.Name] = true })
+
+	switch {
+	case set["from"] || set["to"]:
+		if *f.from == "" || *f.to == "" {
+			return ReportRange{}, errors.New("-from and -to must be given together")
+		}
+		start, err := parseDate(*f.from)
+		if err != nil {
+			return ReportRange{}, err
+		}
+		end, err := parseDate(*f.to)
+		if err != nil {
+			return ReportRange{}, err
+		}
+		return CustomRange(start, end)
+	case set["quarter"]:
+		return QuarterRange(*f.year, *f.quarter)
+	case set["year"] && !set["month"]:
+		return YearRange(*f.year), nil
+	}
+	if *f.month < 1 || *f.month > 12 {
+		return ReportRange{}, fmt.Errorf("invalid month %d", *f.month)
+	}
+	return MonthRange(*f.year, time.Month(*f.month)), nil
+}
+
+func runDelete(fm *FinanceManager, p *prompter, args []string, out io.Writer) error {
+	fs := newCommandFlags("delete", out)
+	id := fs.String("id", "", "ID of the entry to delete")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlag("id", *id); err != nil {
+		return err
+	}
+	if err := fm.Delete(*id); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(out, "deleted %s\n", *id)
+	return err
+}
+
+func runSetBudget(fm *FinanceManager, p *prompter, args []string, out io.Writer) error {
+	fs := newCommandFlags("set-budget", out)
+	category := fs.String("category", "", "Expense category, assigned by the category rules when omitted")
+	periodStr := fs.String("period", "monthly", "Budget period: monthly or yearly")
+	limitStr := fs.String("amount", "", "Budget limit, optionally followed by a currency code")
+	remove := fs.Bool("remove", false, "Remove the budget instead of setting it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlag("category", *category); err != nil {
+		return err
+	}
+	period, err := parseBudgetPeriod(*periodStr)
+	if err != nil {
+		return err
+	}
+	if *remove {
+		return fm.RemoveBudget(*category, period)
+	}
+	if err := requireFlag("amount", *limitStr); err != nil {
+		return err
+	}
+	limit, err := ParseMoney(*limitStr, fm.currency)
+	if err != nil {
+		return err
+	}
+	return fm.SetBudget(*category, period, limit)
+}
+
+func runBudgets(fm *FinanceManager, p *prompter, args []string, out io.Writer) error {
+	fs := newCommandFlags("budgets", out)
+	dateStr := fs.String("date", "", "Show progress as of this date (YYYY-MM-DD), defaults to today")
+	format := fs.String("format", "text", "Output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	asOf := time.Now()
+	if *dateStr != "" {
+		var err error
+		if asOf, err = parseDate(*dateStr); err != nil {
+			return err
+		}
+	}
+	statuses, err := fm.BudgetProgress(asOf)
+	if err != nil {
+		return err
+	}
+	switch *format {
+	case "text":
+		_, err = fmt.Fprint(out, formatBudgetStatuses(statuses))
+		return err
+	case "json":
+		return writeJSON(out, statuses)
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+}
+
+func runForecast(fm *FinanceManager, p *prompter, args []string, out io.Writer) error {
+	fs := newCommandFlags("forecast", out)
+	months := fs.Int("months", 3, "Number of months to project")
+	history := fs.Int("history", defaultForecastHistory, "Number of past months to average")
+	dateStr := fs.String("date", "", "Forecast from the end of this date (YYYY-MM-DD), defaults to today")
+	openingStr := fs.String("opening", "", "Starting balance, defaults to the account balances on that date")
+	format := fs.String("format", "text", "Output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	opts := ForecastOptions{Months: *months, History: *history}
+	if *dateStr != "" {
+		var err error
+		if opts.AsOf, err = parseDate(*dateStr); err != nil {
+			return err
+		}
+	}
+	if *openingStr != "" {
+		opening, err := ParseMoney(*openingStr, fm.currency)
+		if err != nil {
+			return err
+		}
+		opts.Opening = &opening
+	}
+	forecast, err := fm.Forecast(opts)
+	if err != nil {
+		return err
+	}
+	switch *format {
+	case "text":
+		formatForecast(out, forecast)
+		return nil
+	case "json":
+		return writeJSON(out, forecast)
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+}
+
+func runImport(fm *FinanceManager, p *prompter, args []string, out io.Writer) error {
+	fs := newCommandFlags("import", out)
+	file := fs.String("file", "", "Statement file to import")
+	format := fs.String("format", "", "Statement format: csv or ofx (default: by file extension)")
+	mappingFile := fs.String("mapping", "", "JSON file describing the CSV columns")
+	dryRun := fs.Bool("dry-run", false, "Preview the import without storing anything")
+	account := fs.String("account", "", "Account the statement belongs to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlag("file", *file); err != nil {
+		return err
+	}
+
+	mapping := DefaultCSVMapping()
+	if *mappingFile != "" {
+		var err error
+		if mapping, err = LoadCSVMapping(*mappingFile); err != nil {
+			return err
+		}
+	}
+	transactions, err := ReadStatement(*file, *format, mapping, fm.currency)
+	if err != nil {
+		return err
+	}
+
+	plan := fm.PlanImport(transactions)
+	plan.Account = *account
+	formatImportPlan(out, plan)
+	if *dryRun {
+		return nil
+	}
+	added, err := fm.CommitImport(plan)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "imported %d entries\n", added)
+	return err
+}
+
+func runCategorize(fm *FinanceManager, p *prompter, args []string, out io.Writer) error {
+	fs := newCommandFlags("categorize", out)
+	rulesFile := fs.String("rules", "", "JSON file of categories and rules (default: the -rules flag of the app)")
+	overwrite := fs.Bool("all", false, "Re-assign every expense, not only those without a known category")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *rulesFile != "" {
+		rules, err := LoadCategoryRules(*rulesFile)
+		if err != nil {
+			return err
+		}
+		fm.SetCategoryRules(rules)
+	}
+	changed, err := fm.RecategorizeExpenses(*overwrite)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "recategorized %d expenses\n", changed)
+	return err
+}
+
+func runAddRecurring(fm *FinanceManager, p *prompter, args []string, out io.Writer) error {
+	fs := newCommandFlags("add-recurring", out)
+	kind := fs.String("type", "expense", "Series type: income or expense")
+	amountStr := fs.String("amount", "", "Amount, optionally followed by a currency code")
+	source := fs.String("source", "", "Income source")
+	category := fs.String("category", "", "Expense category, assigned by the category rules when omitted")
+	payee := fs.String("payee", "", "Who is paid")
+	frequency := fs.String("frequency", "monthly", "Frequency: daily, weekly, monthly or yearly")
+	interval := fs.Int("interval", 1, "Repeat every N periods")
+	lastBusinessDay := fs.Bool("last-business-day", false, "Monthly series: use the last weekday of each month")
+	startStr := fs.String("start", "", "First occurrence (YYYY-MM-DD), defaults to today")
+	endStr := fs.String("end", "", "Last possible occurrence (YYYY-MM-DD)")
+	account := fs.String("account", "", "Account the entries belong to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	t := RecurringTemplate{
+		Type:     *kind,
+		Source:   *source,
+		Category: *category,
+		Payee:    *payee,
+		Account:  *account,
+		Rule:     RecurrenceRule{Interval: *interval, LastBusinessDay: *lastBusinessDay},
+		Start:    time.Now(),
+	}
+	var err error
+	if t.Rule.Frequency, err = parseRecurrenceFrequency(*frequency); err != nil {
+		return err
+	}
+	if err := requireFlag("amount", *amountStr); err != nil {
+		return err
+	}
+	if t.Amount, err = ParseMoney(*amountStr, fm.currency); err != nil {
+		return err
+	}
+	if *startStr != "" {
+		if t.Start, err = parseDate(*startStr); err != nil {
+			return err
+		}
+	}
+	if *endStr != "" {
+		if t.End, err = parseDate(*endStr); err != nil {
+			return err
+		}
+	}
+	if t.Type == "income" {
+		err = requireFlag("source", t.Source)
+	} else if t.Category == "" && t.Payee == "" {
+		err = errors.New("-category or -payee is required")
+	}
+	if err != nil {
+		return err
+	}
+
+	created, err := fm.CreateRecurring(t)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(out, created.ID)
+	return err
+}
+
+func runListRecurring(fm *FinanceManager, p *prompter, args []string, out io.Writer) error {
+	fs := newCommandFlags("list-recurring", out)
+	format := fs.String("format", "text", "Output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	series := fm.ListRecurring()
+	switch *format {
+	case "text":
+		for _, t := range series {
+			description := t.Source
+			if t.Type == "expense" {
+				description = strings.TrimSpace(t.Category + " " + t.Payee)
+			}
+			end := "open-ended"
+			if !t.End.IsZero() {
+				end = "until " + t.End.Format("2006-01-02")
+			}
+			fmt.Fprintf(out, "%-8s %-8s %-20s %s %s from %s, %s\n", t.ID, t.Type, description, t.Amount,
+				t.Rule.Frequency, t.Start.Format("2006-01-02"), end)
+		}
+		return nil
+	case "json":
+		return writeJSON(out, series)
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+}
+
+func runEndRecurring(fm *FinanceManager, p *prompter, args []string, out io.Writer) error {
+	fs := newCommandFlags("end-recurring", out)
+	id := fs.String("id", "", "ID of the series")
+	endStr := fs.String("date", "", "Last possible occurrence (YYYY-MM-DD), defaults to today")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlag("id", *id); err != nil {
+		return err
+	}
+	end := time.Now()
+	if *endStr != "" {
+		var err error
+		if end, err = parseDate(*endStr); err != nil {
+			return err
+		}
+	}
+	return fm.EndRecurring(*id, end)
+}
+
+func runMaterialize(fm *FinanceManager, p *prompter, args []string, out io.Writer) error {
+	fs := newCommandFlags("materialize", out)
+	throughStr := fs.String("through", "", "Create entries due up to this date (YYYY-MM-DD), defaults to today")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	through := time.Now()
+	if *throughStr != "" {
+		var err error
+		if through, err = parseDate(*throughStr); err != nil {
+			return err
+		}
+	}
+	created, err := fm.MaterializeRecurring(through)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "created %d entries\n", created)
+	return err
+}
+
+func runHoldings(fm *FinanceManager, p *prompter, args []string, out io.Writer) error {
+	fs := newCommandFlags("holdings", out)
+	dateStr := fs.String("date", "", "Holdings at the end of this date (YYYY-MM-DD), defaults to all trades")
+	format := fs.String("format", "text", "Output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var end time.Time
+	if *dateStr != "" {
+		date, err := parseDate(*dateStr)
+		if err != nil {
+			return err
+		}
+		end = date.AddDate(0, 0, 1)
+	}
+	holdings, err := fm.Holdings(end)
+	if err != nil {
+		return err
+	}
+	switch *format {
+	case "text":
+		formatHoldings(out, holdings)
+		return nil
+	case "json":
+		return writeJSON(out, holdings)
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+}
+
+func runGains(fm *FinanceManager, p *prompter, args []string, out io.Writer) error {
+	fs := newCommandFlags("gains", out)
+	rangeFlags := addReportRangeFlags(fs)
+	format := fs.String("format", "text", "Output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	r, err := rangeFlags.reportRange()
+	if err != nil {
+		return err
+	}
+	gains, err := fm.RealizedGains(r.Start, r.End)
+	if err != nil {
+		return err
+	}
+	switch *format {
+	case "text":
+		for _, gain := range gains {
+			fmt.Fprintf(out, "%s  %-20s %14s  proceeds %-14s cost %-14s gain %s\n", gain.Date.Format("2006-01-02"),
+				gain.Asset, gain.Quantity, gain.Proceeds, gain.CostBasis, gain.Gain)
+		}
+		return nil
+	case "json":
+		return writeJSON(out, gains)
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+}
+
+func runAddPrice(fm *FinanceManager, p *prompter, args []string, out io.Writer) error {
+	fs := newCommandFlags("add-price", out)
+	entry := addEntryFlags(fs, "price")
+	asset := fs.String("asset", "", "Asset name")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	date, price, err := entry.parse(fm, "price")
+	if err != nil {
+		return err
+	}
+	if err := requireFlag("asset", *asset); err != nil {
+		return err
+	}
+	return fm.AddPrice(date, *asset, price)
+}
+
+func runImportPrices(fm *FinanceManager, p *prompter, args []string, out io.Writer) error {
+	fs := newCommandFlags("import-prices", out)
+	file := fs.String("file", "", "CSV file of prices")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlag("file", *file); err != nil {
+		return err
+	}
+	prices, err := ReadPriceFile(*file, fm.currency)
+	if err != nil {
+		return err
+	}
+	changed, err := fm.AddPrices(prices)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "%d prices read, %d new or changed\n", len(prices), changed)
+	return err
+}
+
+func runValuation(fm *FinanceManager, p *prompter, args []string, out io.Writer) error {
+	fs := newCommandFlags("valuation", out)
+	dateStr := fs.String("date", "", "Value holdings at the end of this date (YYYY-MM-DD), defaults to now")
+	format := fs.String("format", "text", "Output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	asOf := time.Now()
+	if *dateStr != "" {
+		date, err := parseDate(*dateStr)
+		if err != nil {
+			return err
+		}
+		asOf = date.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	valuation, err := fm.ValueAt(asOf)
+	if err != nil {
+		return err
+	}
+	switch *format {
+	case "text":
+		formatHoldings(out, valuation.Holdings)
+		_, err = fmt.Fprintf(out, "Total value %s, cost %s, unrealized gain %s\n",
+			valuation.MarketValue, valuation.CostBasis, valuation.UnrealizedGain)
+		return err
+	case "json":
+		return writeJSON(out, valuation)
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+}
+
+func runAnalytics(fm *FinanceManager, p *prompter, args []string, out io.Writer) error {
+	fs := newCommandFlags("analytics", out)
+	rangeFlags := addReportRangeFlags(fs)
+	format := fs.String("format", "text", "Output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	r, err := rangeFlags.reportRange()
+	if err != nil {
+		return err
+	}
+	analytics, err := fm.InvestmentAnalytics(r)
+	if err != nil {
+		return err
+	}
+	switch *format {
+	case "text":
+		_, err = io.WriteString(out, formatInvestmentAnalytics(analytics))
+		return err
+	case "json":
+		return writeJSON(out, analytics)
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+}
+
+func runTransfer(fm *FinanceManager, p *prompter, args []string, out io.Writer) error {
+	fs := newCommandFlags("transfer", out)
+	entry := addEntryFlags(fs, "amount")
+	from := fs.String("from", cashAccount, "Account the money leaves")
+	to := fs.String("to", "", "Account the money goes to, e.g. Assets:Brokerage")
+	description := fs.String("description", "Transfer", "Description")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	date, amount, err := entry.parse(fm, "amount")
+	if err != nil {
+		return err
+	}
+	if err := requireFlag("to", *to); err != nil {
+		return err
+	}
+	txn, err := fm.Transfer(date, *from, *to, amount, *description)
+	if err != nil {
+		return err
+	}
+	return writeCreated(out, *entry.format, txn.ID, txn)
+}
+
+func runLedger(fm *FinanceManager, p *prompter, args []string, out io.Writer) error {
+	fs := newCommandFlags("ledger", out)
+	format := fs.String("format", "text", "Output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	txns, err := fm.LedgerTransactions()
+	if err != nil {
+		return err
+	}
+	switch *format {
+	case "text":
+		formatLedger(out, txns)
+		return nil
+	case "json":
+		return writeJSON(out, txns)
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+}
+
+func runTrialBalance(fm *FinanceManager, p *prompter, args []string, out io.Writer) error {
+	fs := newCommandFlags("trial-balance", out)
+	format := fs.String("format", "text", "Output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	tb, err := fm.TrialBalance()
+	if err != nil {
+		return err
+	}
+	switch *format {
+	case "text":
+		formatTrialBalance(out, tb)
+		return nil
+	case "json":
+		return writeJSON(out, tb)
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+}
+
+func runAddAccount(fm *FinanceManager, p *prompter, args []string, out io.Writer) error {
+	fs := newCommandFlags("add-account", out)
+	name := fs.String("name", "", "Account name, e.g. Checking")
+	kindStr := fs.String("kind", "checking", "Kind: checking, savings, credit_card, broker or cash")
+	openingStr := fs.String("opening", "0", "Opening balance, optionally followed by a currency code")
+	dateStr := fs.String("date", "", "Date the account was opened (YYYY-MM-DD), defaults to today")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlag("name", *name); err != nil {
+		return err
+	}
+	kind, err := parseAccountKind(*kindStr)
+	if err != nil {
+		return err
+	}
+	account := Account{Name: *name, Kind: kind, Opened: time.Now()}
+	if account.OpeningBalance, err = ParseMoney(*openingStr, fm.currency); err != nil {
+		return err
+	}
+	if *dateStr != "" {
+		if account.Opened, err = parseDate(*dateStr); err != nil {
+			return err
+		}
+	}
+	return fm.AddAccount(account)
+}
+
+func runAccounts(fm *FinanceManager, p *prompter, args []string, out io.Writer) error {
+	fs := newCommandFlags("accounts", out)
+	dateStr := fs.String("date", "", "Balances at the end of this date (YYYY-MM-DD), defaults to now")
+	format := fs.String("format", "text", "Output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	asOf := time.Now()
+	if *dateStr != "" {
+		date, err := parseDate(*dateStr)
+		if err != nil {
+			return err
+		}
+		asOf = date.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+
+	type accountRow struct {
+		Account
+		Balance Money `json:"balance"`
+	}
+	var rows []accountRow
+	for _, account := range fm.ListAccounts() {
+		balance, err := fm.AccountBalance(account.Name, asOf)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, accountRow{account, balance})
+	}
+	switch *format {
+	case "text":
+		for _, row := range rows {
+			fmt.Fprintf(out, "%-20s %-12s %14s\n", row.Name, row.Kind, row.Balance)
+		}
+		return nil
+	case "json":
+		if rows == nil {
+			rows = []accountRow{}
+		}
+		return writeJSON(out, rows)
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+}
+
+func runRegister(fm *FinanceManager, p *prompter, args []string, out io.Writer) error {
+	fs := newCommandFlags("register", out)
+	account := fs.String("account", "", "Account name")
+	from := fs.String("from", "", "Only movements on or after this date (YYYY-MM-DD)")
+	to := fs.String("to", "", "Only movements before this date (YYYY-MM-DD)")
+	format := fs.String("format", "text", "Output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if err := requireFlag("account", *account); err != nil {
+		return err
+	}
+	var start, end time.Time
+	var err error
+	if *from != "" {
+		if start, err = parseDate(*from); err != nil {
+			return err
+		}
+	}
+	if *to != "" {
+		if end, err = parseDate(*to); err != nil {
+			return err
+		}
+	}
+	register, err := fm.AccountRegister(*account, start, end)
+	if err != nil {
+		return err
+	}
+	switch *format {
+	case "text":
+		formatRegister(out, register)
+		return nil
+	case "json":
+		return writeJSON(out, register)
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+}
+
+func runSearch(fm *FinanceManager, p *prompter, args []string, out io.Writer) error {
+	fs := newCommandFlags("search", out)
+	text := fs.String("text", "", "Words that must all appear in the description, payee, notes or tags")
+	tags := fs.String("tag", "", "Comma separated tags the entries must all carry")
+	kind := fs.String("type", "all", "Entry type: all, income, expense or investment")
+	account := fs.String("account", "", "Only entries of this account")
+	minStr := fs.String("min", "", "Smallest amount, in the reporting currency")
+	maxStr := fs.String("max", "", "Largest amount, in the reporting currency")
+	from := fs.String("from", "", "Only entries on or after this date (YYYY-MM-DD)")
+	to := fs.String("to", "", "Only entries before this date (YYYY-MM-DD)")
+	order := fs.String("sort", "date", "Sort by date, amount, description or type; prefix with - to reverse")
+	offset := fs.Int("offset", 0, "Skip this many matches")
+	limit := fs.Int("limit", 0, "Show at most this many matches (0 for all)")
+	format := fs.String("format", "text", "Output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	q := EntryQuery{Text: *text, Tags: splitTags(*tags), Type: *kind, Account: *account, Sort: *order, Offset: *offset, Limit: *limit}
+	for _, bound := range []struct {
+		value  string
+		target **Money
+	}{{*minStr, &q.MinAmount}, {*maxStr, &q.MaxAmount}} {
+		if bound.value == "" {
+			continue
+		}
+		amount, err := ParseMoney(bound.value, fm.currency)
+		if err != nil {
+			return err
+		}
+		*bound.target = &amount
+	}
+	var err error
+	if *from != "" {
+		if q.Start, err = parseDate(*from); err != nil {
+			return err
+		}
+	}
+	if *to != "" {
+		if q.End, err = parseDate(*to); err != nil {
+			return err
+		}
+	}
+
+	result, err := fm.Search(q)
+	if err != nil {
+		return err
+	}
+	switch *format {
+	case "text":
+		for _, row := range result.Entries {
+			fmt.Fprintf(out, "%-8s %-10s %s  %-20s %14s  %s\n", row.ID, row.Type, row.Date.Format("2006-01-02"), row.Description,
+				row.Amount, strings.Join(row.Tags, ","))
+		}
+		_, err = fmt.Fprintf(out, "%d of %d matches\n", len(result.Entries), result.Total)
+		return err
+	case "json":
+		return writeJSON(out, result)
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+}
+
+func runHistory(fm *FinanceManager, p *prompter, args []string, out io.Writer) error {
+	fs := newCommandFlags("history", out)
+	limit := fs.Int("limit", 20, "Show this many recent events (0 for all)")
+	format := fs.String("format", "text", "Output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	history := fm.History()
+	if *limit > 0 && len(history) > *limit {
+		history = history[len(history)-*limit:]
+	}
+	switch *format {
+	case "text":
+		formatHistory(out, history)
+		return nil
+	case "json":
+		if history == nil {
+			history = []AuditEvent{}
+		}
+		return writeJSON(out, history)
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+}
+
+func runServe(fm *FinanceManager, p *prompter, args []string, out io.Writer) error {
+	fs := newCommandFlags("serve", out)
+	addr := fs.String("addr", ":8080", "Address to listen on")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return serveFinanceAPI(fm, *addr, out)
+}
+
+func runChangePassword(fm *FinanceManager, p *prompter, args []string, out io.Writer) error {
+	fs := newCommandFlags("change-password", out)
+	passwordEnv := fs.String("password-env", "FINANCE_NEW_PASSWORD", "Environment variable holding the new password, asked for when unset")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	password := os.Getenv(*passwordEnv)
+	if password == "" {
+		var err error
+		if password, err = p.password("New password", true); err != nil {
+			return err
+		}
+	}
+	if err := fm.ChangePassword(password); err != nil {
+		return err
+	}
+	fmt.Fprintln(out, "Password changed")
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestFinanceCommands(t *testing.T) {
+	fm := NewFinanceManager()
+	var out bytes.Buffer
+
+	err := runFinanceCommand(fm, nil, []string{"add-income", "-date", "2024-03-01", "-amount", "5000", "-source", "Salary"}, &out)
+	if err != nil {
+		t.Fatalf("add-income failed: %v", err)
+	}
+	incomeID := strings.TrimSpace(out.String())
+
+	out.Reset()
+	err = runFinanceCommand(fm, nil, []string{"add-expense", "-date", "2024-03-02", "-amount", "950.50", "-category", "Rent"}, &out)
+	if err != nil {
+		t.Fatalf("add-expense failed: %v", err)
+	}
+
+	if err := runFinanceCommand(fm, nil, []string{"add-expense", "-amount", "10"}, &out); err == nil {
+		t.Error("Expected error for missing -category")
+	}
+
+	out.Reset()
+	if err := runFinanceCommand(fm, nil, []string{"list", "-format", "json"}, &out); err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	var rows []entryRow
+	if err := json.Unmarshal(out.Bytes(), &rows); err != nil {
+		t.Fatalf("list output is not JSON: %v\n%s", err, out.String())
+	}
+	if len(rows) != 2 || rows[0].ID != incomeID || rows[1].Amount != usd("950.50") {
+		t.Errorf("Unexpected rows %+v", rows)
+	}
+
+	out.Reset()
+	if err := runFinanceCommand(fm, nil, []string{"report", "-year", "2024", "-month", "3"}, &out); err != nil {
+		t.Fatalf("report failed: %v", err)
+	}
+	if !strings.Contains(out.String(), "$5000.00") {
+		t.Errorf("Expected report to include the income, got:\n%s", out.String())
+	}
+
+	if err := runFinanceCommand(fm, nil, []string{"delete", "-id", incomeID}, &out); err != nil {
+		t.Errorf("delete failed: %v", err)
+	}
+	if len(fm.ListIncomes()) != 0 {
+		t.Error("Expected income to be deleted")
+	}
+
+	if err := runFinanceCommand(fm, nil, []string{"bogus"}, &out); err == nil {
+		t.Error("Expected error for unknown command")
+	}
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+)
+
+type ChartKind string
+
+const (
+	ChartIncomeExpense ChartKind = "income-expense"
+	ChartCategories    ChartKind = "categories"
+	ChartInvestments   ChartKind = "investments"
+)
+
+func parseChartKind(value string) (ChartKind, error) {
+	switch kind := ChartKind(strings.ToLower(value)); kind {
+	case ChartIncomeExpense, ChartCategories, ChartInvestments:
+		return kind, nil
+	}
+	return "", fmt.Errorf("invalid chart %q, expected income-expense, categories or investments", value)
+}
+
+// ChartSeries is one named set of values, one per chart label.
+type ChartSeries struct {
+	Name   string
+	Color  string
+	Values []Money
+}
+
+// Chart is report data ready to draw: a group of bars per label with one
+// bar per series, or a line per series when Line is set.
+type Chart struct {
+	Title    string
+	Currency string
+	Labels   []string
+	Series   []ChartSeries
+	Line     bool
+}
+
+// chartWidth is the length in characters of the longest bar in text charts.
+const chartWidth = 40
+
+// maxChartCategories bounds the bars of a category chart; smaller
+// categories are combined into one bar.
+const maxChartCategories = 10
+
+// BuildChart picks the figures of one chart out of a report.
+func BuildChart(report *PeriodReport, kind ChartKind) (*Chart, error) {
+	chart := &Chart{Currency: report.Currency}
+	switch kind {
+	case ChartIncomeExpense:
+		chart.Title = "Income and expenses, " + report.Label
+		income := ChartSeries{Name: "Income", Color: "#2e7d32"}
+		expenses := ChartSeries{Name: "Expenses", Color: "#c62828"}
+		for _, month := range report.Months {
+			chart.Labels = append(chart.Labels, chartMonth(month))
+			income.Values = append(income.Values, month.Income)
+			expenses.Values = append(expenses.Values, month.Expenses)
+		}
+		chart.Series = []ChartSeries{income, expenses}
+	case ChartCategories:
+		chart.Title = "Expenses by category, " + report.Label
+		expenses := ChartSeries{Name: "Expenses", Color: "#ef6c00"}
+		totals := report.ExpensesByCategory
+		if len(totals) > maxChartCategories {
+			other := CategoryTotal{Category: "Other", Amount: Money{Currency: report.Currency}}
+			for _, total := range totals[maxChartCategories-1:] {
+				var err error
+				if other.Amount, err = other.Amount.Add(total.Amount); err != nil {
+					return nil, err
+				}
+			}
+			totals = append(totals[:maxChartCategories-1:maxChartCategories-1], other)
+		}
+		for _, total := range totals {
+			chart.Labels = append(chart.Labels, total.Category)
+			expenses.Values = append(expenses.Values, total.Amount)
+		}
+		chart.Series = []ChartSeries{expenses}
+	case ChartInvestments:
+		chart.Title = "Investment value, " + report.Label
+		chart.Line = true
+		value := ChartSeries{Name: "Market value", Color: "#1565c0"}
+		for _, month := range report.Months {
+			chart.Labels = append(chart.Labels, chartMonth(month))
+			value.Values = append(value.Values, month.InvestmentValue)
+		}
+		chart.Series = []ChartSeries{value}
+	default:
+		return nil, fmt.Errorf("invalid chart %q", kind)
+	}
+	return chart, nil
+}
+
+func chartMonth(month MonthTotals) string {
+	return fmt.Sprintf("%.3s %d", month.Month, month.Year)
+}
+
+// max returns the largest value of any series, at least zero.
+func (c *Chart) max() int64 {
+	var max int64
+	for _, s := range c.Series {
+		for _, v := range s.Values {
+			if v.Minor > max {
+				max = v.Minor
+			}
+		}
+	}
+	return max
+}
+
+// WriteChart draws a chart of the report for r as text, ascii or svg.
+func (fm *FinanceManager) WriteChart(w io.Writer, r ReportRange, kind ChartKind, format string) error {
+	fm = fm.view()
+	var render func(io.Writer, *Chart) error
+	switch format {
+	case "text":
+		render = func(w io.Writer, c *Chart) error { return renderChartText(w, c, false) }
+	case "ascii":
+		render = func(w io.Writer, c *Chart) error { return renderChartText(w, c, true) }
+	case "svg":
+		render = renderChartSVG
+	default:
+		return fmt.Errorf("unknown chart format %q (available: ascii, svg, text)", format)
+	}
+	report, err := fm.BuildReport(r)
+	if err != nil {
+		return err
+	}
+	chart, err := BuildChart(report, kind)
+	if err != nil {
+		return err
+	}
+	return render(w, chart)
+}
+
+// barBlocks are the partial Unicode blocks for the last cell of a bar, in
+// eighths of a cell.
+var barBlocks = []string{"", "▏", "▎", "▍", "▌", "▋", "▊", "▉"}
+
+// asciiBars tell the series apart when only ASCII is available.
+var asciiBars = []string{"#", "=", "*", "+"}
+
+func textBar(value, max int64, series int, ascii bool) string {
+	if value <= 0 || max <= 0 {
+		return ""
+	}
+	eighths := (value*chartWidth*8 + max/2) / max
+	if ascii {
+		return strings.Repeat(asciiBars[series%len(asciiBars)], int((eighths+4)/8))
+	}
+	return strings.Repeat("█", int(eighths/8)) + barBlocks[eighths%8]
+}
+
+// renderChartText draws one horizontal bar per label and series, scaled
+// to the largest value.
+func renderChartText(w io.Writer, chart *Chart, ascii bool) error {
+	var b strings.Builder
+	fmt.Fprintln(&b, chart.Title)
+	if len(chart.Labels) == 0 {
+		b.WriteString("No data\n")
+		_, err := io.WriteString(w, b.String())
+		return err
+	}
+
+	labelWidth, nameWidth := 0, 0
+	for _, label := range chart.Labels {
+		labelWidth = max(labelWidth, len([]rune(label)))
+	}
+	for _, s := range chart.Series {
+		nameWidth = max(nameWidth, len([]rune(s.Name)))
+	}
+	top := chart.max()
+	for i, label := range chart.Labels {
+		for j, s := range chart.Series {
+			if j > 0 {
+				label = ""
+			}
+			fmt.Fprintf(&b, "%-*s ", labelWidth, label)
+			if len(chart.Series) > 1 {
+				fmt.Fprintf(&b, "%-*s ", nameWidth, s.Name)
+			}
+			fmt.Fprintf(&b, "%s %s\n", textBar(s.Values[i].Minor, top, j, ascii), s.Values[i])
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Layout of SVG charts, in pixels.
+const (
+	svgWidth  = 720
+	svgHeight = 400
+	svgLeft   = 90
+	svgRight  = 20
+	svgTop    = 50
+	svgBottom = 100
+)
+
+// chartStep rounds a quarter of the largest value up to 1, 2, 2.5 or 5
+// times a power of ten, so the grid lines fall on round amounts.
+func chartStep(max int64) float64 {
+	raw := float64(max) / 4
+	if raw < 1 {
+		return 1
+	}
+	scale := math.Pow(10, math.Floor(math.Log10(raw)))
+	for _, m := range []float64{1, 2, 2.5, 5, 10} {
+		if raw <= m*scale {
+			return m * scale
+		}
+	}
+	return 10 * scale
+}
+
+func svgEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// renderChartSVG writes a standalone SVG document with a grid, axis labels
+// and a legend. Each bar and point carries its amount as a tooltip.
+func renderChartSVG(w io.Writer, chart *Chart) error {
+	var b bytes.Buffer
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif" font-size="12">`+"\n",
+		svgWidth, svgHeight, svgWidth, svgHeight)
+	b.WriteString(`<rect width="100%" height="100%" fill="white"/>` + "\n")
+	fmt.Fprintf(&b, `<text x="%d" y="28" text-anchor="middle" font-size="16" font-weight="bold">%s</text>`+"\n", svgWidth/2, svgEscape(chart.Title))
+
+	plotWidth := float64(svgWidth - svgLeft - svgRight)
+	plotHeight := float64(svgHeight - svgTop - svgBottom)
+	baseline := float64(svgTop) + plotHeight
+	step := chartStep(chart.max())
+	top := 4 * step
+	y := func(minor int64) float64 {
+		return baseline - plotHeight*math.Max(0, float64(minor))/top
+	}
+
+	for k := 0; k <= 4; k++ {
+		value := int64(math.Round(step * float64(k)))
+		gy := y(value)
+		fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#dddddd"/>`+"\n", svgLeft, gy, svgWidth-svgRight, gy)
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" text-anchor="end">%s</text>`+"\n", svgLeft-6, gy+4,
+			svgEscape(Money{Minor: value, Currency: chart.Currency}.String()))
+	}
+	fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#333333"/>`+"\n", svgLeft, baseline, svgWidth-svgRight, baseline)
+
+	if len(chart.Labels) == 0 {
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" text-anchor="middle">No data</text>`+"\n", svgWidth/2, baseline-plotHeight/2)
+	}
+	slot := plotWidth / math.Max(1, float64(len(chart.Labels)))
+	center := func(i int) float64 { return float64(svgLeft) + slot*(float64(i)+0.5) }
+	for i, label := range chart.Labels {
+		lx, ly := center(i), baseline+16
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" text-anchor="end" transform="rotate(-40 %.1f %.1f)">%s</text>`+"\n",
+			lx, ly, lx, ly, svgEscape(label))
+	}
+
+	for j, s := range chart.Series {
+		if chart.Line {
+			points := make([]string, len(s.Values))
+			for i, v := range s.Values {
+				points[i] = fmt.Sprintf("%.1f,%.1f", center(i), y(v.Minor))
+			}
+			fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="2"/>`+"\n", strings.Join(points, " "), s.Color)
+			for i, v := range s.Values {
+				fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="3" fill="%s"><title>%s: %s</title></circle>`+"\n",
+					center(i), y(v.Minor), s.Color, svgEscape(chart.Labels[i]), svgEscape(v.String()))
+			}
+			continue
+		}
+		group := slot * 0.8
+		width := group / float64(len(chart.Series))
+		for i, v := range s.Values {
+			x := center(i) - group/2 + width*float64(j)
+			top := y(v.Minor)
+			fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s %s: %s</title></rect>`+"\n",
+				x, top, width, baseline-top, s.Color, svgEscape(chart.Labels[i]), svgEscape(s.Name), svgEscape(v.String()))
+		}
+	}
+
+	for j, s := range chart.Series {
+		lx := svgLeft + 140*j
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="10" height="10" fill="%s"/>`+"\n", lx, svgHeight-22, s.Color)
+		fmt.Fprintf(&b, `<text x="%d" y="%d">%s</text>`+"\n", lx+16, svgHeight-13, svgEscape(s.Name))
+	}
+	b.WriteString("</svg>\n")
+	_, err := w.Write(b.Bytes())
+	return err
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func chartFixture(t *testing.T) *FinanceManager {
+	t.Helper()
+	fm := NewFinanceManager()
+	fm.AddIncome(date(2024, 1, 1), "Salary", usd("4000.00"))
+	fm.AddIncome(date(2024, 2, 1), "Salary", usd("4000.00"))
+	fm.AddExpense(date(2024, 1, 3), "Rent", usd("2000.00"))
+	fm.AddExpense(date(2024, 2, 9), "R&D <lab>", usd("500.00"))
+	fm.AddExpense(date(2024, 3, 3), "Rent", usd("2000.00"))
+	if _, err := fm.BuyInvestment(date(2024, 1, 5), "ACME", 10*oneUnit, usd("10.00")); err != nil {
+		t.Fatalf("Failed to buy: %v", err)
+	}
+	fm.AddPrice(date(2024, 2, 1), "ACME", usd("12.00"))
+	fm.AddPrice(date(2024, 3, 1), "ACME", usd("15.00"))
+	return fm
+}
+
+func TestTextChart(t *testing.T) {
+	fm := chartFixture(t)
+	q1, _ := QuarterRange(2024, 1)
+
+	var out bytes.Buffer
+	if err := fm.WriteChart(&out, q1, ChartIncomeExpense, "text"); err != nil {
+		t.Fatalf("Failed to chart: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 7 || lines[0] != "Income and expenses, Q1 2024" {
+		t.Fatalf("Expected a title and two bars per month, got:\n%s", out.String())
+	}
+	if want := "Jan 2024 Income   " + strings.Repeat("█", chartWidth) + " $4000.00"; lines[1] != want {
+		t.Errorf("Expected the largest bar to fill the width:\n%q\ngot\n%q", want, lines[1])
+	}
+	if want := "         Expenses " + strings.Repeat("█", chartWidth/2) + " $2000.00"; lines[2] != want {
+		t.Errorf("Expected a half-width expense bar:\n%q\ngot\n%q", want, lines[2])
+	}
+	if !strings.HasSuffix(lines[4], "Expenses █████ $500.00") {
+		t.Errorf("Expected an eighth-width bar, got %q", lines[4])
+	}
+	if bar := textBar(1, 16, 0, false); bar != "██▌" {
+		t.Errorf("Expected a partial block at the end of a bar, got %q", bar)
+	}
+
+	out.Reset()
+	if err := fm.WriteChart(&out, q1, ChartIncomeExpense, "ascii"); err != nil {
+		t.Fatalf("Failed to chart: %v", err)
+	}
+	if !strings.Contains(out.String(), strings.Repeat("#", chartWidth)+" $4000.00") ||
+		!strings.Contains(out.String(), strings.Repeat("=", chartWidth/2)+" $2000.00") ||
+		strings.ContainsRune(out.String(), '█') {
+		t.Errorf("Expected ASCII bars, got:\n%s", out.String())
+	}
+
+	if err := fm.WriteChart(&out, q1, ChartIncomeExpense, "png"); err == nil {
+		t.Error("Expected error for an unknown chart format")
+	}
+}
+
+func TestInvestmentChartFollowsMonthEnds(t *testing.T) {
+	fm := chartFixture(t)
+	q1, _ := QuarterRange(2024, 1)
+	report, err := fm.BuildReport(q1)
+	if err != nil {
+		t.Fatalf("Failed to build report: %v", err)
+	}
+	chart, err := BuildChart(report, ChartInvestments)
+	if err != nil {
+		t.Fatalf("Failed to build chart: %v", err)
+	}
+	want := []string{"100.00", "120.00", "150.00"}
+	values := chart.Series[0].Values
+	for i := range want {
+		if values[i] != usd(want[i]) {
+			t.Errorf("%s: expected value $%s, got %s", chart.Labels[i], want[i], values[i])
+		}
+	}
+}
+
+// svgElements parses doc as XML and counts its elements by name.
+func svgElements(t *testing.T, doc []byte) map[string]int {
+	t.Helper()
+	counts := make(map[string]int)
+	decoder := xml.NewDecoder(bytes.NewReader(doc))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return counts
+		}
+		if err != nil {
+			t.Fatalf("SVG is not well-formed XML: %v\n%s", err, doc)
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			counts[start.Name.Local]++
+		}
+	}
+}
+
+func TestSVGChart(t *testing.T) {
+	fm := chartFixture(t)
+	q1, _ := QuarterRange(2024, 1)
+
+	var out bytes.Buffer
+	if err := fm.WriteChart(&out, q1, ChartCategories, "svg"); err != nil {
+		t.Fatalf("Failed to chart: %v", err)
+	}
+	elements := svgElements(t, out.Bytes())
+	// Background, two category bars and one legend swatch.
+	if elements["svg"] != 1 || elements["rect"] != 4 || elements["title"] != 2 {
+		t.Errorf("Unexpected SVG elements %v", elements)
+	}
+	if !strings.Contains(out.String(), "R&amp;D &lt;lab&gt;") {
+		t.Errorf("Expected the category name to be escaped, got:\n%s", out.String())
+	}
+
+	path := filepath.Join(t.TempDir(), "value.svg")
+	err := runFinanceCommand(fm, nil, []string{"chart", "-type", "investments", "-format", "svg", "-quarter", "1", "-year", "2024", "-out", path}, &out)
+	if err != nil {
+		t.Fatalf("chart failed: %v", err)
+	}
+	doc, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read chart: %v", err)
+	}
+	if elements := svgElements(t, doc); elements["polyline"] != 1 || elements["circle"] != 3 {
+		t.Errorf("Expected a line through three months, got %v", elements)
+	}
+}
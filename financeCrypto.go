@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+)
+
+var (
+	// ErrWrongPassword means the data file is intact but was encrypted
+	// with another password.
+	ErrWrongPassword = errors.New("wrong password")
+	// ErrCorruptData means the data file was damaged or tampered with.
+	ErrCorruptData = errors.New("data file is corrupted")
+)
+
+// Encrypted files start with encryptedMagic and a version byte, followed
+// by the key derivation parameters, the nonce and a key check value. The
+// header is authenticated along with the AES-256-GCM ciphertext, and a
+// CRC-32 of everything before it closes the file.
+const (
+	encryptedMagic   = "FMENC"
+	encryptedVersion = 1
+	saltSize         = 16
+	keyCheckSize     = 16
+	encryptedHeader  = len(encryptedMagic) + 1 + 4 + saltSize + 12 + keyCheckSize
+)
+
+// defaultKDFIterations is the PBKDF2-HMAC-SHA256 work factor for new keys;
+// maxKDFIterations bounds what a damaged header can ask for.
+const (
+	defaultKDFIterations = 600000
+	maxKDFIterations     = 10000000
+)
+
+// pbkdf2SHA256 derives a keyLen byte key from password as in RFC 8018.
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	var key []byte
+	var counter [4]byte
+	for block := uint32(1); len(key) < keyLen; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(counter[:], block)
+		prf.Write(counter[:])
+		u := prf.Sum(nil)
+		t := append([]byte(nil), u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
+
+// keyCheck lets Load tell a wrong password apart from damaged ciphertext.
+func keyCheck(key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("finance key check"))
+	return mac.Sum(nil)[:keyCheckSize]
+}
+
+// isEncryptedFile reports whether path holds data written by an
+// EncryptedFileStore.
+func isEncryptedFile(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	magic := make([]byte, len(encryptedMagic))
+	if _, err := f.Read(magic); err != nil {
+		return false
+	}
+	return string(magic) == encryptedMagic
+}
+
+// EncryptedFileStore keeps the state as a single JSON document sealed with
+// a key derived from a password. A file that is not encrypted yet is read
+// as plain JSON and encrypted by the next Save.
+type EncryptedFileStore struct {
+	path       string
+	password   []byte
+	iterations int
+	salt       []byte
+	key        []byte
+	sealed     bool
+}
+
+func NewEncryptedFileStore(path, password string) *EncryptedFileStore {
+	return &EncryptedFileStore{path: path, password: []byte(password), iterations: defaultKDFIterations}
+}
+
+// encrypted reports whether the file was encrypted when it was last read
+// or written.
+func (s *EncryptedFileStore) encrypted() bool {
+	return s.sealed
+}
+
+func (s *EncryptedFileStore) Load() (*FinanceData, error) {
+	content, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &FinanceData{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	plain := content
+	if bytes.HasPrefix(content, []byte(encryptedMagic)) {
+		if plain, err = s.open(content); err != nil {
+			return nil, fmt.Errorf("decoding %s: %w", s.path, err)
+		}
+		s.sealed = true
+	}
+	data := &FinanceData{}
+	if err := json.Unmarshal(plain, data); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", s.path, err)
+	}
+	return data, nil
+}
+
+func (s *EncryptedFileStore) Save(data *FinanceData) error {
+	content, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if s.key == nil {
+		if err := s.newKey(); err != nil {
+			return err
+		}
+	}
+	sealed, err := s.seal(content)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.path, sealed, 0600); err != nil {
+		return err
+	}
+	s.sealed = true
+	return nil
+}
+
+// Rekey derives a new key from password with a fresh salt and rewrites
+// data with it. The old key stays in use if the write fails.
+func (s *EncryptedFileStore) Rekey(password string, data *FinanceData) error {
+	old := *s
+	s.password = []byte(password)
+	if err := s.newKey(); err != nil {
+		*s = old
+		return err
+	}
+	if err := s.Save(data); err != nil {
+		*s = old
+		return err
+	}
+	return nil
+}
+
+func (s *EncryptedFileStore) newKey() error {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	s.salt = salt
+	s.key = pbkdf2SHA256(s.password, salt, s.iterations, 32)
+	return nil
+}
+
+func (s *EncryptedFileStore) seal(plain []byte) ([]byte, error) {
+	aead, err := newAEAD(s.key)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, 0, encryptedHeader)
+	header = append(header, encryptedMagic...)
+	header = append(header, encryptedVersion)
+	header = binary.BigEndian.AppendUint32(header, uint32(s.iterations))
+	header = append(header, s.salt...)
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	header = append(header, nonce...)
+	header = append(header, keyCheck(s.key)...)
+
+	out := aead.Seal(header, nonce, plain, header)
+	return binary.BigEndian.AppendUint32(out, crc32.ChecksumIEEE(out)), nil
+}
+
+// open checks the file's checksum before the password, so damage is never
+// reported as a wrong password, and the key check before decrypting, so a
+// wrong password is never reported as damage.
+func (s *EncryptedFileStore) open(content []byte) ([]byte, error) {
+	if len(content) < encryptedHeader+4 {
+		return nil, fmt.Errorf("%w: file is truncated", ErrCorruptData)
+	}
+	body, sum := content[:len(content)-4], content[len(content)-4:]
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(sum) {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrCorruptData)
+	}
+	header, ciphertext := body[:encryptedHeader], body[encryptedHeader:]
+	rest := header[len(encryptedMagic):]
+	if version := rest[0]; version != encryptedVersion {
+		return nil, fmt.Errorf("unsupported encryption version %d", version)
+	}
+	iterations := int(binary.BigEndian.Uint32(rest[1:5]))
+	if iterations < 1 || iterations > maxKDFIterations {
+		return nil, fmt.Errorf("%w: invalid key derivation parameters", ErrCorruptData)
+	}
+	salt := rest[5 : 5+saltSize]
+	nonce := rest[5+saltSize : 5+saltSize+12]
+	check := rest[5+saltSize+12:]
+
+	key := s.key
+	if key == nil || iterations != s.iterations || !bytes.Equal(salt, s.salt) {
+		key = pbkdf2SHA256(s.password, salt, iterations, 32)
+	}
+	if !hmac.Equal(check, keyCheck(key)) {
+		return nil, ErrWrongPassword
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := aead.Open(nil, nonce, ciphertext, header)
+	if err != nil {
+		return nil, fmt.Errorf("%w: authentication failed", ErrCorruptData)
+	}
+	s.iterations, s.salt, s.key = iterations, append([]byte(nil), salt...), key
+	return plain, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// ChangePassword re-encrypts the data file with a key derived from
+// password. It fails unless the manager is backed by an EncryptedFileStore.
+func (fm *FinanceManager) ChangePassword(password string) error {
+	if password == "" {
+		return invalid(fmt.Errorf("password must not be empty"))
+	}
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	store, ok := fm.store.(*EncryptedFileStore)
+	if !ok {
+		return fmt.Errorf("the data file is not encrypted")
+	}
+	if err := store.Rekey(password, fm.data()); err != nil {
+		return fmt.Errorf("saving finance data: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// RateProvider returns how many units of to one unit of from is worth on
+// the given date.
+type RateProvider interface {
+	Rate(from, to string, on time.Time) (*big.Rat, error)
+}
+
+type datedRate struct {
+	date time.Time
+	rate *big.Rat
+}
+
+// FileRateProvider holds dated exchange rates loaded from a CSV file with
+// the columns date,from,to,rate, e.g. "2024-01-31,EUR,USD,1.0845". The rate
+// valid on a date is the latest one published on or before it; inverse
+// pairs are derived when only the other direction is listed.
+type FileRateProvider struct {
+	rates map[string][]datedRate
+}
+
+func NewFileRateProvider(path string) (*FileRateProvider, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	p := &FileRateProvider{rates: make(map[string][]datedRate)}
+	if err := p.Load(f); err != nil {
+		return nil, fmt.Errorf("loading rates from %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// Load adds the rates read from r.
+func (p *FileRateProvider) Load(r io.Reader) error {
+	if p.rates == nil {
+		p.rates = make(map[string][]datedRate)
+	}
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = 4
+	reader.TrimLeadingSpace = true
+
+	line := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		line++
+		if line == 1 && strings.EqualFold(record[0], "date") {
+			continue
+		}
+
+		date, err := time.ParseInLocation("2006-01-02", record[0], time.Local)
+		if err != nil {
+			return fmt.Errorf("line %d: invalid date %q", line, record[0])
+		}
+		from, to := strings.ToUpper(record[1]), strings.ToUpper(record[2])
+		if err := validateCurrency(from); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := validateCurrency(to); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		rate, ok := new(big.Rat).SetString(record[3])
+		if !ok || rate.Sign() <= 0 {
+			return fmt.Errorf("line %d: invalid rate %q", line, record[3])
+		}
+		p.Add(date, from, to, rate)
+	}
+	return nil
+}
+
+// Add records that one unit of from is worth rate units of to from date on.
+func (p *FileRateProvider) Add(date time.Time, from, to string, rate *big.Rat) {
+	if p.rates == nil {
+		p.rates = make(map[string][]datedRate)
+	}
+	key := from + "/" + to
+	rates := append(p.rates[key], datedRate{date: date, rate: rate})
+	sort.SliceStable(rates, func(i, j int) bool { return rates[i].date.Before(rates[j].date) })
+	p.rates[key] = rates
+}
+
+func (p *FileRateProvider) Rate(from, to string, on time.Time) (*big.Rat, error) {
+	if from == to {
+		return big.NewRat(1, 1), nil
+	}
+	if rate := latestRate(p.rates[from+"/"+to], on); rate != nil {
+		return rate, nil
+	}
+	if rate := latestRate(p.rates[to+"/"+from], on); rate != nil {
+		return new(big.Rat).Inv(rate), nil
+	}
+	return nil, fmt.Errorf("no %s/%s rate on or before %s", from, to, on.Format("2006-01-02"))
+}
+
+func latestRate(rates []datedRate, on time.Time) *big.Rat {
+	i := sort.Search(len(rates), func(i int) bool { return rates[i].date.After(on) })
+	if i == 0 {
+		return nil
+	}
+	return rates[i-1].rate
+}
+
+// Convert multiplies m by rate into currency to, rounding half away from zero
+// to the minor unit of to.
+func (m Money) Convert(to string, rate *big.Rat) (Money, error) {
+	num := new(big.Int).Mul(big.NewInt(m.Minor), rate.Num())
+	den := new(big.Int).Set(rate.Denom())
+	if shift := currencyExponent(to) - currencyExponent(m.Currency); shift > 0 {
+		num.Mul(num, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(shift)), nil))
+	} else if shift < 0 {
+		den.Mul(den, new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(-shift)), nil))
+	}
+
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if r.Sign() != 0 && new(big.Int).Mul(new(big.Int).Abs(r), big.NewInt(2)).Cmp(den) >= 0 {
+		q.Add(q, big.NewInt(int64(num.Sign())))
+	}
+	if !q.IsInt64() {
+		return Money{}, errors.New("amount overflow")
+	}
+	return Money{Minor: q.Int64(), Currency: to}, nil
+}
+
+// SetReportingCurrency chooses the currency that totals and reports use.
+func (fm *FinanceManager) SetReportingCurrency(currency string) error {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	if err := validateCurrency(currency); err != nil {
+		return err
+	}
+	fm.currency = currency
+	return nil
+}
+
+func (fm *FinanceManager) SetRateProvider(rates RateProvider) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	fm.rates = rates
+}
+
+// toReporting converts m into the reporting currency at the rate valid on date.
+func (fm *FinanceManager) toReporting(m Money, date time.Time) (Money, error) {
+	if m.Currency == fm.currency {
+		return m, nil
+	}
+	if fm.rates == nil {
+		return Money{}, fmt.Errorf("no exchange rates to convert %s to %s", m.Currency, fm.currency)
+	}
+	rate, err := fm.rates.Rate(m.Currency, fm.currency, date)
+	if err != nil {
+		return Money{}, err
+	}
+	return m.Convert(fm.currency, rate)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFileRateProvider(t *testing.T) {
+	rates := &FileRateProvider{}
+	err := rates.Load(strings.NewReader(`date,from,to,rate
+2024-01-01,EUR,USD,1.10
+2024-02-01,EUR,USD,1.08
+`))
+	if err != nil {
+		t.Fatalf("Failed to load rates: %v", err)
+	}
+
+	rate, err := rates.Rate("EUR", "USD", time.Date(2024, 1, 31, 12, 0, 0, 0, time.Local))
+	if err != nil || rate.Cmp(big.NewRat(110, 100)) != 0 {
+		t.Errorf("Expected January rate 1.10, got %v (%v)", rate, err)
+	}
+	rate, err = rates.Rate("USD", "EUR", time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local))
+	if err != nil || rate.Cmp(big.NewRat(100, 108)) != 0 {
+		t.Errorf("Expected inverse February rate, got %v (%v)", rate, err)
+	}
+	if _, err := rates.Rate("EUR", "USD", time.Date(2023, 12, 31, 0, 0, 0, 0, time.Local)); err == nil {
+		t.Error("Expected error for a date before the first rate")
+	}
+}
+
+func TestMoneyConvert(t *testing.T) {
+	got, err := NewMoney(1000, "EUR").Convert("JPY", big.NewRat(16055, 100))
+	if err != nil || got != NewMoney(1606, "JPY") {
+		t.Errorf("Expected ¥1606, got %v (%v)", got, err)
+	}
+	got, _ = NewMoney(-125, "USD").Convert("EUR", big.NewRat(1, 2))
+	if got != NewMoney(-63, "EUR") {
+		t.Errorf("Expected -0.63 EUR rounded away from zero, got %v", got)
+	}
+}
+
+func TestMultiCurrencyTotals(t *testing.T) {
+	rates := &FileRateProvider{}
+	rates.Add(time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local), "EUR", "USD", big.NewRat(11, 10))
+	rates.Add(time.Date(2024, 1, 15, 0, 0, 0, 0, time.Local), "EUR", "USD", big.NewRat(12, 10))
+
+	fm := NewFinanceManager()
+	fm.SetRateProvider(rates)
+	fm.AddIncome(time.Date(2024, 1, 10, 0, 0, 0, 0, time.Local), "Salary", NewMoney(10000, "EUR"))
+	fm.AddIncome(time.Date(2024, 1, 20, 0, 0, 0, 0, time.Local), "Bonus", NewMoney(10000, "EUR"))
+	fm.AddIncome(time.Date(2024, 1, 20, 0, 0, 0, 0, time.Local), "Refund", usd("5.00"))
+
+	total, err := fm.GetTotalIncome(time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local), time.Date(2024, 2, 1, 0, 0, 0, 0, time.Local))
+	if err != nil || total != usd("235.00") {
+		t.Errorf("Expected $235.00 converted at each entry's rate, got %s (%v)", total, err)
+	}
+
+	fm.SetReportingCurrency("GBP")
+	if _, err := fm.GetTotalIncome(time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local), time.Date(2024, 2, 1, 0, 0, 0, 0, time.Local)); err == nil {
+		t.Error("Expected error when no GBP rate is known")
+	}
+}
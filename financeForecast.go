@@ -0,0 +1,292 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// forecastBandZ places the confidence bands so the balance should end up
+// between Low and High in about 80% of outcomes.
+const forecastBandZ = 1.2816
+
+const defaultForecastHistory = 6
+
+// ForecastOptions configures a forecast. The projection covers Months
+// months starting with AsOf's month, of which only the days after AsOf are
+// projected. AsOf defaults to today. History is how many full months
+// before AsOf's month are averaged, 6 by default. Opening defaults to the
+// balances of all accounts at the end of AsOf plus the net of the incomes
+// and expenses up to then that belong to no account.
+type ForecastOptions struct {
+	AsOf    time.Time
+	Months  int
+	History int
+	Opening *Money
+}
+
+// ForecastMonth is one projected month in the reporting currency. Low and
+// High bound the closing Balance.
+type ForecastMonth struct {
+	Year       int             `json:"year"`
+	Month      time.Month      `json:"month"`
+	Income     Money           `json:"income"`
+	Expenses   Money           `json:"expenses"`
+	Net        Money           `json:"net"`
+	Balance    Money           `json:"balance"`
+	Low        Money           `json:"low"`
+	High       Money           `json:"high"`
+	Categories []CategoryTotal `json:"categories"`
+}
+
+// Forecast projects income, expenses and balance month by month.
+// FirstNegative is the first month expected to close below zero and
+// FirstAtRisk the first whose low band does.
+type Forecast struct {
+	Currency      string          `json:"currency"`
+	AsOf          time.Time       `json:"as_of"`
+	HistoryStart  time.Time       `json:"history_start"`
+	HistoryEnd    time.Time       `json:"history_end"`
+	Opening       Money           `json:"opening"`
+	Months        []ForecastMonth `json:"months"`
+	FirstNegative *time.Time      `json:"first_negative,omitempty"`
+	FirstAtRisk   *time.Time      `json:"first_at_risk,omitempty"`
+}
+
+// forecastStream is one income source or expense category, in minor units
+// of the reporting currency.
+type forecastStream struct {
+	name    string
+	income  bool
+	history []int64
+	elapsed int64
+	known   []int64
+	fixed   []int64
+}
+
+func (s *forecastStream) stats() (mean, variance float64) {
+	for _, v := range s.history {
+		mean += float64(v)
+	}
+	mean /= float64(len(s.history))
+	for _, v := range s.history {
+		variance += (float64(v) - mean) * (float64(v) - mean)
+	}
+	if len(s.history) > 1 {
+		variance /= float64(len(s.history) - 1)
+	}
+	return mean, variance
+}
+
+// Forecast projects the balance from the end of opts.AsOf. Each income
+// source and expense category is expected to repeat its average over the
+// history months, less what it already reached in AsOf's month. Recurring
+// series are projected from their schedules instead, and entries already
+// dated in a projected month replace the average of their category when
+// they are larger. Investments are not projected.
+func (fm *FinanceManager) Forecast(opts ForecastOptions) (*Forecast, error) {
+	fm = fm.view()
+	if opts.Months <= 0 {
+		return nil, fmt.Errorf("months must be positive")
+	}
+	if opts.History == 0 {
+		opts.History = defaultForecastHistory
+	}
+	if opts.History < 0 {
+		return nil, fmt.Errorf("history must not be negative")
+	}
+	if opts.AsOf.IsZero() {
+		opts.AsOf = time.Now()
+	}
+
+	current := time.Date(opts.AsOf.Year(), opts.AsOf.Month(), 1, 0, 0, 0, 0, opts.AsOf.Location())
+	cutoff := time.Date(opts.AsOf.Year(), opts.AsOf.Month(), opts.AsOf.Day()+1, 0, 0, 0, 0, opts.AsOf.Location())
+	historyStart := current.AddDate(0, -opts.History, 0)
+	end := current.AddDate(0, opts.Months, 0)
+	monthOf := func(date, base time.Time) int {
+		return (date.Year()-base.Year())*12 + int(date.Month()) - int(base.Month())
+	}
+
+	streams := make(map[string]*forecastStream)
+	var order []string
+	stream := func(income bool, name string) *forecastStream {
+		name = strings.TrimSpace(name)
+		if name == "" && !income {
+			name = "Uncategorized"
+		}
+		key := fmt.Sprintf("%t|%s", income, strings.ToLower(name))
+		s, ok := streams[key]
+		if !ok {
+			s = &forecastStream{name: name, income: income, history: make([]int64, opts.History),
+				known: make([]int64, opts.Months), fixed: make([]int64, opts.Months)}
+			streams[key] = s
+			order = append(order, key)
+		}
+		return s
+	}
+
+	var opening int64
+	materialized := make(map[string]bool)
+	add := func(income bool, name, account string, amount Money, date time.Time, recurringID string) error {
+		amount, err := fm.toReporting(amount, date)
+		if err != nil {
+			return err
+		}
+		minor := amount.Minor
+		if !income {
+			minor = -minor
+		}
+		switch {
+		case date.Before(cutoff):
+			// Account balances cover the entries that belong to one.
+			if strings.TrimSpace(account) == "" {
+				opening += minor
+			}
+			switch {
+			case recurringID != "" || date.Before(historyStart):
+			case date.Before(current):
+				stream(income, name).history[monthOf(date, historyStart)] += amount.Minor
+			default:
+				stream(income, name).elapsed += amount.Minor
+			}
+		case date.Before(end):
+			s := stream(income, name)
+			if recurringID != "" {
+				materialized[recurringID+"|"+date.Format("2006-01-02")] = true
+				s.fixed[monthOf(date, current)] += amount.Minor
+			} else {
+				s.known[monthOf(date, current)] += amount.Minor
+			}
+		}
+		return nil
+	}
+	for _, income := range fm.incomes {
+		if err := add(true, income.Source, income.Account, income.Amount, income.Date, income.RecurringID); err != nil {
+			return nil, err
+		}
+	}
+	for _, expense := range fm.expenses {
+		if err := add(false, expense.Category, expense.Account, expense.Amount, expense.Date, expense.RecurringID); err != nil {
+			return nil, err
+		}
+	}
+	for _, account := range fm.accounts {
+		balance, err := fm.AccountBalance(account.Name, cutoff.Add(-time.Nanosecond))
+		if err != nil {
+			return nil, err
+		}
+		if balance, err = fm.toReporting(balance, opts.AsOf); err != nil {
+			return nil, fmt.Errorf("account %s: %w", account.Name, err)
+		}
+		opening += balance.Minor
+	}
+
+	for _, t := range fm.recurring {
+		income := t.Type == "income"
+		name := t.Source
+		if !income {
+			name = fm.categorize(Expense{Category: t.Category, Payee: t.Payee, Amount: t.Amount}).Category
+		}
+		for n := 0; ; n++ {
+			date := t.occurrence(n)
+			if !date.Before(end) || (!t.End.IsZero() && date.After(t.End)) {
+				break
+			}
+			if date.Before(cutoff) || materialized[t.ID+"|"+date.Format("2006-01-02")] {
+				continue
+			}
+			amount, err := fm.toReporting(t.Amount, date)
+			if err != nil {
+				return nil, fmt.Errorf("recurring series %s: %w", t.ID, err)
+			}
+			stream(income, name).fixed[monthOf(date, current)] += amount.Minor
+		}
+	}
+
+	forecast := &Forecast{Currency: fm.currency, AsOf: opts.AsOf, HistoryStart: historyStart, HistoryEnd: current,
+		Opening: Money{Minor: opening, Currency: fm.currency}}
+	if opts.Opening != nil {
+		amount, err := fm.toReporting(*opts.Opening, opts.AsOf)
+		if err != nil {
+			return nil, err
+		}
+		forecast.Opening = amount
+	}
+
+	sort.Strings(order)
+	money := func(minor float64) Money { return Money{Minor: int64(minor), Currency: fm.currency} }
+	balance := float64(forecast.Opening.Minor)
+	var spread float64
+	for i := 0; i < opts.Months; i++ {
+		month := current.AddDate(0, i, 0)
+		var income, expenses, variance float64
+		categories := newCategoryTotals(fm.currency)
+		for _, key := range order {
+			s := streams[key]
+			mean, v := s.stats()
+			// AsOf's month only gets what its average still leaves.
+			var elapsed float64
+			if i == 0 {
+				elapsed = float64(s.elapsed)
+			}
+			expected := float64(s.known[i]) + elapsed
+			if expected < mean {
+				expected = mean
+				variance += v
+			}
+			expected = math.Round(expected - elapsed + float64(s.fixed[i]))
+			if s.income {
+				income += expected
+			} else if expected > 0 {
+				expenses += expected
+				if err := categories.add(s.name, money(expected)); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		balance += income - expenses
+		spread += variance
+		band := math.Round(forecastBandZ * math.Sqrt(spread))
+		m := ForecastMonth{
+			Year:       month.Year(),
+			Month:      month.Month(),
+			Income:     money(income),
+			Expenses:   money(expenses),
+			Net:        money(income - expenses),
+			Balance:    money(balance),
+			Low:        money(balance - band),
+			High:       money(balance + band),
+			Categories: categories.sorted(),
+		}
+		forecast.Months = append(forecast.Months, m)
+		if forecast.FirstNegative == nil && m.Balance.IsNegative() {
+			forecast.FirstNegative = &month
+		}
+		if forecast.FirstAtRisk == nil && m.Low.IsNegative() {
+			forecast.FirstAtRisk = &month
+		}
+	}
+	return forecast, nil
+}
+
+func formatForecast(w io.Writer, f *Forecast) {
+	fmt.Fprintf(w, "Cash-flow forecast from %s on %s, averaging %s to %s\n", f.Opening, f.AsOf.Format("2006-01-02"),
+		f.HistoryStart.Format("2006-01"), f.HistoryEnd.AddDate(0, 0, -1).Format("2006-01"))
+	fmt.Fprintf(w, "%-8s %14s %14s %14s %14s   %s\n", "Month", "Income", "Expenses", "Net", "Balance", "80% band")
+	for _, m := range f.Months {
+		fmt.Fprintf(w, "%d-%02d  %14s %14s %14s %14s   %s to %s\n", m.Year, m.Month,
+			m.Income, m.Expenses, m.Net, m.Balance, m.Low, m.High)
+	}
+	switch {
+	case f.FirstNegative != nil:
+		fmt.Fprintf(w, "Balance expected to go negative in %s\n", f.FirstNegative.Format("January 2006"))
+	case f.FirstAtRisk != nil:
+		fmt.Fprintf(w, "Balance may go negative from %s\n", f.FirstAtRisk.Format("January 2006"))
+	default:
+		fmt.Fprintln(w, "Balance expected to stay positive")
+	}
+}
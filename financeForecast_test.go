@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func forecastFixture(t *testing.T) *FinanceManager {
+	t.Helper()
+	fm := NewFinanceManager()
+	for i, amount := range []string{"300.00", "500.00", "400.00", "400.00", "300.00", "500.00"} {
+		if err := fm.AddExpense(date(2024, time.Month(i+1), 10), "Food", usd(amount)); err != nil {
+			t.Fatalf("Failed to add expense: %v", err)
+		}
+	}
+	series := []RecurringTemplate{
+		{Type: "income", Source: "Salary", Amount: usd("2000.00"), Rule: RecurrenceRule{Frequency: RecurMonthly}, Start: date(2024, 8, 1)},
+		{Type: "expense", Category: "Rent", Amount: usd("2000.00"), Rule: RecurrenceRule{Frequency: RecurMonthly}, Start: date(2024, 8, 1)},
+	}
+	for _, s := range series {
+		if _, err := fm.CreateRecurring(s); err != nil {
+			t.Fatalf("Failed to add recurring series: %v", err)
+		}
+	}
+	// A known one-off that exceeds the usual food spending.
+	fm.AddExpense(date(2024, 9, 5), "food", usd("700.00"))
+	return fm
+}
+
+func TestForecast(t *testing.T) {
+	fm := forecastFixture(t)
+	opening := usd("1000.00")
+	forecast, err := fm.Forecast(ForecastOptions{AsOf: date(2024, 7, 10), Months: 4, Opening: &opening})
+	if err != nil {
+		t.Fatalf("Failed to forecast: %v", err)
+	}
+	if len(forecast.Months) != 4 || forecast.Months[0].Month != time.July {
+		t.Fatalf("Expected July to October, got %+v", forecast.Months)
+	}
+
+	wantIncome := []string{"0.00", "2000.00", "2000.00", "2000.00"}
+	wantExpenses := []string{"400.00", "2400.00", "2700.00", "2400.00"}
+	wantBalances := []string{"600.00", "200.00", "-500.00", "-900.00"}
+	for i, m := range forecast.Months {
+		if m.Income != usd(wantIncome[i]) || m.Expenses != usd(wantExpenses[i]) || m.Balance != usd(wantBalances[i]) {
+			t.Errorf("%s: expected income %s, expenses %s and balance %s, got %s, %s and %s",
+				m.Month, wantIncome[i], wantExpenses[i], wantBalances[i], m.Income, m.Expenses, m.Balance)
+		}
+	}
+
+	// Food varies by a standard deviation of about $89.44 a month.
+	jul := forecast.Months[0]
+	if jul.Low != usd("485.37") || jul.High != usd("714.63") {
+		t.Errorf("Expected July band $485.37 to $714.63, got %s to %s", jul.Low, jul.High)
+	}
+	if sep := forecast.Months[2]; sep.High != usd("-337.89") {
+		t.Errorf("Expected the known September expense not to widen the band, got high %s", sep.High)
+	}
+	if oct := forecast.Months[3]; oct.High != usd("-701.46") {
+		t.Errorf("Expected the band to keep widening in October, got high %s", oct.High)
+	}
+	if forecast.FirstNegative == nil || !forecast.FirstNegative.Equal(date(2024, 9, 1)) {
+		t.Errorf("Expected the balance to go negative in September, got %v", forecast.FirstNegative)
+	}
+	if forecast.FirstAtRisk == nil || !forecast.FirstAtRisk.Equal(date(2024, 9, 1)) {
+		t.Errorf("Expected September to be the first month at risk, got %v", forecast.FirstAtRisk)
+	}
+	if cats := forecast.Months[1].Categories; len(cats) != 2 || cats[0].Category != "Rent" || cats[1].Amount != usd("400.00") {
+		t.Errorf("Unexpected August categories %+v", cats)
+	}
+
+	if _, err := fm.Forecast(ForecastOptions{Months: 0}); err == nil {
+		t.Error("Expected error for zero months")
+	}
+}
+
+func TestForecastCountsMaterializedEntriesOnce(t *testing.T) {
+	fm := forecastFixture(t)
+	if _, err := fm.MaterializeRecurring(date(2024, 8, 31)); err != nil {
+		t.Fatalf("Failed to materialize: %v", err)
+	}
+	forecast, err := fm.Forecast(ForecastOptions{AsOf: date(2024, 7, 10), Months: 2})
+	if err != nil {
+		t.Fatalf("Failed to forecast: %v", err)
+	}
+	if aug := forecast.Months[1]; aug.Income != usd("2000.00") || aug.Expenses != usd("2400.00") {
+		t.Errorf("Expected one salary and one rent in August, got %s and %s", aug.Income, aug.Expenses)
+	}
+	if forecast.Opening != usd("-2400.00") {
+		t.Errorf("Expected opening balance -$2400.00 from past entries, got %s", forecast.Opening)
+	}
+}
+
+func TestForecastStartsAtAsOf(t *testing.T) {
+	fm := forecastFixture(t)
+	if err := fm.AddAccount(Account{Name: "Checking", Kind: AccountChecking, OpeningBalance: usd("3000.00"), Opened: date(2024, 1, 1)}); err != nil {
+		t.Fatalf("Failed to add account: %v", err)
+	}
+	if _, err := fm.CreateExpense(Expense{Date: date(2024, 8, 3), Category: "Food", Amount: usd("150.00"), Account: "Checking"}); err != nil {
+		t.Fatalf("Failed to add expense: %v", err)
+	}
+	forecast, err := fm.Forecast(ForecastOptions{AsOf: date(2024, 8, 10), Months: 2})
+	if err != nil {
+		t.Fatalf("Failed to forecast: %v", err)
+	}
+	// $3000.00 in checking less $150.00 food, and -$2400.00 of past food
+	// without an account.
+	if forecast.Opening != usd("450.00") {
+		t.Errorf("Expected opening balance $450.00 from accounts and entries, got %s", forecast.Opening)
+	}
+	// The August salary and rent fell before AsOf and are not projected
+	// again; food still has $200.00 of its $350.00 average to go.
+	aug := forecast.Months[0]
+	if aug.Month != time.August || aug.Income != usd("0.00") || aug.Expenses != usd("200.00") {
+		t.Errorf("Expected the rest of August to hold $200.00 of food, got %s income and %s expenses", aug.Income, aug.Expenses)
+	}
+
+	forecast, err = fm.Forecast(ForecastOptions{AsOf: date(2024, 7, 31), Months: 1})
+	if err != nil {
+		t.Fatalf("Failed to forecast: %v", err)
+	}
+	if jul := forecast.Months[0]; jul.Income != usd("0.00") || jul.Expenses != usd("400.00") {
+		t.Errorf("Expected nothing but food averages left in July, got %s and %s", jul.Income, jul.Expenses)
+	}
+	forecast, err = fm.Forecast(ForecastOptions{AsOf: date(2024, 7, 31), Months: 2})
+	if err != nil || forecast.Months[1].Income != usd("2000.00") {
+		t.Errorf("Expected the August salary after July 31, got %v", err)
+	}
+}
+
+func TestForecastCommand(t *testing.T) {
+	fm := forecastFixture(t)
+	var out bytes.Buffer
+	err := runFinanceCommand(fm, nil, []string{"forecast", "-date", "2024-07-10", "-months", "3", "-opening", "1000"}, &out)
+	if err != nil {
+		t.Fatalf("forecast failed: %v", err)
+	}
+	for _, want := range []string{"2024-07", "2024-09", "Balance expected to go negative in September 2024"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected forecast to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Audit event actions. Every saved change is recorded as auditChange;
+// undoing and redoing it are recorded as events of their own.
+const (
+	auditChange = "change"
+	auditUndo   = "undo"
+	auditRedo   = "redo"
+)
+
+// maxUndo bounds how many changes a session can undo.
+const maxUndo = 100
+
+// AuditChange is one record touched by an event, as JSON. Before is empty
+// for a created record and After for a deleted one.
+type AuditChange struct {
+	Kind   string          `json:"kind"`
+	ID     string          `json:"id"`
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
+
+func (c AuditChange) verb() string {
+	switch {
+	case len(c.Before) == 0:
+		return "created"
+	case len(c.After) == 0:
+		return "deleted"
+	default:
+		return "updated"
+	}
+}
+
+type AuditEvent struct {
+	Seq     int           `json:"seq"`
+	Time    time.Time     `json:"time"`
+	Actor   string        `json:"actor"`
+	Action  string        `json:"action"`
+	Changes []AuditChange `json:"changes"`
+}
+
+// currentActor names the user making changes, for the audit history.
+func currentActor() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := os.Getenv("USER"); name != "" {
+		return name
+	}
+	return "unknown"
+}
+
+// SetActor sets who the following changes are recorded as made by.
+func (fm *FinanceManager) SetActor(actor string) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	fm.actor = actor
+}
+
+// History returns the recorded events, oldest first.
+func (fm *FinanceManager) History() []AuditEvent {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+	return append([]AuditEvent(nil), fm.history...)
+}
+
+// copyData copies the state in d without its history. Entries are always
+// replaced rather than changed in place, so copying the slices is enough
+// to keep the copy from following later changes.
+func copyData(d *FinanceData) *FinanceData {
+	c := &FinanceData{
+		Incomes:     append([]Income(nil), d.Incomes...),
+		Expenses:    append([]Expense(nil), d.Expenses...),
+		Investments: append([]Investment(nil), d.Investments...),
+		Budgets:     append([]Budget(nil), d.Budgets...),
+		Recurring:   append([]RecurringTemplate(nil), d.Recurring...),
+		Prices:      append([]AssetPrice(nil), d.Prices...),
+		Accounts:    append([]Account(nil), d.Accounts...),
+		NextID:      d.NextID,
+	}
+	if d.Ledger != nil {
+		c.Ledger = &LedgerData{Transactions: append([]LedgerTransaction(nil), d.Ledger.Transactions...)}
+	}
+	return c
+}
+
+// restore replaces the state with a copy of d. IDs keep counting up, so an
+// undone entry's ID is never handed out again.
+func (fm *FinanceManager) restore(d *FinanceData) {
+	fm.incomes = append(make([]Income, 0), d.Incomes...)
+	fm.expenses = append(make([]Expense, 0), d.Expenses...)
+	fm.investments = append(make([]Investment, 0), d.Investments...)
+	fm.budgets = append(make([]Budget, 0), d.Budgets...)
+	fm.recurring = append(make([]RecurringTemplate, 0), d.Recurring...)
+	fm.prices = append(make([]AssetPrice, 0), d.Prices...)
+	fm.accounts = append(make([]Account, 0), d.Accounts...)
+	fm.ledger = nil
+	if d.Ledger != nil {
+		fm.ledger = &LedgerData{Transactions: append(make([]LedgerTransaction, 0), d.Ledger.Transactions...)}
+	}
+}
+
+type auditKey struct {
+	kind string
+	id   string
+}
+
+// auditRecords flattens d into its records by kind and ID.
+func auditRecords(d *FinanceData) map[auditKey]any {
+	records := make(map[auditKey]any)
+	if d == nil {
+		return records
+	}
+	add := func(kind, id string, v any) { records[auditKey{kind, id}] = v }
+	for _, income := range d.Incomes {
+		add("income", income.ID, income)
+	}
+	for _, expense := range d.Expenses {
+		add("expense", expense.ID, expense)
+	}
+	for _, investment := range d.Investments {
+		add("investment", investment.ID, investment)
+	}
+	for _, budget := range d.Budgets {
+		add("budget", budget.Category+"/"+string(budget.Period), budget)
+	}
+	for _, t := range d.Recurring {
+		add("recurring", t.ID, t)
+	}
+	for _, price := range d.Prices {
+		add("price", price.Asset+"@"+price.Date.Format("2006-01-02"), price)
+	}
+	for _, account := range d.Accounts {
+		add("account", account.Name, account)
+	}
+	if d.Ledger != nil {
+		add("ledger", "enabled", true)
+		// Booked transactions follow their entries, which are recorded.
+		for _, txn := range d.Ledger.Transactions {
+			if txn.posted() {
+				add("transaction", txn.ID, txn)
+			}
+		}
+	}
+	return records
+}
+
+// diffData lists the records that differ between two states, as JSON.
+func diffData(before, after *FinanceData) ([]AuditChange, error) {
+	old, updated := auditRecords(before), auditRecords(after)
+	encode := func(v any, ok bool) (json.RawMessage, error) {
+		if !ok {
+			return nil, nil
+		}
+		return json.Marshal(v)
+	}
+
+	var changes []AuditChange
+	add := func(key auditKey) error {
+		b, hadBefore := old[key]
+		a, hasAfter := updated[key]
+		if hadBefore && hasAfter && reflect.DeepEqual(a, b) {
+			return nil
+		}
+		change := AuditChange{Kind: key.kind, ID: key.id}
+		var err error
+		if change.Before, err = encode(b, hadBefore); err != nil {
+			return err
+		}
+		if change.After, err = encode(a, hasAfter); err != nil {
+			return err
+		}
+		changes = append(changes, change)
+		return nil
+	}
+	for key := range old {
+		if err := add(key); err != nil {
+			return nil, err
+		}
+	}
+	for key := range updated {
+		if _, ok := old[key]; !ok {
+			if err := add(key); err != nil {
+				return nil, err
+			}
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Kind != changes[j].Kind {
+			return changes[i].Kind < changes[j].Kind
+		}
+		return changes[i].ID < changes[j].ID
+	})
+	return changes, nil
+}
+
+// record appends an event for the changes since the last commit and
+// returns the new state, or nil when nothing changed.
+func (fm *FinanceManager) record(action string) (*FinanceData, error) {
+	state := copyData(fm.data())
+	changes, err := diffData(fm.committed, state)
+	if err != nil || len(changes) == 0 {
+		return nil, err
+	}
+	seq := 1
+	if n := len(fm.history); n > 0 {
+		seq = fm.history[n-1].Seq + 1
+	}
+	fm.history = append(fm.history, AuditEvent{Seq: seq, Time: time.Now(), Actor: fm.actor, Action: action, Changes: changes})
+	return state, nil
+}
+
+func (fm *FinanceManager) CanUndo() bool {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+	return len(fm.undo) > 0
+}
+
+func (fm *FinanceManager) CanRedo() bool {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+	return len(fm.redo) > 0
+}
+
+// Undo reverts the last change made through fm. Undoing is itself saved
+// and recorded in the history.
+func (fm *FinanceManager) Undo() error {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	if len(fm.undo) == 0 {
+		return fmt.Errorf("nothing to undo")
+	}
+	target := fm.undo[len(fm.undo)-1]
+	current := fm.committed
+	fm.restore(target)
+	if err := fm.commitAs(auditUndo, func() { fm.restore(current) }); err != nil {
+		return err
+	}
+	fm.undo = fm.undo[:len(fm.undo)-1]
+	fm.redo = append(fm.redo, current)
+	return nil
+}
+
+// Redo applies the last undone change again.
+func (fm *FinanceManager) Redo() error {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	if len(fm.redo) == 0 {
+		return fmt.Errorf("nothing to redo")
+	}
+	target := fm.redo[len(fm.redo)-1]
+	current := fm.committed
+	fm.restore(target)
+	if err := fm.commitAs(auditRedo, func() { fm.restore(current) }); err != nil {
+		return err
+	}
+	fm.redo = fm.redo[:len(fm.redo)-1]
+	fm.undo = append(fm.undo, current)
+	return nil
+}
+
+// changedFields lists the top-level fields that differ between two JSON
+// objects, with their old and new values.
+func changedFields(before, after json.RawMessage) []string {
+	var old, updated map[string]json.RawMessage
+	if json.Unmarshal(before, &old) != nil || json.Unmarshal(after, &updated) != nil {
+		return []string{fmt.Sprintf("%s -> %s", before, after)}
+	}
+	var fields []string
+	for name, value := range updated {
+		if !bytes.Equal(old[name], value) {
+			fields = append(fields, name)
+		}
+	}
+	for name := range old {
+		if _, ok := updated[name]; !ok {
+			fields = append(fields, name)
+		}
+	}
+	sort.Strings(fields)
+	for i, name := range fields {
+		fields[i] = fmt.Sprintf("%s: %s -> %s", name, orNone(old[name]), orNone(updated[name]))
+	}
+	return fields
+}
+
+func orNone(value json.RawMessage) string {
+	if len(value) == 0 {
+		return "none"
+	}
+	return string(value)
+}
+
+func formatHistory(w io.Writer, events []AuditEvent) {
+	for _, event := range events {
+		fmt.Fprintf(w, "#%d %s %s %s\n", event.Seq, event.Time.Format("2006-01-02 15:04:05"), event.Actor, event.Action)
+		for _, change := range event.Changes {
+			fmt.Fprintf(w, "    %s %s %s\n", change.verb(), change.Kind, change.ID)
+			switch change.verb() {
+			case "created":
+				fmt.Fprintf(w, "        %s\n", change.After)
+			case "deleted":
+				fmt.Fprintf(w, "        %s\n", change.Before)
+			default:
+				fmt.Fprintf(w, "        %s\n", strings.Join(changedFields(change.Before, change.After), "\n        "))
+			}
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUndoRedo(t *testing.T) {
+	fm := NewFinanceManager()
+	fm.SetActor("alice")
+	income, err := fm.CreateIncome(Income{Date: date(2024, 3, 1), Source: "Salary", Amount: usd("5000.00")})
+	if err != nil {
+		t.Fatalf("Failed to add income: %v", err)
+	}
+	income.Amount = usd("500.00")
+	if err := fm.UpdateIncome(income); err != nil {
+		t.Fatalf("Failed to update income: %v", err)
+	}
+
+	if err := fm.Undo(); err != nil {
+		t.Fatalf("Failed to undo: %v", err)
+	}
+	if got, _ := fm.GetIncome(income.ID); got.Amount != usd("5000.00") {
+		t.Errorf("Expected undo to restore $5000.00, got %s", got.Amount)
+	}
+	if err := fm.Redo(); err != nil {
+		t.Fatalf("Failed to redo: %v", err)
+	}
+	if got, _ := fm.GetIncome(income.ID); got.Amount != usd("500.00") {
+		t.Errorf("Expected redo to apply $500.00 again, got %s", got.Amount)
+	}
+
+	fm.Undo()
+	fm.Undo()
+	if len(fm.ListIncomes()) != 0 {
+		t.Errorf("Expected undoing the create to remove the income, got %+v", fm.ListIncomes())
+	}
+	if err := fm.Undo(); err == nil {
+		t.Error("Expected error with nothing left to undo")
+	}
+
+	fm.Redo()
+	fm.AddExpense(date(2024, 3, 2), "Rent", usd("1200.00"))
+	if fm.CanRedo() {
+		t.Error("Expected a new change to clear the redo stack")
+	}
+	if created, _ := fm.CreateIncome(Income{Date: date(2024, 3, 3), Source: "Gift", Amount: usd("20.00")}); created.ID == income.ID {
+		t.Error("Expected IDs not to be reused after undo")
+	}
+
+	var actions []string
+	for _, event := range fm.History() {
+		actions = append(actions, event.Action)
+		if event.Actor != "alice" {
+			t.Errorf("Expected actor alice, got %q", event.Actor)
+		}
+	}
+	want := "change change undo redo undo undo redo change change"
+	if got := strings.Join(actions, " "); got != want {
+		t.Errorf("Expected actions %q, got %q", want, got)
+	}
+}
+
+func TestHistoryRecordsBeforeAndAfter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "finance.json")
+	fm, err := NewFinanceManagerWithStore(NewJSONFileStore(path))
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	expense, _ := fm.CreateExpense(Expense{Date: date(2024, 3, 2), Category: "Rent", Amount: usd("1200.00")})
+	expense.Amount = usd("1250.00")
+	fm.UpdateExpense(expense)
+	fm.SetBudget("Rent", BudgetMonthly, usd("1300.00"))
+
+	reopened, err := NewFinanceManagerWithStore(NewJSONFileStore(path))
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	history := reopened.History()
+	if len(history) != 3 {
+		t.Fatalf("Expected 3 events after reopening, got %d", len(history))
+	}
+	update := history[1]
+	if len(update.Changes) != 1 || update.Changes[0].ID != expense.ID || update.Changes[0].verb() != "updated" {
+		t.Fatalf("Unexpected changes %+v", update.Changes)
+	}
+	if reopened.CanUndo() {
+		t.Error("Expected undo to be limited to the current session")
+	}
+
+	var out bytes.Buffer
+	formatHistory(&out, history)
+	for _, want := range []string{"updated expense " + expense.ID, `"1200.00"`, `"1250.00"`, "created budget Rent/monthly"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected history to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Quantity counts units of an asset to eight decimal places, so fractional
+// shares and crypto amounts are exact.
+type Quantity int64
+
+const (
+	quantityDecimals = 8
+	oneUnit          = Quantity(100000000)
+)
+
+func ParseQuantity(input string) (Quantity, error) {
+	s := strings.TrimSpace(input)
+	whole, frac, _ := strings.Cut(s, ".")
+	if s == "" || !isDigits(whole) || !isDigits(frac) || len(frac) > quantityDecimals {
+		return 0, fmt.Errorf("invalid quantity %q", input)
+	}
+	frac += strings.Repeat("0", quantityDecimals-len(frac))
+	n, err := strconv.ParseInt("0"+whole+frac, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid quantity %q: out of range", input)
+	}
+	return Quantity(n), nil
+}
+
+func (q Quantity) String() string {
+	s := strconv.FormatInt(int64(q/oneUnit), 10)
+	if frac := int64(q % oneUnit); frac != 0 {
+		s += "." + strings.TrimRight(fmt.Sprintf("%0*d", quantityDecimals, frac), "0")
+	}
+	return s
+}
+
+func (q Quantity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(q.String())
+}
+
+func (q *Quantity) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return fmt.Errorf("quantity must be a decimal string: %w", err)
+	}
+	parsed, err := ParseQuantity(s)
+	if err != nil {
+		return err
+	}
+	*q = parsed
+	return nil
+}
+
+// scaleMoney returns m × num / den, rounded half away from zero.
+func scaleMoney(m Money, num, den Quantity) (Money, error) {
+	if den == 0 {
+		return Money{}, fmt.Errorf("cannot scale by a zero quantity")
+	}
+	return m.Convert(m.Currency, big.NewRat(int64(num), int64(den)))
+}
+
+type InvestmentType string
+
+const (
+	InvestmentBuy  InvestmentType = "buy"
+	InvestmentSell InvestmentType = "sell"
+)
+
+func parseInvestmentType(value string) (InvestmentType, error) {
+	switch kind := InvestmentType(strings.ToLower(strings.TrimSpace(value))); kind {
+	case "", InvestmentBuy:
+		return InvestmentBuy, nil
+	case InvestmentSell:
+		return kind, nil
+	}
+	return "", fmt.Errorf("invalid investment type %q, expected buy or sell", value)
+}
+
+// units is the traded quantity. Entries written before quantities existed
+// count as a single unit.
+func (investment Investment) units() Quantity {
+	if investment.Quantity == 0 {
+		return oneUnit
+	}
+	return investment.Quantity
+}
+
+func (investment Investment) kind() InvestmentType {
+	if investment.Type == "" {
+		return InvestmentBuy
+	}
+	return investment.Type
+}
+
+// UnitPrice is the price paid or received per unit.
+func (investment Investment) UnitPrice() (Money, error) {
+	return scaleMoney(investment.Value, oneUnit, investment.units())
+}
+
+// BuyInvestment records the purchase of quantity units at unitPrice each.
+func (fm *FinanceManager) BuyInvestment(date time.Time, asset string, quantity Quantity, unitPrice Money) (Investment, error) {
+	return fm.tradeInvestment(date, asset, InvestmentBuy, quantity, unitPrice)
+}
+
+// SellInvestment records the sale of quantity units at unitPrice each.
+func (fm *FinanceManager) SellInvestment(date time.Time, asset string, quantity Quantity, unitPrice Money) (Investment, error) {
+	return fm.tradeInvestment(date, asset, InvestmentSell, quantity, unitPrice)
+}
+
+func (fm *FinanceManager) tradeInvestment(date time.Time, asset string, kind InvestmentType, quantity Quantity, unitPrice Money) (Investment, error) {
+	value, err := scaleMoney(unitPrice, quantity, oneUnit)
+	if err != nil {
+		return Investment{}, err
+	}
+	return fm.CreateInvestment(Investment{Date: date, Asset: asset, Type: kind, Quantity: quantity, Value: value})
+}
+
+// CostBasisMethod decides which purchase cost a sale is matched against.
+type CostBasisMethod string
+
+const (
+	// CostBasisFIFO sells the oldest lots first.
+	CostBasisFIFO CostBasisMethod = "fifo"
+	// CostBasisAverage sells at the average cost of every unit held.
+	CostBasisAverage CostBasisMethod = "average"
+)
+
+func (fm *FinanceManager) SetCostBasisMethod(method CostBasisMethod) error {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	switch method {
+	case CostBasisFIFO, CostBasisAverage:
+		fm.costBasis = method
+		return nil
+	}
+	return fmt.Errorf("invalid cost basis method %q, expected fifo or average", method)
+}
+
+// Lot is the unsold part of a purchase.
+type Lot struct {
+	Date     time.Time `json:"date"`
+	Quantity Quantity  `json:"quantity"`
+	Cost     Money     `json:"cost"`
+}
+
+// Holding is the open position in one asset, in the asset's currency.
+// It is priced at the asset's most recent quote or trade, whichever is newer.
+type Holding struct {
+	Asset          string    `json:"asset"`
+	Quantity       Quantity  `json:"quantity"`
+	Price          Money     `json:"price"`
+	PriceDate      time.Time `json:"price_date"`
+	CostBasis      Money     `json:"cost_basis"`
+	MarketValue    Money     `json:"market_value"`
+	UnrealizedGain Money     `json:"unrealized_gain"`
+	Lots           []Lot     `json:"lots,omitempty"`
+}
+
+// RealizedGain is the result of one sale.
+type RealizedGain struct {
+	InvestmentID string    `json:"investment_id"`
+	Date         time.Time `json:"date"`
+	Asset        string    `json:"asset"`
+	Quantity     Quantity  `json:"quantity"`
+	Proceeds     Money     `json:"proceeds"`
+	CostBasis    Money     `json:"cost_basis"`
+	Gain         Money     `json:"gain"`
+}
+
+type position struct {
+	asset    string
+	currency string
+	lots     []Lot
+	last     Investment
+}
+
+func (p *position) quantity() Quantity {
+	var total Quantity
+	for _, lot := range p.lots {
+		total += lot.Quantity
+	}
+	return total
+}
+
+func (p *position) cost() (Money, error) {
+	costs := make([]Money, len(p.lots))
+	for i, lot := range p.lots {
+		costs[i] = lot.Cost
+	}
+	return sumMoney(p.currency, costs...)
+}
+
+func (p *position) sell(trade Investment, method CostBasisMethod) (RealizedGain, error) {
+	units, held := trade.units(), p.quantity()
+	if units > held {
+		return RealizedGain{}, fmt.Errorf("selling %s %s exceeds the %s held", units, p.asset, held)
+	}
+
+	cost := Money{Currency: p.currency}
+	var err error
+	if method == CostBasisAverage {
+		// Every unit carries the same cost, so the lots collapse into one.
+		total, err := p.cost()
+		if err != nil {
+			return RealizedGain{}, err
+		}
+		if cost, err = scaleMoney(total, units, held); err != nil {
+			return RealizedGain{}, err
+		}
+		remaining, err := total.Sub(cost)
+		if err != nil {
+			return RealizedGain{}, err
+		}
+		p.lots = []Lot{{Date: p.lots[0].Date, Quantity: held - units, Cost: remaining}}
+		if units == held {
+			p.lots = nil
+		}
+	} else {
+		for left := units; left > 0; {
+			lot := &p.lots[0]
+			if lot.Quantity <= left {
+				if cost, err = cost.Add(lot.Cost); err != nil {
+					return RealizedGain{}, err
+				}
+				left -= lot.Quantity
+				p.lots = p.lots[1:]
+				continue
+			}
+			part, err := scaleMoney(lot.Cost, left, lot.Quantity)
+			if err != nil {
+				return RealizedGain{}, err
+			}
+			if cost, err = cost.Add(part); err != nil {
+				return RealizedGain{}, err
+			}
+			if lot.Cost, err = lot.Cost.Sub(part); err != nil {
+				return RealizedGain{}, err
+			}
+			lot.Quantity -= left
+			left = 0
+		}
+	}
+
+	gain, err := trade.Value.Sub(cost)
+	if err != nil {
+		return RealizedGain{}, err
+	}
+	return RealizedGain{
+		InvestmentID: trade.ID,
+		Date:         trade.Date,
+		Asset:        p.asset,
+		Quantity:     units,
+		Proceeds:     trade.Value,
+		CostBasis:    cost,
+		Gain:         gain,
+	}, nil
+}
+
+// replayInvestments applies the trades dated before end, or every trade
+// when end is zero, in date order. It fails when a sale exceeds the units
+// held at the time.
+func (fm *FinanceManager) replayInvestments(end time.Time) ([]*position, []RealizedGain, error) {
+	trades := append([]Investment(nil), fm.investments...)
+	sort.SliceStable(trades, func(i, j int) bool { return trades[i].Date.Before(trades[j].Date) })
+
+	index := make(map[string]*position)
+	var positions []*position
+	var gains []RealizedGain
+	for _, trade := range trades {
+		if !end.IsZero() && !trade.Date.Before(end) {
+			break
+		}
+		asset := strings.TrimSpace(trade.Asset)
+		p, ok := index[strings.ToLower(asset)]
+		if !ok {
+			p = &position{asset: asset, currency: trade.Value.Currency}
+			index[strings.ToLower(asset)] = p
+			positions = append(positions, p)
+		}
+		if trade.Value.Currency != p.currency {
+			return nil, nil, fmt.Errorf("asset %q is traded in both %s and %s", p.asset, p.currency, trade.Value.Currency)
+		}
+		p.last = trade
+
+		if trade.Type == InvestmentSell {
+			gain, err := p.sell(trade, fm.costBasis)
+			if err != nil {
+				return nil, nil, fmt.Errorf("investment %s: %w", trade.ID, err)
+			}
+			gains = append(gains, gain)
+			continue
+		}
+		p.lots = append(p.lots, Lot{Date: trade.Date, Quantity: trade.units(), Cost: trade.Value})
+	}
+
+	sort.Slice(positions, func(i, j int) bool { return positions[i].asset < positions[j].asset })
+	return positions, gains, nil
+}
+
+// commitInvestments checks that the trades still add up before saving.
+func (fm *FinanceManager) commitInvestments(rollback func()) error {
+	if _, _, err := fm.replayInvestments(time.Time{}); err != nil {
+		fm.unbooked = nil
+		rollback()
+		return invalid(err)
+	}
+	return fm.commit(rollback)
+}
+
+// Holdings returns the open positions from the trades dated before end, or
+// from every trade when end is zero.
+func (fm *FinanceManager) Holdings(end time.Time) ([]Holding, error) {
+	fm = fm.view()
+	positions, _, err := fm.replayInvestments(end)
+	if err != nil {
+		return nil, err
+	}
+
+	var holdings []Holding
+	for _, p := range positions {
+		quantity := p.quantity()
+		if quantity == 0 {
+			continue
+		}
+		h := Holding{Asset: p.asset, Quantity: quantity, PriceDate: p.last.Date, Lots: append([]Lot(nil), p.lots...)}
+		if h.Price, err = p.last.UnitPrice(); err != nil {
+			return nil, err
+		}
+		if h.CostBasis, err = p.cost(); err != nil {
+			return nil, err
+		}
+		if h.MarketValue, err = scaleMoney(p.last.Value, quantity, p.last.units()); err != nil {
+			return nil, err
+		}
+		if quote, ok := fm.quote(p.asset, end); ok && quote.Date.After(p.last.Date) {
+			if quote.Price.Currency != p.currency {
+				return nil, fmt.Errorf("asset %q is quoted in %s but traded in %s", p.asset, quote.Price.Currency, p.currency)
+			}
+			h.Price, h.PriceDate = quote.Price, quote.Date
+			if h.MarketValue, err = scaleMoney(quote.Price, quantity, oneUnit); err != nil {
+				return nil, err
+			}
+		}
+		if h.UnrealizedGain, err = h.MarketValue.Sub(h.CostBasis); err != nil {
+			return nil, err
+		}
+		holdings = append(holdings, h)
+	}
+	return holdings, nil
+}
+
+// RealizedGains returns the sales in [start, end).
+func (fm *FinanceManager) RealizedGains(start, end time.Time) ([]RealizedGain, error) {
+	fm = fm.view()
+	_, gains, err := fm.replayInvestments(end)
+	if err != nil {
+		return nil, err
+	}
+	var inRange []RealizedGain
+	for _, gain := range gains {
+		if inPeriod(gain.Date, start, end) {
+			inRange = append(inRange, gain)
+		}
+	}
+	return inRange, nil
+}
+
+// reportInvestments values the holdings at the end of r and of the period
+// before it, and adds up the gains realized during r.
+func (fm *FinanceManager) reportInvestments(report *PeriodReport, r ReportRange) error {
+	current, err := fm.ValueAt(r.End.Add(-time.Nanosecond))
+	if err != nil {
+		return err
+	}
+	previous, err := fm.ValueAt(r.Start.Add(-time.Nanosecond))
+	if err != nil {
+		return err
+	}
+	report.InvestmentValue = current.MarketValue
+	report.UnrealizedGains = current.UnrealizedGain
+	report.Holdings = current.Holdings
+	report.PreviousInvestmentValue = previous.MarketValue
+	if report.InvestmentChange, err = current.MarketValue.Sub(previous.MarketValue); err != nil {
+		return err
+	}
+	for i := range report.Months {
+		month := &report.Months[i]
+		end := time.Date(month.Year, month.Month+1, 1, 0, 0, 0, 0, r.Start.Location())
+		if end.After(r.End) {
+			end = r.End
+		}
+		value, err := fm.ValueAt(end.Add(-time.Nanosecond))
+		if err != nil {
+			return err
+		}
+		month.InvestmentValue = value.MarketValue
+	}
+
+	gains, err := fm.RealizedGains(r.Start, r.End)
+	if err != nil {
+		return err
+	}
+	report.RealizedGains = Money{Currency: fm.currency}
+	for _, gain := range gains {
+		amount, err := fm.toReporting(gain.Gain, gain.Date)
+		if err != nil {
+			return err
+		}
+		if report.RealizedGains, err = report.RealizedGains.Add(amount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatHoldings(w io.Writer, holdings []Holding) {
+	for _, h := range holdings {
+		fmt.Fprintf(w, "%-20s %14s @ %-12s (%s)  value %-14s cost %-14s gain %s\n", h.Asset, h.Quantity,
+			h.Price, h.PriceDate.Format("2006-01-02"), h.MarketValue, h.CostBasis, h.UnrealizedGain)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseQuantity(t *testing.T) {
+	for input, want := range map[string]string{"10": "10", "0.5": "0.5", "1.25000000": "1.25", ".00000001": "0.00000001"} {
+		q, err := ParseQuantity(input)
+		if err != nil || q.String() != want {
+			t.Errorf("ParseQuantity(%q) = %s, %v; want %s", input, q, err, want)
+		}
+	}
+	for _, input := range []string{"", "-1", "1.123456789", "abc"} {
+		if _, err := ParseQuantity(input); err == nil {
+			t.Errorf("Expected error for quantity %q", input)
+		}
+	}
+}
+
+func newHoldingsTestManager(t *testing.T, method CostBasisMethod) *FinanceManager {
+	t.Helper()
+	fm := NewFinanceManager()
+	if err := fm.SetCostBasisMethod(method); err != nil {
+		t.Fatal(err)
+	}
+	trades := []struct {
+		date     time.Time
+		kind     InvestmentType
+		quantity string
+		price    string
+	}{
+		{date(2024, 1, 10), InvestmentBuy, "10", "100.00"},
+		{date(2024, 2, 10), InvestmentBuy, "10", "130.00"},
+		{date(2024, 3, 10), InvestmentSell, "15", "150.00"},
+	}
+	for _, trade := range trades {
+		quantity, _ := ParseQuantity(trade.quantity)
+		if _, err := fm.tradeInvestment(trade.date, "ACME", trade.kind, quantity, usd(trade.price)); err != nil {
+			t.Fatalf("Failed to record %s: %v", trade.kind, err)
+		}
+	}
+	return fm
+}
+
+func TestCostBasis(t *testing.T) {
+	tests := []struct {
+		method   CostBasisMethod
+		realized Money
+		cost     Money
+	}{
+		// FIFO sells 10 at 100 and 5 at 130; 5 at 130 remain.
+		{CostBasisFIFO, usd("600.00"), usd("650.00")},
+		// Average sells 15 at 115; 5 at 115 remain.
+		{CostBasisAverage, usd("525.00"), usd("575.00")},
+	}
+	for _, tt := range tests {
+		fm := newHoldingsTestManager(t, tt.method)
+		gains, err := fm.RealizedGains(date(2024, 1, 1), date(2025, 1, 1))
+		if err != nil || len(gains) != 1 {
+			t.Fatalf("%s: expected one realized gain, got %d (%v)", tt.method, len(gains), err)
+		}
+		if gains[0].Gain != tt.realized {
+			t.Errorf("%s: expected realized gain %s, got %s", tt.method, tt.realized, gains[0].Gain)
+		}
+
+		holdings, err := fm.Holdings(time.Time{})
+		if err != nil || len(holdings) != 1 {
+			t.Fatalf("%s: expected one holding, got %d (%v)", tt.method, len(holdings), err)
+		}
+		h := holdings[0]
+		if h.Quantity.String() != "5" || h.CostBasis != tt.cost || h.MarketValue != usd("750.00") {
+			t.Errorf("%s: expected 5 units costing %s worth $750.00, got %s costing %s worth %s",
+				tt.method, tt.cost, h.Quantity, h.CostBasis, h.MarketValue)
+		}
+	}
+}
+
+func TestSellValidation(t *testing.T) {
+	fm := newHoldingsTestManager(t, CostBasisFIFO)
+
+	if _, err := fm.SellInvestment(date(2024, 4, 1), "ACME", 6*oneUnit, usd("150.00")); err == nil {
+		t.Error("Expected error when selling more units than held")
+	}
+	buy := fm.ListInvestments()[1]
+	if err := fm.DeleteInvestment(buy.ID); err == nil {
+		t.Error("Expected error when deleting a buy that a later sale depends on")
+	}
+	if len(fm.ListInvestments()) != 3 {
+		t.Errorf("Expected failed changes to be rolled back, got %d investments", len(fm.ListInvestments()))
+	}
+}
+
+func TestReportInvestments(t *testing.T) {
+	fm := newHoldingsTestManager(t, CostBasisFIFO)
+
+	report, err := fm.BuildReport(MonthRange(2024, time.February))
+	if err != nil {
+		t.Fatalf("Failed to build report: %v", err)
+	}
+	// Revaluing at the second buy must not count the position twice.
+	if report.InvestmentValue != usd("2600.00") || report.UnrealizedGains != usd("300.00") {
+		t.Errorf("Expected February value $2600.00 with $300.00 unrealized, got %s and %s",
+			report.InvestmentValue, report.UnrealizedGains)
+	}
+	if !report.RealizedGains.IsZero() {
+		t.Errorf("Expected no realized gains in February, got %s", report.RealizedGains)
+	}
+
+	text, err := fm.GenerateMonthlyReport(2024, time.March)
+	if err != nil {
+		t.Fatalf("Failed to generate report: %v", err)
+	}
+	for _, want := range []string{"Investment Value: $750.00", "Realized Gains:   $600.00", "Unrealized Gains: $100.00", "ACME"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected report to contain %q:\n%s", want, text)
+		}
+	}
+}
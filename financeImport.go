@@ -0,0 +1,426 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ImportedTransaction is one line of a bank statement. Positive amounts
+// are credits and become income; negative amounts are debits and become
+// expenses.
+type ImportedTransaction struct {
+	Date        time.Time `json:"date"`
+	Description string    `json:"description"`
+	Amount      Money     `json:"amount"`
+	Category    string    `json:"category,omitempty"`
+}
+
+// CSVMapping describes the layout of a bank's CSV export. Columns are given
+// by header name, or by 1-based position when the file has no header.
+// Either Amount (signed) or Debit and Credit (both positive) must be set.
+type CSVMapping struct {
+	Delimiter       string `json:"delimiter"`
+	HasHeader       bool   `json:"has_header"`
+	Date            string `json:"date"`
+	DateLayout      string `json:"date_layout"`
+	Description     string `json:"description"`
+	Amount          string `json:"amount"`
+	Debit           string `json:"debit"`
+	Credit          string `json:"credit"`
+	Category        string `json:"category"`
+	Currency        string `json:"currency"`
+	DefaultCurrency string `json:"default_currency"`
+	DecimalComma    bool   `json:"decimal_comma"`
+}
+
+// DefaultCSVMapping reads "date,description,amount" files with a header.
+func DefaultCSVMapping() CSVMapping {
+	return CSVMapping{
+		Delimiter:   ",",
+		HasHeader:   true,
+		Date:        "date",
+		DateLayout:  "2006-01-02",
+		Description: "description",
+		Amount:      "amount",
+	}
+}
+
+func LoadCSVMapping(path string) (CSVMapping, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return CSVMapping{}, err
+	}
+	mapping := DefaultCSVMapping()
+	if err := json.Unmarshal(content, &mapping); err != nil {
+		return CSVMapping{}, fmt.Errorf("decoding %s: %w", path, err)
+	}
+	return mapping, nil
+}
+
+// ParseCSVStatement reads a CSV export using mapping. currency is used
+// when the mapping names neither a currency column nor a default.
+func ParseCSVStatement(r io.Reader, mapping CSVMapping, currency string) ([]ImportedTransaction, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if mapping.Delimiter != "" {
+		reader.Comma = []rune(mapping.Delimiter)[0]
+	}
+	if mapping.DateLayout == "" {
+		mapping.DateLayout = "2006-01-02"
+	}
+	if mapping.DefaultCurrency != "" {
+		currency = mapping.DefaultCurrency
+	}
+	if mapping.Date == "" || mapping.Description == "" || (mapping.Amount == "" && mapping.Debit == "" && mapping.Credit == "") {
+		return nil, fmt.Errorf("csv mapping needs date, description and amount (or debit/credit) columns")
+	}
+
+	var header []string
+	var transactions []ImportedTransaction
+	line := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line++
+		if mapping.HasHeader && header == nil {
+			header = record
+			continue
+		}
+		field := func(column string) (string, error) {
+			if column == "" {
+				return "", nil
+			}
+			i, err := csvColumnIndex(header, column)
+			if err != nil {
+				return "", err
+			}
+			if i >= len(record) {
+				return "", fmt.Errorf("missing column %q", column)
+			}
+			return strings.TrimSpace(record[i]), nil
+		}
+
+		tx, err := parseCSVRecord(field, mapping, currency)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		transactions = append(transactions, tx)
+	}
+	return transactions, nil
+}
+
+func csvColumnIndex(header []string, column string) (int, error) {
+	for i, name := range header {
+		if strings.EqualFold(strings.TrimSpace(name), column) {
+			return i, nil
+		}
+	}
+	if n, err := strconv.Atoi(column); err == nil && n > 0 {
+		return n - 1, nil
+	}
+	return 0, fmt.Errorf("unknown column %q", column)
+}
+
+func parseCSVRecord(field func(string) (string, error), mapping CSVMapping, currency string) (ImportedTransaction, error) {
+	var tx ImportedTransaction
+	dateStr, err := field(mapping.Date)
+	if err != nil {
+		return tx, err
+	}
+	if tx.Date, err = time.ParseInLocation(mapping.DateLayout, dateStr, time.Local); err != nil {
+		return tx, fmt.Errorf("invalid date %q", dateStr)
+	}
+	if tx.Description, err = field(mapping.Description); err != nil {
+		return tx, err
+	}
+	if tx.Category, err = field(mapping.Category); err != nil {
+		return tx, err
+	}
+	if code, err := field(mapping.Currency); err != nil {
+		return tx, err
+	} else if code != "" {
+		currency = strings.ToUpper(code)
+	}
+
+	parse := func(column string) (Money, error) {
+		value, err := field(column)
+		if err != nil || value == "" {
+			return Money{Currency: currency}, err
+		}
+		if mapping.DecimalComma {
+			value = strings.NewReplacer(".", "", ",", ".").Replace(value)
+		}
+		return ParseMoney(value, currency)
+	}
+	if mapping.Amount != "" {
+		tx.Amount, err = parse(mapping.Amount)
+		return tx, err
+	}
+	debit, err := parse(mapping.Debit)
+	if err != nil {
+		return tx, err
+	}
+	credit, err := parse(mapping.Credit)
+	if err != nil {
+		return tx, err
+	}
+	tx.Amount, err = credit.Sub(Money{Minor: absMinor(debit), Currency: debit.Currency})
+	return tx, err
+}
+
+// absMinor lets debit columns hold either "12.50" or "-12.50".
+func absMinor(m Money) int64 {
+	if m.Minor < 0 {
+		return -m.Minor
+	}
+	return m.Minor
+}
+
+var (
+	ofxTagPattern      = regexp.MustCompile(`<([A-Za-z0-9.]+)>([^<\r\n]*)`)
+	ofxCurrencyPattern = regexp.MustCompile(`<CURDEF>\s*([A-Za-z]{3})`)
+)
+
+// ParseOFX reads the STMTTRN records of an OFX statement, in either the
+// SGML (1.x) or XML (2.x) flavour.
+func ParseOFX(r io.Reader, currency string) ([]ImportedTransaction, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	text := string(content)
+	if m := ofxCurrencyPattern.FindStringSubmatch(text); m != nil {
+		currency = strings.ToUpper(m[1])
+	}
+
+	var transactions []ImportedTransaction
+	for _, block := range strings.Split(text, "<STMTTRN>")[1:] {
+		block, _, _ = strings.Cut(block, "</STMTTRN>")
+		fields := make(map[string]string)
+		for _, m := range ofxTagPattern.FindAllStringSubmatch(block, -1) {
+			fields[strings.ToUpper(m[1])] = strings.TrimSpace(m[2])
+		}
+
+		posted := fields["DTPOSTED"]
+		if len(posted) < 8 {
+			return nil, fmt.Errorf("transaction %q: invalid DTPOSTED %q", fields["FITID"], posted)
+		}
+		date, err := time.ParseInLocation("20060102", posted[:8], time.Local)
+		if err != nil {
+			return nil, fmt.Errorf("transaction %q: invalid DTPOSTED %q", fields["FITID"], posted)
+		}
+		amount, err := parseOFXAmount(fields["TRNAMT"], currency)
+		if err != nil {
+			return nil, fmt.Errorf("transaction %q: %w", fields["FITID"], err)
+		}
+		description := fields["NAME"]
+		if description == "" {
+			description = fields["MEMO"]
+		}
+		transactions = append(transactions, ImportedTransaction{Date: date, Description: description, Amount: amount})
+	}
+	return transactions, nil
+}
+
+// parseOFXAmount reads a TRNAMT value. OFX allows a comma as the decimal
+// separator and more decimal places than the currency has; the extra
+// places are rounded half away from zero.
+func parseOFXAmount(value, currency string) (Money, error) {
+	value = strings.TrimSpace(value)
+	if !strings.Contains(value, ".") {
+		value = strings.Replace(value, ",", ".", 1)
+	}
+	_, frac, _ := strings.Cut(value, ".")
+	exp := currencyExponent(currency)
+	if len(frac) <= exp {
+		return ParseMoney(value, currency)
+	}
+	if err := validateCurrency(currency); err != nil {
+		return Money{}, err
+	}
+	r, ok := new(big.Rat).SetString(value)
+	if !ok {
+		return Money{}, fmt.Errorf("invalid amount %q", value)
+	}
+	// One minor unit times the amount in minor units.
+	r.Mul(r, new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(exp)), nil)))
+	return Money{Minor: 1, Currency: currency}.Convert(currency, r)
+}
+
+// ImportItem is one transaction of an import preview. Skipped items have
+// a zero amount and are never stored.
+type ImportItem struct {
+	Transaction ImportedTransaction `json:"transaction"`
+	Duplicate   bool                `json:"duplicate"`
+	Skipped     bool                `json:"skipped"`
+}
+
+// ImportPlan previews an import; nothing is stored until CommitImport.
+// The entries it creates are attached to Account, if set.
+type ImportPlan struct {
+	Items   []ImportItem `json:"items"`
+	Account string       `json:"account,omitempty"`
+}
+
+func (p *ImportPlan) counts() (added, duplicates, skipped int) {
+	for _, item := range p.Items {
+		switch {
+		case item.Skipped:
+			skipped++
+		case item.Duplicate:
+			duplicates++
+		default:
+			added++
+		}
+	}
+	return added, duplicates, skipped
+}
+
+// importKey identifies a transaction by date, signed amount and description.
+func importKey(date time.Time, amount Money, description string) string {
+	description = strings.ToLower(strings.Join(strings.Fields(description), " "))
+	return date.Format("2006-01-02") + "|" + amount.Decimal() + " " + amount.Currency + "|" + description
+}
+
+// PlanImport marks transactions that are already stored, or that repeat
+// an earlier line of the same import, as duplicates, and zero amounts as
+// skipped.
+func (fm *FinanceManager) PlanImport(transactions []ImportedTransaction) *ImportPlan {
+	fm = fm.view()
+	seen := make(map[string]bool)
+	for _, income := range fm.incomes {
+		seen[importKey(income.Date, income.Amount, income.Source)] = true
+	}
+	for _, expense := range fm.expenses {
+		seen[importKey(expense.Date, expense.Amount.Neg(), expense.importDescription())] = true
+	}
+
+	plan := &ImportPlan{}
+	for _, tx := range transactions {
+		if tx.Amount.IsZero() {
+			plan.Items = append(plan.Items, ImportItem{Transaction: tx, Skipped: true})
+			continue
+		}
+		key := importKey(tx.Date, tx.Amount, tx.Description)
+		plan.Items = append(plan.Items, ImportItem{Transaction: tx, Duplicate: seen[key]})
+		seen[key] = true
+	}
+	return plan
+}
+
+func (e Expense) importDescription() string {
+	if e.Payee != "" {
+		return e.Payee
+	}
+	return e.Category
+}
+
+// CommitImport stores the items of plan that are neither duplicate nor
+// skipped in a single save.
+func (fm *FinanceManager) CommitImport(plan *ImportPlan) (int, error) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	var incomes []Income
+	var expenses []Expense
+	for _, item := range plan.Items {
+		if item.Duplicate || item.Skipped {
+			continue
+		}
+		tx := item.Transaction
+		switch {
+		case tx.Amount.IsZero():
+			return 0, fmt.Errorf("%s %q: amount must not be zero", tx.Date.Format("2006-01-02"), tx.Description)
+		case tx.Amount.IsPositive():
+			source := tx.Description
+			if strings.TrimSpace(source) == "" {
+				source = "Unknown"
+			}
+			income := Income{Date: tx.Date, Source: source, Amount: tx.Amount, Account: plan.Account}
+			if err := validateIncome(income); err != nil {
+				return 0, fmt.Errorf("%s %q: %w", tx.Date.Format("2006-01-02"), tx.Description, err)
+			}
+			incomes = append(incomes, income)
+		case tx.Amount.IsNegative():
+			expense := fm.categorize(Expense{Date: tx.Date, Category: tx.Category, Payee: tx.Description, Amount: tx.Amount.Neg(), Account: plan.Account})
+			if strings.TrimSpace(expense.Category) == "" {
+				expense.Category = "Uncategorized"
+			}
+			if err := validateExpense(expense); err != nil {
+				return 0, fmt.Errorf("%s %q: %w", tx.Date.Format("2006-01-02"), tx.Description, err)
+			}
+			expenses = append(expenses, expense)
+		}
+	}
+
+	oldIncomes, oldExpenses := fm.incomes, fm.expenses
+	for _, income := range incomes {
+		income.ID = fm.newID(incomePrefix)
+		fm.incomes = append(fm.incomes, income)
+		fm.book(income.ID)
+	}
+	for _, expense := range expenses {
+		expense.ID = fm.newID(expensePrefix)
+		fm.expenses = append(fm.expenses, expense)
+		fm.book(expense.ID)
+	}
+	err := fm.commit(func() { fm.incomes, fm.expenses = oldIncomes, oldExpenses })
+	if err != nil {
+		return 0, err
+	}
+	return len(incomes) + len(expenses), nil
+}
+
+// ReadStatement parses a statement file; format is csv or ofx, or empty to
+// pick by file extension.
+func ReadStatement(path, format string, mapping CSVMapping, currency string) ([]ImportedTransaction, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if format == "" {
+		format = "csv"
+		if lower := strings.ToLower(path); strings.HasSuffix(lower, ".ofx") || strings.HasSuffix(lower, ".qfx") {
+			format = "ofx"
+		}
+	}
+	switch format {
+	case "csv":
+		return ParseCSVStatement(f, mapping, currency)
+	case "ofx":
+		return ParseOFX(f, currency)
+	default:
+		return nil, fmt.Errorf("unknown statement format %q", format)
+	}
+}
+
+func formatImportPlan(w io.Writer, plan *ImportPlan) {
+	for _, item := range plan.Items {
+		status := "NEW"
+		switch {
+		case item.Skipped:
+			status = "SKIP"
+		case item.Duplicate:
+			status = "DUP"
+		}
+		tx := item.Transaction
+		fmt.Fprintf(w, "%-4s %s  %-30s %s\n", status, tx.Date.Format("2006-01-02"), tx.Description, tx.Amount)
+	}
+	added, duplicates, skipped := plan.counts()
+	fmt.Fprintf(w, "%d new, %d duplicate, %d skipped (zero amount)\n", added, duplicates, skipped)
+}
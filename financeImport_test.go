@@ -0,0 +1,97 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCSVImport(t *testing.T) {
+	mapping := CSVMapping{
+		Delimiter:    ";",
+		HasHeader:    true,
+		Date:         "Booking Date",
+		DateLayout:   "02.01.2006",
+		Description:  "Payee",
+		Debit:        "Debit",
+		Credit:       "Credit",
+		DecimalComma: true,
+	}
+	statement := `Booking Date;Payee;Debit;Credit
+01.03.2024;ACME Corp;;3.500,00
+02.03.2024;Landlord;1.200,00;
+02.03.2024;Landlord;1.200,00;
+03.03.2024;Card check;0,00;
+`
+	transactions, err := ParseCSVStatement(strings.NewReader(statement), mapping, "EUR")
+	if err != nil {
+		t.Fatalf("Failed to parse CSV: %v", err)
+	}
+	if len(transactions) != 4 || transactions[0].Amount != NewMoney(350000, "EUR") || transactions[1].Amount != NewMoney(-120000, "EUR") {
+		t.Fatalf("Unexpected transactions %+v", transactions)
+	}
+
+	fm := NewFinanceManager()
+	plan := fm.PlanImport(transactions)
+	if !plan.Items[2].Duplicate || plan.Items[1].Duplicate {
+		t.Errorf("Expected only the repeated line to be a duplicate, got %+v", plan.Items)
+	}
+	if added, _, skipped := plan.counts(); added != 2 || skipped != 1 {
+		t.Errorf("Expected the zero line to be previewed as skipped, got %d new and %d skipped", added, skipped)
+	}
+
+	added, err := fm.CommitImport(plan)
+	if err != nil || added != 2 {
+		t.Fatalf("Expected 2 imported entries, got %d (%v)", added, err)
+	}
+	if expenses := fm.ListExpenses(); len(expenses) != 1 || expenses[0].Payee != "Landlord" || expenses[0].Amount != NewMoney(120000, "EUR") {
+		t.Errorf("Unexpected expenses %+v", expenses)
+	}
+
+	// Importing the same statement again must not add anything.
+	plan = fm.PlanImport(transactions)
+	if added, duplicates, _ := plan.counts(); added != 0 || duplicates != 3 {
+		t.Errorf("Expected all 3 lines to be duplicates on re-import, got %d new", added)
+	}
+}
+
+func TestOFXImport(t *testing.T) {
+	statement := `OFXHEADER:100
+<OFX><BANKMSGSRSV1><STMTTRNRS><STMTRS><CURDEF>USD
+<BANKTRANLIST>
+<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20240305120000[-5:EST]<TRNAMT>-42.10<FITID>1<NAME>Grocery Store
+</STMTTRN>
+<STMTTRN>
+<TRNTYPE>CREDIT</TRNTYPE>
+<DTPOSTED>20240301</DTPOSTED>
+<TRNAMT>2500.00</TRNAMT>
+<FITID>2</FITID>
+<MEMO>Payroll</MEMO>
+</STMTTRN>
+<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20240306<TRNAMT>-12.505<FITID>3<NAME>Fuel
+</STMTTRN>
+<STMTTRN><TRNTYPE>DEBIT<DTPOSTED>20240307<TRNAMT>-7,50<FITID>4<NAME>Bakery
+</STMTTRN>
+</BANKTRANLIST></STMTRS></STMTTRNRS></BANKMSGSRSV1></OFX>`
+
+	transactions, err := ParseOFX(strings.NewReader(statement), "EUR")
+	if err != nil {
+		t.Fatalf("Failed to parse OFX: %v", err)
+	}
+	if len(transactions) != 4 {
+		t.Fatalf("Expected 4 transactions, got %d", len(transactions))
+	}
+	if tx := transactions[0]; tx.Amount != usd("-42.10") || tx.Description != "Grocery Store" ||
+		!tx.Date.Equal(time.Date(2024, 3, 5, 0, 0, 0, 0, time.Local)) {
+		t.Errorf("Unexpected first transaction %+v", tx)
+	}
+	if tx := transactions[1]; tx.Amount != usd("2500.00") || tx.Description != "Payroll" {
+		t.Errorf("Unexpected second transaction %+v", tx)
+	}
+	if tx := transactions[2]; tx.Amount != usd("-12.51") {
+		t.Errorf("Expected -12.505 to round to -$12.51, got %s", tx.Amount)
+	}
+	if tx := transactions[3]; tx.Amount != usd("-7.50") {
+		t.Errorf("Expected a decimal comma to be read as -$7.50, got %s", tx.Amount)
+	}
+}
@@ -0,0 +1,546 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+const transactionPrefix = "txn"
+
+// AccountType is given by the first segment of an account name, e.g.
+// "Assets:Brokerage" is an asset account.
+type AccountType string
+
+const (
+	AccountAssets      AccountType = "Assets"
+	AccountLiabilities AccountType = "Liabilities"
+	AccountIncome      AccountType = "Income"
+	AccountExpenses    AccountType = "Expenses"
+	AccountEquity      AccountType = "Equity"
+)
+
+// Accounts the entry wrappers post to.
+const (
+	cashAccount         = "Assets:Cash"
+	investmentsAccount  = "Assets:Investments"
+	capitalGainsAccount = "Income:Capital Gains"
+	openingBalances     = "Equity:Opening Balances"
+)
+
+// normalizeAccount checks that name starts with an account type and tidies
+// the spacing around its segments.
+func normalizeAccount(name string) (string, AccountType, error) {
+	segments := strings.Split(name, ":")
+	for i, segment := range segments {
+		segments[i] = strings.TrimSpace(segment)
+		if segments[i] == "" {
+			return "", "", fmt.Errorf("invalid account %q", name)
+		}
+	}
+	for _, kind := range []AccountType{AccountAssets, AccountLiabilities, AccountIncome, AccountExpenses, AccountEquity} {
+		if strings.EqualFold(segments[0], string(kind)) {
+			segments[0] = string(kind)
+			return strings.Join(segments, ":"), kind, nil
+		}
+	}
+	return "", "", fmt.Errorf("account %q must start with Assets, Liabilities, Income, Expenses or Equity", name)
+}
+
+// accountSegment turns a source, category or asset name into one segment
+// of an account name.
+func accountSegment(name, fallback string) string {
+	name = strings.TrimSpace(strings.ReplaceAll(name, ":", "-"))
+	if name == "" {
+		return fallback
+	}
+	return name
+}
+
+// Posting moves Amount into Account: positive amounts are debits and
+// negative amounts credits.
+type Posting struct {
+	Account string `json:"account"`
+	Amount  Money  `json:"amount"`
+}
+
+// LedgerTransaction is a balanced set of postings. Transactions booked for
+// income, expense and investment entries share the entry's ID; those of
+// account opening balances are named after the account.
+type LedgerTransaction struct {
+	ID          string    `json:"id"`
+	Date        time.Time `json:"date"`
+	Description string    `json:"description"`
+	Postings    []Posting `json:"postings"`
+}
+
+// LedgerData holds every transaction of the ledger: those booked for
+// entries and opening balances as well as those posted directly, such as
+// transfers.
+type LedgerData struct {
+	Transactions []LedgerTransaction `json:"transactions"`
+}
+
+func openingTransactionID(account string) string {
+	return "opening-" + account
+}
+
+// posted reports whether txn was posted directly rather than booked for an
+// entry or opening balance.
+func (txn LedgerTransaction) posted() bool {
+	return strings.HasPrefix(txn.ID, transactionPrefix+"-")
+}
+
+// validateTransaction normalizes the account names of txn and checks that
+// its postings add up to zero in every currency.
+func validateTransaction(txn *LedgerTransaction) error {
+	if len(txn.Postings) < 2 {
+		return fmt.Errorf("transaction needs at least two postings")
+	}
+	balance := make(map[string]int64)
+	for i := range txn.Postings {
+		posting := &txn.Postings[i]
+		var err error
+		if posting.Account, _, err = normalizeAccount(posting.Account); err != nil {
+			return err
+		}
+		if err := validateCurrency(posting.Amount.Currency); err != nil {
+			return err
+		}
+		if posting.Amount.IsZero() {
+			return fmt.Errorf("posting to %s has no amount", posting.Account)
+		}
+		sum, err := Money{Minor: balance[posting.Amount.Currency], Currency: posting.Amount.Currency}.Add(posting.Amount)
+		if err != nil {
+			return err
+		}
+		balance[posting.Amount.Currency] = sum.Minor
+	}
+	for currency, sum := range balance {
+		if sum != 0 {
+			return fmt.Errorf("transaction is off balance by %s", Money{Minor: sum, Currency: currency})
+		}
+	}
+	return nil
+}
+
+// EnableLedger turns on double-entry bookkeeping and books the existing
+// entries. It stays on once saved.
+func (fm *FinanceManager) EnableLedger() error {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	if fm.ledger != nil {
+		return nil
+	}
+	fm.ledger = &LedgerData{Transactions: make([]LedgerTransaction, 0)}
+	fm.bookAll()
+	return fm.commit(func() { fm.ledger = nil })
+}
+
+func (fm *FinanceManager) LedgerEnabled() bool {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+	return fm.ledger != nil
+}
+
+// PostTransaction stores a balanced transaction under a new ID.
+func (fm *FinanceManager) PostTransaction(txn LedgerTransaction) (LedgerTransaction, error) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	return fm.postTransaction(txn)
+}
+
+func (fm *FinanceManager) postTransaction(txn LedgerTransaction) (LedgerTransaction, error) {
+	if fm.ledger == nil {
+		return LedgerTransaction{}, invalid(fmt.Errorf("the ledger is not enabled"))
+	}
+	txn.Postings = append([]Posting(nil), txn.Postings...)
+	if err := validateTransaction(&txn); err != nil {
+		return LedgerTransaction{}, invalid(err)
+	}
+	txn.ID = fm.newID(transactionPrefix)
+	old := fm.ledger.Transactions
+	fm.ledger.Transactions = append(old[:len(old):len(old)], txn)
+	if err := fm.commit(func() { fm.ledger.Transactions = old }); err != nil {
+		return LedgerTransaction{}, err
+	}
+	return txn, nil
+}
+
+// Transfer moves amount from one account to another, e.g. cash into a
+// brokerage account or an opening balance out of equity.
+func (fm *FinanceManager) Transfer(date time.Time, from, to string, amount Money, description string) (LedgerTransaction, error) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	if !amount.IsPositive() {
+		return LedgerTransaction{}, invalid(fmt.Errorf("amount must be positive"))
+	}
+	return fm.postTransaction(LedgerTransaction{
+		Date:        date,
+		Description: description,
+		Postings:    []Posting{{Account: fm.transferAccount(to), Amount: amount}, {Account: fm.transferAccount(from), Amount: amount.Neg()}},
+	})
+}
+
+func (fm *FinanceManager) DeleteTransaction(id string) error {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	if fm.ledger != nil {
+		for i, txn := range fm.ledger.Transactions {
+			if txn.ID == id && txn.posted() {
+				old := fm.ledger.Transactions
+				fm.ledger.Transactions = append(append([]LedgerTransaction(nil), old[:i]...), old[i+1:]...)
+				return fm.commit(func() { fm.ledger.Transactions = old })
+			}
+		}
+	}
+	return &NotFoundError{Kind: "transaction", ID: id}
+}
+
+// LedgerTransactions returns every transaction, those of entries included,
+// in date order.
+func (fm *FinanceManager) LedgerTransactions() ([]LedgerTransaction, error) {
+	return fm.view().ledgerTransactions()
+}
+
+func (fm *FinanceManager) ledgerTransactions() ([]LedgerTransaction, error) {
+	if fm.ledger == nil {
+		return nil, fmt.Errorf("the ledger is not enabled")
+	}
+	txns := append([]LedgerTransaction(nil), fm.ledger.Transactions...)
+	sort.SliceStable(txns, func(i, j int) bool { return txns[i].Date.Before(txns[j].Date) })
+	return txns, nil
+}
+
+// book marks entries, by ID, whose transactions the next commit books
+// again from their current state. It does nothing while the ledger is
+// disabled.
+func (fm *FinanceManager) book(ids ...string) {
+	if fm.ledger != nil {
+		fm.unbooked = append(fm.unbooked, ids...)
+	}
+}
+
+// bookAll marks every entry and opening balance.
+func (fm *FinanceManager) bookAll() {
+	for _, income := range fm.incomes {
+		fm.book(income.ID)
+	}
+	for _, expense := range fm.expenses {
+		fm.book(expense.ID)
+	}
+	for _, investment := range fm.investments {
+		fm.book(investment.ID)
+	}
+	for _, account := range fm.accounts {
+		fm.book(openingTransactionID(account.Name))
+	}
+}
+
+// bookMissing marks the entries that have no transaction yet, as in data
+// saved before entries were booked.
+func (fm *FinanceManager) bookMissing() {
+	if fm.ledger == nil {
+		return
+	}
+	booked := make(map[string]bool)
+	for _, txn := range fm.ledger.Transactions {
+		booked[txn.ID] = true
+	}
+	fm.bookAll()
+	missing := fm.unbooked[:0]
+	for _, id := range fm.unbooked {
+		if !booked[id] {
+			missing = append(missing, id)
+		}
+	}
+	fm.unbooked = missing
+}
+
+// bookLedger replaces the transactions of the marked entries with ones
+// built from their current state, dropping those of deleted entries, and
+// checks each transaction that changed. Every stored transaction was
+// checked when it was booked, so the books stay balanced without going
+// over them again. A trade rebooks every trade, since the cost basis of a
+// sale depends on the trades before it.
+func (fm *FinanceManager) bookLedger() error {
+	ids := fm.unbooked
+	fm.unbooked = nil
+	if fm.ledger == nil || len(ids) == 0 {
+		return nil
+	}
+
+	built := make(map[string]*LedgerTransaction)
+	var gains map[string]RealizedGain
+	for _, id := range ids {
+		built[id] = nil
+		if strings.HasPrefix(id, investmentPrefix+"-") && gains == nil {
+			_, realized, err := fm.replayInvestments(time.Time{})
+			if err != nil {
+				return err
+			}
+			gains = make(map[string]RealizedGain)
+			for _, gain := range realized {
+				gains[gain.InvestmentID] = gain
+			}
+		}
+	}
+	set := func(txn LedgerTransaction) {
+		if _, ok := built[txn.ID]; !ok {
+			return
+		}
+		// Zero postings, such as the cost of a sale too small to round to
+		// a cent, carry nothing.
+		postings := make([]Posting, 0, len(txn.Postings))
+		for _, posting := range txn.Postings {
+			if !posting.Amount.IsZero() {
+				postings = append(postings, posting)
+			}
+		}
+		if txn.Postings = postings; len(postings) > 0 {
+			built[txn.ID] = &txn
+		}
+	}
+	for _, account := range fm.accounts {
+		set(fm.openingTransaction(account))
+	}
+	for _, income := range fm.incomes {
+		set(fm.incomeTransaction(income))
+	}
+	for _, expense := range fm.expenses {
+		set(fm.expenseTransaction(expense))
+	}
+	for _, investment := range fm.investments {
+		if gains != nil {
+			built[investment.ID] = nil
+		}
+		set(fm.investmentTransaction(investment, gains[investment.ID]))
+	}
+	for id, txn := range built {
+		if txn != nil {
+			if err := validateTransaction(txn); err != nil {
+				return fmt.Errorf("transaction %s: %w", id, err)
+			}
+		}
+	}
+
+	txns := make([]LedgerTransaction, 0, len(fm.ledger.Transactions)+len(built))
+	for _, txn := range fm.ledger.Transactions {
+		if rebuilt, ok := built[txn.ID]; ok && !txn.posted() {
+			if rebuilt != nil {
+				txns = append(txns, *rebuilt)
+			}
+			delete(built, txn.ID)
+			continue
+		}
+		txns = append(txns, txn)
+	}
+	added := make([]string, 0, len(built))
+	for id, txn := range built {
+		if txn != nil {
+			added = append(added, id)
+		}
+	}
+	sort.Strings(added)
+	for _, id := range added {
+		txns = append(txns, *built[id])
+	}
+	fm.ledger.Transactions = txns
+	return nil
+}
+
+// openingTransaction books an account's opening balance out of equity.
+func (fm *FinanceManager) openingTransaction(account Account) LedgerTransaction {
+	return LedgerTransaction{
+		ID:          openingTransactionID(account.Name),
+		Date:        account.Opened,
+		Description: "Opening balance",
+		Postings: []Posting{
+			{account.ledgerAccount(), account.OpeningBalance},
+			{openingBalances, account.OpeningBalance.Neg()},
+		},
+	}
+}
+
+// incomeTransaction books income to the entry's account, or to cash.
+func (fm *FinanceManager) incomeTransaction(income Income) LedgerTransaction {
+	return LedgerTransaction{
+		ID:          income.ID,
+		Date:        income.Date,
+		Description: income.Source,
+		Postings: []Posting{
+			{fm.entryLedgerAccount(income.Account), income.Amount},
+			{"Income:" + accountSegment(income.Source, "Other"), income.Amount.Neg()},
+		},
+	}
+}
+
+// expenseTransaction books an expense from the entry's account, or cash.
+func (fm *FinanceManager) expenseTransaction(expense Expense) LedgerTransaction {
+	return LedgerTransaction{
+		ID:          expense.ID,
+		Date:        expense.Date,
+		Description: expense.importDescription(),
+		Postings: []Posting{
+			{"Expenses:" + accountSegment(expense.Category, "Uncategorized"), expense.Amount},
+			{fm.entryLedgerAccount(expense.Account), expense.Amount.Neg()},
+		},
+	}
+}
+
+// investmentTransaction books a trade between the entry's account and the
+// asset's account at cost. A sale books the difference to capital gains.
+func (fm *FinanceManager) investmentTransaction(investment Investment, gain RealizedGain) LedgerTransaction {
+	account := investmentsAccount + ":" + accountSegment(investment.Asset, "Other")
+	cash := fm.entryLedgerAccount(investment.Account)
+	txn := LedgerTransaction{
+		ID:          investment.ID,
+		Date:        investment.Date,
+		Description: fmt.Sprintf("%s %s %s", investment.kind(), investment.units(), investment.Asset),
+	}
+	if investment.kind() == InvestmentBuy {
+		txn.Postings = []Posting{{account, investment.Value}, {cash, investment.Value.Neg()}}
+	} else {
+		txn.Postings = []Posting{{cash, investment.Value}, {account, gain.CostBasis.Neg()}, {capitalGainsAccount, gain.Gain.Neg()}}
+	}
+	return txn
+}
+
+// transferAccount lets transfers name an account from ListAccounts instead
+// of its ledger account.
+func (fm *FinanceManager) transferAccount(name string) string {
+	if i := fm.accountIndex(name); i >= 0 {
+		return fm.accounts[i].ledgerAccount()
+	}
+	return name
+}
+
+func (fm *FinanceManager) entryLedgerAccount(name string) string {
+	if i := fm.accountIndex(name); name != "" && i >= 0 {
+		return fm.accounts[i].ledgerAccount()
+	}
+	return cashAccount
+}
+
+// AccountBalance is the balance of one account in one currency. Debit
+// balances are positive.
+type AccountBalance struct {
+	Account string      `json:"account"`
+	Type    AccountType `json:"type"`
+	Balance Money       `json:"balance"`
+}
+
+type TrialBalance struct {
+	Accounts []AccountBalance `json:"accounts"`
+	Debits   []Money          `json:"debits"`
+	Credits  []Money          `json:"credits"`
+	Balanced bool             `json:"balanced"`
+}
+
+// TrialBalance totals every account. Debits equal credits in each currency
+// when the books are consistent.
+func (fm *FinanceManager) TrialBalance() (*TrialBalance, error) {
+	return fm.view().trialBalance()
+}
+
+func (fm *FinanceManager) trialBalance() (*TrialBalance, error) {
+	txns, err := fm.ledgerTransactions()
+	if err != nil {
+		return nil, err
+	}
+
+	balances := make(map[string]*AccountBalance)
+	for _, txn := range txns {
+		for _, posting := range txn.Postings {
+			name, kind, err := normalizeAccount(posting.Account)
+			if err != nil {
+				return nil, fmt.Errorf("transaction %s: %w", txn.ID, err)
+			}
+			key := name + "|" + posting.Amount.Currency
+			balance, ok := balances[key]
+			if !ok {
+				balance = &AccountBalance{Account: name, Type: kind, Balance: Money{Currency: posting.Amount.Currency}}
+				balances[key] = balance
+			}
+			if balance.Balance, err = balance.Balance.Add(posting.Amount); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	tb := &TrialBalance{Balanced: true}
+	debits, credits := make(map[string]int64), make(map[string]int64)
+	for _, balance := range balances {
+		tb.Accounts = append(tb.Accounts, *balance)
+		if balance.Balance.IsNegative() {
+			credits[balance.Balance.Currency] -= balance.Balance.Minor
+		} else {
+			debits[balance.Balance.Currency] += balance.Balance.Minor
+		}
+	}
+	sort.Slice(tb.Accounts, func(i, j int) bool {
+		if tb.Accounts[i].Account != tb.Accounts[j].Account {
+			return tb.Accounts[i].Account < tb.Accounts[j].Account
+		}
+		return tb.Accounts[i].Balance.Currency < tb.Accounts[j].Balance.Currency
+	})
+
+	currencies := make(map[string]bool)
+	for currency := range debits {
+		currencies[currency] = true
+	}
+	for currency := range credits {
+		currencies[currency] = true
+	}
+	var codes []string
+	for currency := range currencies {
+		codes = append(codes, currency)
+	}
+	sort.Strings(codes)
+	for _, currency := range codes {
+		tb.Debits = append(tb.Debits, Money{Minor: debits[currency], Currency: currency})
+		tb.Credits = append(tb.Credits, Money{Minor: credits[currency], Currency: currency})
+		if debits[currency] != credits[currency] {
+			tb.Balanced = false
+		}
+	}
+	return tb, nil
+}
+
+// checkLedger books the entries changed since the last commit before
+// every save while the ledger is enabled, so unbalanced transactions are
+// never written.
+func (fm *FinanceManager) checkLedger() error {
+	return fm.bookLedger()
+}
+
+func formatTrialBalance(w io.Writer, tb *TrialBalance) {
+	for _, balance := range tb.Accounts {
+		debit, credit := "", ""
+		if balance.Balance.IsNegative() {
+			credit = balance.Balance.Neg().String()
+		} else {
+			debit = balance.Balance.String()
+		}
+		fmt.Fprintf(w, "%-40s %14s %14s\n", balance.Account, debit, credit)
+	}
+	for i := range tb.Debits {
+		fmt.Fprintf(w, "%-40s %14s %14s\n", "Total", tb.Debits[i], tb.Credits[i])
+	}
+	if tb.Balanced {
+		fmt.Fprintln(w, "Balanced")
+	} else {
+		fmt.Fprintln(w, "NOT BALANCED")
+	}
+}
+
+func formatLedger(w io.Writer, txns []LedgerTransaction) {
+	for _, txn := range txns {
+		fmt.Fprintf(w, "%s %-8s %s\n", txn.Date.Format("2006-01-02"), txn.ID, txn.Description)
+		for _, posting := range txn.Postings {
+			fmt.Fprintf(w, "    %-40s %14s\n", posting.Account, posting.Amount)
+		}
+	}
+}
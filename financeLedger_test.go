@@ -0,0 +1,172 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// bookedTransaction returns the stored ledger transaction with id.
+func bookedTransaction(fm *FinanceManager, id string) (LedgerTransaction, bool) {
+	for _, txn := range fm.ledger.Transactions {
+		if txn.ID == id {
+			return txn, true
+		}
+	}
+	return LedgerTransaction{}, false
+}
+
+func TestLedgerWrappers(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "finance.json")
+	fm, err := NewFinanceManagerWithStore(NewJSONFileStore(path))
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	if err := fm.EnableLedger(); err != nil {
+		t.Fatalf("Failed to enable ledger: %v", err)
+	}
+
+	fm.AddIncome(date(2024, 1, 1), "Salary", usd("5000.00"))
+	fm.AddExpense(date(2024, 1, 2), "Rent", usd("1200.00"))
+	if _, err := fm.Transfer(date(2024, 1, 3), cashAccount, "assets : Brokerage", usd("1000.00"), "Fund broker"); err != nil {
+		t.Fatalf("Failed to transfer: %v", err)
+	}
+	fm.BuyInvestment(date(2024, 1, 4), "ACME", 10*oneUnit, usd("100.00"))
+	fm.SellInvestment(date(2024, 2, 1), "ACME", 4*oneUnit, usd("125.00"))
+
+	reopened, err := NewFinanceManagerWithStore(NewJSONFileStore(path))
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	if !reopened.LedgerEnabled() {
+		t.Fatal("Expected the ledger to stay enabled after reopening")
+	}
+	tb, err := reopened.TrialBalance()
+	if err != nil {
+		t.Fatalf("Failed to compute trial balance: %v", err)
+	}
+	if !tb.Balanced {
+		t.Errorf("Expected balanced books, got debits %v and credits %v", tb.Debits, tb.Credits)
+	}
+
+	want := map[string]Money{
+		"Assets:Brokerage":        usd("1000.00"),
+		"Assets:Cash":             usd("2300.00"),
+		"Assets:Investments:ACME": usd("600.00"),
+		"Expenses:Rent":           usd("1200.00"),
+		"Income:Capital Gains":    usd("-100.00"),
+		"Income:Salary":           usd("-5000.00"),
+	}
+	if len(tb.Accounts) != len(want) {
+		t.Errorf("Expected %d accounts, got %+v", len(want), tb.Accounts)
+	}
+	for _, balance := range tb.Accounts {
+		if want[balance.Account] != balance.Balance {
+			t.Errorf("Expected %s balance %s, got %s", balance.Account, want[balance.Account], balance.Balance)
+		}
+	}
+}
+
+func TestPostTransactionValidation(t *testing.T) {
+	fm := NewFinanceManager()
+	txn := LedgerTransaction{Date: date(2024, 1, 1), Postings: []Posting{
+		{"Assets:Checking", usd("100.00")},
+		{openingBalances, usd("-100.00")},
+	}}
+	if _, err := fm.PostTransaction(txn); err == nil {
+		t.Error("Expected error while the ledger is disabled")
+	}
+	fm.EnableLedger()
+
+	unbalanced := txn
+	unbalanced.Postings = []Posting{{"Assets:Checking", usd("100.00")}, {openingBalances, usd("-90.00")}}
+	if _, err := fm.PostTransaction(unbalanced); err == nil {
+		t.Error("Expected error for an unbalanced transaction")
+	}
+	unknown := txn
+	unknown.Postings = []Posting{{"Checking", usd("100.00")}, {openingBalances, usd("-100.00")}}
+	if _, err := fm.PostTransaction(unknown); err == nil {
+		t.Error("Expected error for an account without a type")
+	}
+
+	posted, err := fm.PostTransaction(txn)
+	if err != nil {
+		t.Fatalf("Failed to post transaction: %v", err)
+	}
+	if err := fm.Delete(posted.ID); err != nil {
+		t.Errorf("Failed to delete transaction: %v", err)
+	}
+	if txns, _ := fm.LedgerTransactions(); len(txns) != 0 {
+		t.Errorf("Expected no transactions after delete, got %d", len(txns))
+	}
+}
+
+func TestLedgerBooksEntryChanges(t *testing.T) {
+	fm := NewFinanceManager()
+	fm.AddExpense(date(2024, 1, 2), "Rent", usd("1200.00"))
+	if err := fm.EnableLedger(); err != nil {
+		t.Fatalf("Failed to enable ledger: %v", err)
+	}
+	expense := fm.ListExpenses()[0]
+	if _, ok := bookedTransaction(fm, expense.ID); !ok {
+		t.Fatal("Expected enabling the ledger to book existing entries")
+	}
+
+	expense.Category = "Housing"
+	if err := fm.UpdateExpense(expense); err != nil {
+		t.Fatalf("Failed to update expense: %v", err)
+	}
+	if txn, _ := bookedTransaction(fm, expense.ID); txn.Postings[0].Account != "Expenses:Housing" {
+		t.Errorf("Expected the transaction to follow the new category, got %+v", txn.Postings)
+	}
+	if err := fm.DeleteExpense(expense.ID); err != nil {
+		t.Fatalf("Failed to delete expense: %v", err)
+	}
+	if len(fm.ledger.Transactions) != 0 {
+		t.Errorf("Expected the transaction to go with its entry, got %+v", fm.ledger.Transactions)
+	}
+	if err := fm.Undo(); err != nil {
+		t.Fatalf("Failed to undo: %v", err)
+	}
+	if _, ok := bookedTransaction(fm, expense.ID); !ok {
+		t.Error("Expected undo to bring the transaction back")
+	}
+}
+
+func TestLedgerDropsZeroPostings(t *testing.T) {
+	fm := NewFinanceManager()
+	fm.EnableLedger()
+	if _, err := fm.BuyInvestment(date(2024, 1, 1), "ACME", oneUnit, usd("0.01")); err != nil {
+		t.Fatalf("Failed to buy: %v", err)
+	}
+	// A third of a cent of cost basis rounds to nothing.
+	sale, err := fm.SellInvestment(date(2024, 2, 1), "ACME", oneUnit/3, usd("0.03"))
+	if err != nil {
+		t.Fatalf("Failed to sell: %v", err)
+	}
+	if txn, _ := bookedTransaction(fm, sale.ID); len(txn.Postings) != 2 {
+		t.Errorf("Expected the zero cost posting to be dropped, got %+v", txn.Postings)
+	}
+	if err := fm.AddIncome(date(2024, 3, 1), "Salary", usd("100.00")); err != nil {
+		t.Errorf("Expected later changes to still commit, got %v", err)
+	}
+}
+
+func TestLedgerBooksEntriesSavedWithoutTransactions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "finance.json")
+	store := NewJSONFileStore(path)
+	data := &FinanceData{
+		Incomes: []Income{{ID: "inc-1", Date: date(2024, 1, 1), Source: "Salary", Amount: usd("100.00")}},
+		Ledger:  &LedgerData{Transactions: []LedgerTransaction{}},
+		NextID:  1,
+	}
+	if err := store.Save(data); err != nil {
+		t.Fatal(err)
+	}
+	fm, err := NewFinanceManagerWithStore(store)
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	if _, ok := bookedTransaction(fm, "inc-1"); !ok {
+		t.Error("Expected the income to be booked on load")
+	}
+}
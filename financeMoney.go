@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const defaultCurrency = "USD"
+
+// Money is an exact amount stored in the minor units of its currency
+// (cents for USD, yen for JPY).
+type Money struct {
+	Minor    int64
+	Currency string
+}
+
+// currencyExponents lists currencies whose minor unit is not 1/100.
+var currencyExponents = map[string]int{
+	"JPY": 0,
+	"KRW": 0,
+	"VND": 0,
+	"BHD": 3,
+	"KWD": 3,
+	"OMR": 3,
+	"TND": 3,
+}
+
+var currencySymbols = map[string]string{
+	"USD": "$",
+	"EUR": "€",
+	"GBP": "£",
+	"JPY": "¥",
+}
+
+func currencyExponent(currency string) int {
+	if exp, ok := currencyExponents[currency]; ok {
+		return exp
+	}
+	return 2
+}
+
+func validateCurrency(currency string) error {
+	if len(currency) != 3 {
+		return fmt.Errorf("invalid currency code %q", currency)
+	}
+	for _, r := range currency {
+		if r < 'A' || r > 'Z' {
+			return fmt.Errorf("invalid currency code %q", currency)
+		}
+	}
+	return nil
+}
+
+func NewMoney(minor int64, currency string) Money {
+	return Money{Minor: minor, Currency: currency}
+}
+
+// ParseMoney parses user input such as "1,234.56", "$12" or "-3.5 EUR".
+// An ISO code in the input overrides currency; a symbol must match it.
+func ParseMoney(input, currency string) (Money, error) {
+	s := strings.TrimSpace(input)
+	if fields := strings.Fields(s); len(fields) == 2 {
+		if validateCurrency(fields[0]) == nil {
+			currency, s = fields[0], fields[1]
+		} else if validateCurrency(fields[1]) == nil {
+			s, currency = fields[0], fields[1]
+		}
+	}
+	if err := validateCurrency(currency); err != nil {
+		return Money{}, err
+	}
+
+	negative := false
+	if strings.HasPrefix(s, "-") {
+		negative, s = true, s[1:]
+	} else if strings.HasPrefix(s, "+") {
+		s = s[1:]
+	}
+	if symbol, ok := currencySymbols[currency]; ok {
+		s = strings.TrimPrefix(s, symbol)
+	}
+	s = strings.ReplaceAll(s, ",", "")
+
+	whole, frac, _ := strings.Cut(s, ".")
+	exp := currencyExponent(currency)
+	if whole == "" && frac == "" {
+		return Money{}, fmt.Errorf("invalid amount %q", input)
+	}
+	if len(frac) > exp {
+		return Money{}, fmt.Errorf("invalid amount %q: %s allows %d decimal places", input, currency, exp)
+	}
+	if !isDigits(whole) || !isDigits(frac) {
+		return Money{}, fmt.Errorf("invalid amount %q", input)
+	}
+	frac += strings.Repeat("0", exp-len(frac))
+
+	minor, err := strconv.ParseInt("0"+whole+frac, 10, 64)
+	if err != nil {
+		return Money{}, fmt.Errorf("invalid amount %q: out of range", input)
+	}
+	if negative {
+		minor = -minor
+	}
+	return Money{Minor: minor, Currency: currency}, nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func (m Money) IsZero() bool     { return m.Minor == 0 }
+func (m Money) IsPositive() bool { return m.Minor > 0 }
+func (m Money) IsNegative() bool { return m.Minor < 0 }
+
+func (m Money) Neg() Money {
+	return Money{Minor: -m.Minor, Currency: m.Currency}
+}
+
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, fmt.Errorf("cannot add %s to %s", other.Currency, m.Currency)
+	}
+	sum := m.Minor + other.Minor
+	if (other.Minor > 0 && sum < m.Minor) || (other.Minor < 0 && sum > m.Minor) {
+		return Money{}, fmt.Errorf("amount overflow")
+	}
+	return Money{Minor: sum, Currency: m.Currency}, nil
+}
+
+func (m Money) Sub(other Money) (Money, error) {
+	if other.Minor == math.MinInt64 {
+		return Money{}, fmt.Errorf("amount overflow")
+	}
+	return m.Add(other.Neg())
+}
+
+// Cmp returns -1, 0 or 1; amounts in different currencies are not comparable.
+func (m Money) Cmp(other Money) (int, error) {
+	if m.Currency != other.Currency {
+		return 0, fmt.Errorf("cannot compare %s with %s", m.Currency, other.Currency)
+	}
+	switch {
+	case m.Minor < other.Minor:
+		return -1, nil
+	case m.Minor > other.Minor:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Decimal formats the amount without a currency, e.g. "-1234.50".
+func (m Money) Decimal() string {
+	exp := currencyExponent(m.Currency)
+	minor := m.Minor
+	sign := ""
+	if minor < 0 {
+		sign = "-"
+	}
+	digits := strconv.FormatUint(absInt64(minor), 10)
+	if exp == 0 {
+		return sign + digits
+	}
+	if len(digits) <= exp {
+		digits = strings.Repeat("0", exp-len(digits)+1) + digits
+	}
+	return sign + digits[:len(digits)-exp] + "." + digits[len(digits)-exp:]
+}
+
+func absInt64(n int64) uint64 {
+	if n < 0 {
+		return uint64(-(n + 1)) + 1
+	}
+	return uint64(n)
+}
+
+// String formats the amount with its currency symbol, e.g. "$12.50" or
+// "12.50 CHF" for currencies without a known symbol.
+func (m Money) String() string {
+	symbol, ok := currencySymbols[m.Currency]
+	if !ok {
+		return m.Decimal() + " " + m.Currency
+	}
+	if m.Minor < 0 {
+		return "-" + symbol + m.Neg().Decimal()
+	}
+	return symbol + m.Decimal()
+}
+
+type moneyJSON struct {
+	Amount   string `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return json.Marshal(moneyJSON{Amount: m.Decimal(), Currency: m.Currency})
+}
+
+func (m *Money) UnmarshalJSON(b []byte) error {
+	var raw moneyJSON
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	parsed, err := ParseMoney(raw.Amount, raw.Currency)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
+
+// sumMoney adds up amounts into a total in currency.
+func sumMoney(currency string, amounts ...Money) (Money, error) {
+	total := Money{Currency: currency}
+	for _, amount := range amounts {
+		var err error
+		if total, err = total.Add(amount); err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
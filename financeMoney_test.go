@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func usd(amount string) Money {
+	m, err := ParseMoney(amount, "USD")
+	if err != nil {
+		panic(err)
+	}
+	return m
+}
+
+func TestParseMoney(t *testing.T) {
+	tests := []struct {
+		input    string
+		currency string
+		want     Money
+	}{
+		{"12", "USD", NewMoney(1200, "USD")},
+		{"1,234.56", "USD", NewMoney(123456, "USD")},
+		{"$0.5", "USD", NewMoney(50, "USD")},
+		{"-$3.10", "USD", NewMoney(-310, "USD")},
+		{"12.50 EUR", "USD", NewMoney(1250, "EUR")},
+		{"1500", "JPY", NewMoney(1500, "JPY")},
+		{"1.234", "KWD", NewMoney(1234, "KWD")},
+	}
+	for _, tt := range tests {
+		got, err := ParseMoney(tt.input, tt.currency)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseMoney(%q, %q) = %v, %v; want %v", tt.input, tt.currency, got, err, tt.want)
+		}
+	}
+
+	for _, input := range []string{"", "abc", "1.234", "1.2.3", "€5", "12 usd"} {
+		if _, err := ParseMoney(input, "USD"); err == nil {
+			t.Errorf("Expected error parsing %q", input)
+		}
+	}
+}
+
+func TestMoneyArithmetic(t *testing.T) {
+	// 0.1 + 0.2 drifts with float64; minor units must not.
+	total, err := sumMoney("USD", usd("0.10"), usd("0.20"))
+	if err != nil || total != usd("0.30") {
+		t.Errorf("Expected $0.30, got %s (%v)", total, err)
+	}
+
+	if _, err := usd("1.00").Add(NewMoney(100, "EUR")); err == nil {
+		t.Error("Expected error adding different currencies")
+	}
+
+	diff, _ := usd("1.00").Sub(usd("2.50"))
+	if diff.String() != "-$1.50" {
+		t.Errorf("Expected -$1.50, got %s", diff)
+	}
+	if s := NewMoney(5, "CHF").String(); s != "0.05 CHF" {
+		t.Errorf("Expected 0.05 CHF, got %s", s)
+	}
+}
+
+func TestMoneyJSON(t *testing.T) {
+	b, err := json.Marshal(usd("1234.50"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"amount":"1234.50","currency":"USD"}` {
+		t.Errorf("Unexpected JSON %s", b)
+	}
+	var m Money
+	if err := json.Unmarshal(b, &m); err != nil || m != usd("1234.50") {
+		t.Errorf("Expected round trip, got %v (%v)", m, err)
+	}
+}
@@ -0,0 +1,412 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// A4 in points, and the margin kept free on every side.
+const (
+	pdfPageWidth  = 595
+	pdfPageHeight = 842
+	pdfMargin     = 50
+)
+
+// Glyph widths of the standard Helvetica fonts for ASCII 32 to 126, in
+// thousandths of the font size. Other characters count as pdfDefaultWidth.
+var (
+	helveticaWidths = [95]int{
+		278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556,
+		1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556,
+		333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556,
+		556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584,
+	}
+	helveticaBoldWidths = [95]int{
+		278, 333, 474, 556, 556, 889, 722, 238, 333, 333, 389, 584, 278, 333, 278, 278,
+		556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 333, 333, 584, 584, 584, 611,
+		975, 722, 722, 722, 722, 667, 611, 778, 722, 278, 556, 722, 611, 833, 722, 778,
+		667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 333, 278, 333, 584, 556,
+		333, 556, 611, 556, 611, 556, 333, 611, 611, 278, 278, 556, 278, 889, 611, 611,
+		611, 611, 389, 556, 333, 611, 556, 778, 556, 556, 500, 389, 280, 389, 584,
+	}
+)
+
+const pdfDefaultWidth = 556
+
+// winAnsiExtras are the characters WinAnsiEncoding places in 0x80-0x9F.
+var winAnsiExtras = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87, 'ˆ': 0x88,
+	'‰': 0x89, 'Š': 0x8A, '‹': 0x8B, 'Œ': 0x8C, 'Ž': 0x8E, '‘': 0x91, '’': 0x92, '“': 0x93,
+	'”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '˜': 0x98, '™': 0x99, 'š': 0x9A, '›': 0x9B,
+	'œ': 0x9C, 'ž': 0x9E, 'Ÿ': 0x9F,
+}
+
+// pdfString quotes s as a PDF literal string in WinAnsiEncoding, the
+// encoding the standard fonts are declared with. Characters it cannot
+// represent become '?'.
+func pdfString(s string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, r := range s {
+		var c byte
+		switch {
+		case r >= 32 && r < 127, r >= 0xA0 && r <= 0xFF:
+			c = byte(r)
+		default:
+			var ok bool
+			if c, ok = winAnsiExtras[r]; !ok {
+				c = '?'
+			}
+		}
+		switch {
+		case c == '(' || c == ')' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c > 126:
+			fmt.Fprintf(&b, "\\%03o", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+func pdfTextWidth(s string, size float64, bold bool) float64 {
+	widths := &helveticaWidths
+	if bold {
+		widths = &helveticaBoldWidths
+	}
+	total := 0
+	for _, r := range s {
+		if r >= 32 && r < 127 {
+			total += widths[r-32]
+		} else {
+			total += pdfDefaultWidth
+		}
+	}
+	return float64(total) * size / 1000
+}
+
+// pdfFit shortens s with an ellipsis until it is at most width wide.
+func pdfFit(s string, size float64, bold bool, width float64) string {
+	if pdfTextWidth(s, size, bold) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && pdfTextWidth(string(runes)+"...", size, bold) > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
+
+// pdfNumber formats a coordinate without needless decimals.
+func pdfNumber(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+// pdfColor turns "#rrggbb" into PDF color operands.
+func pdfColor(hex string) string {
+	n, err := strconv.ParseUint(strings.TrimPrefix(hex, "#"), 16, 32)
+	if err != nil {
+		return "0 0 0"
+	}
+	channel := func(shift uint) string { return pdfNumber(float64(n>>shift&0xFF) / 255) }
+	return channel(16) + " " + channel(8) + " " + channel(0)
+}
+
+// pdfWriter lays out text, lines and boxes on A4 pages from top to bottom.
+// y is the baseline of the next line, in PDF coordinates from the bottom.
+type pdfWriter struct {
+	pages []*bytes.Buffer
+	page  *bytes.Buffer
+	y     float64
+}
+
+func newPDFWriter() *pdfWriter {
+	p := &pdfWriter{}
+	p.newPage()
+	return p
+}
+
+func (p *pdfWriter) newPage() {
+	p.page = &bytes.Buffer{}
+	p.pages = append(p.pages, p.page)
+	p.y = pdfPageHeight - pdfMargin
+}
+
+// need starts a new page unless height points are left above the margin.
+func (p *pdfWriter) need(height float64) {
+	if p.y-height < pdfMargin {
+		p.newPage()
+	}
+}
+
+func (p *pdfWriter) text(x, y, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(p.page, "BT 0 g /%s %s Tf %s %s Td %s Tj ET\n", font, pdfNumber(size), pdfNumber(x), pdfNumber(y), pdfString(s))
+}
+
+func (p *pdfWriter) textRight(right, y, size float64, bold bool, s string) {
+	p.text(right-pdfTextWidth(s, size, bold), y, size, bold, s)
+}
+
+func (p *pdfWriter) textCenter(center, y, size float64, bold bool, s string) {
+	p.text(center-pdfTextWidth(s, size, bold)/2, y, size, bold, s)
+}
+
+func (p *pdfWriter) line(x1, y1, x2, y2 float64, color string) {
+	fmt.Fprintf(p.page, "%s RG %s %s m %s %s l S\n", pdfColor(color), pdfNumber(x1), pdfNumber(y1), pdfNumber(x2), pdfNumber(y2))
+}
+
+func (p *pdfWriter) rect(x, y, width, height float64, color string) {
+	fmt.Fprintf(p.page, "%s rg %s %s %s %s re f\n", pdfColor(color), pdfNumber(x), pdfNumber(y), pdfNumber(width), pdfNumber(height))
+}
+
+// polyline strokes a path through points given as x, y pairs.
+func (p *pdfWriter) polyline(points []float64, color string) {
+	if len(points) < 4 {
+		return
+	}
+	fmt.Fprintf(p.page, "%s RG 2 w %s %s m", pdfColor(color), pdfNumber(points[0]), pdfNumber(points[1]))
+	for i := 2; i+1 < len(points); i += 2 {
+		fmt.Fprintf(p.page, " %s %s l", pdfNumber(points[i]), pdfNumber(points[i+1]))
+	}
+	p.page.WriteString(" S 1 w\n")
+}
+
+// pdfColumn is one column of a table. Right-aligned columns end at x;
+// the others start there and are cut to width.
+type pdfColumn struct {
+	title string
+	x     float64
+	width float64
+	right bool
+}
+
+const pdfRowHeight = 15
+
+func (p *pdfWriter) heading(title string) {
+	p.need(40)
+	p.y -= 24
+	p.text(pdfMargin, p.y, 13, true, title)
+	p.y -= 6
+}
+
+// table writes a header row and rows of cells, continuing on a new page,
+// header included, when a page fills up.
+func (p *pdfWriter) table(columns []pdfColumn, rows [][]string) {
+	header := func() {
+		p.y -= pdfRowHeight
+		for _, c := range columns {
+			p.cell(c, true, c.title)
+		}
+		p.line(pdfMargin, p.y-4, pdfPageWidth-pdfMargin, p.y-4, "#999999")
+	}
+	p.need(2 * pdfRowHeight)
+	header()
+	for _, row := range rows {
+		if p.y-pdfRowHeight < pdfMargin {
+			p.newPage()
+			header()
+		}
+		p.y -= pdfRowHeight
+		for i, c := range columns {
+			p.cell(c, false, row[i])
+		}
+	}
+}
+
+func (p *pdfWriter) cell(c pdfColumn, bold bool, s string) {
+	if c.right {
+		p.textRight(c.x, p.y, 10, bold, s)
+	} else {
+		p.text(c.x, p.y, 10, bold, pdfFit(s, 10, bold, c.width))
+	}
+}
+
+// chart draws a chart in a box of the content width.
+func (p *pdfWriter) chart(chart *Chart) {
+	const height, labelSpace = 150, 28
+	p.need(height + labelSpace + 50)
+	p.y -= 20
+	p.text(pdfMargin, p.y, 11, true, chart.Title)
+
+	left, right := float64(pdfMargin+60), float64(pdfPageWidth-pdfMargin)
+	top := p.y - 14
+	baseline := top - height
+	step := chartStep(chart.max())
+	y := func(minor int64) float64 { return baseline + height*math.Max(0, float64(minor))/(4*step) }
+	for k := 0; k <= 4; k++ {
+		value := int64(math.Round(step * float64(k)))
+		p.line(left, y(value), right, y(value), "#dddddd")
+		p.textRight(left-4, y(value)-3, 7, false, Money{Minor: value, Currency: chart.Currency}.String())
+	}
+	p.line(left, baseline, right, baseline, "#333333")
+
+	slot := (right - left) / math.Max(1, float64(len(chart.Labels)))
+	center := func(i int) float64 { return left + slot*(float64(i)+0.5) }
+	for i, label := range chart.Labels {
+		p.textCenter(center(i), baseline-11, 7, false, pdfFit(label, 7, false, slot-2))
+	}
+	for j, s := range chart.Series {
+		if chart.Line {
+			var points []float64
+			for i, v := range s.Values {
+				points = append(points, center(i), y(v.Minor))
+			}
+			p.polyline(points, s.Color)
+			continue
+		}
+		group := slot * 0.8
+		width := group / float64(len(chart.Series))
+		for i, v := range s.Values {
+			p.rect(center(i)-group/2+width*float64(j), baseline, width, y(v.Minor)-baseline, s.Color)
+		}
+	}
+
+	p.y = baseline - labelSpace
+	for j, s := range chart.Series {
+		x := left + float64(120*j)
+		p.rect(x, p.y, 8, 8, s.Color)
+		p.text(x+12, p.y, 9, false, s.Name)
+	}
+	p.y -= 10
+}
+
+// finish writes the document: a catalog, the page tree, the two fonts,
+// the document info and then every page with its content stream.
+func (p *pdfWriter) finish(w io.Writer, title string) error {
+	var out bytes.Buffer
+	var offsets []int
+	object := func(body string) {
+		offsets = append(offsets, out.Len())
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	out.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	const firstPage = 6
+	kids := make([]string, len(p.pages))
+	for i := range p.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(p.pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+	object(fmt.Sprintf("<< /Title %s /Producer (Finance Manager) >>", pdfString(title)))
+	for i, page := range p.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfPageWidth, pdfPageHeight, firstPage+2*i+1))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", page.Len(), page.Bytes()))
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R /Info 5 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	_, err := w.Write(out.Bytes())
+	return err
+}
+
+// PDFRenderer writes a report as a PDF document with the standard
+// Helvetica fonts. With Charts set it adds the income, category and
+// investment charts after the tables.
+type PDFRenderer struct {
+	Charts bool
+}
+
+func (r PDFRenderer) Render(w io.Writer, report *PeriodReport) error {
+	p := newPDFWriter()
+	p.y -= 10
+	p.text(pdfMargin, p.y, 18, true, "Financial Report for "+report.Label)
+	p.y -= 16
+	subtitle := "All amounts in " + report.Currency
+	if report.Account != "" {
+		subtitle += ", account " + report.Account
+	}
+	p.text(pdfMargin, p.y, 10, false, subtitle)
+
+	amount := pdfColumn{title: "Amount", x: pdfPageWidth - pdfMargin, right: true}
+	p.heading("Summary")
+	p.table([]pdfColumn{{title: "", x: pdfMargin, width: 300}, amount}, [][]string{
+		{"Total Income", report.TotalIncome.String()},
+		{"Total Expenses", report.TotalExpenses.String()},
+		{"Net Profit/Loss", report.NetProfit.String()},
+		{"Investment Value", report.InvestmentValue.String()},
+		{"Change vs Previous Period", signedMoney(report.InvestmentChange)},
+		{"Realized Gains", report.RealizedGains.String()},
+		{"Unrealized Gains", report.UnrealizedGains.String()},
+	})
+
+	if len(report.Months) > 1 {
+		p.heading("By Month")
+		var rows [][]string
+		for _, month := range report.Months {
+			rows = append(rows, []string{fmt.Sprintf("%s %d", month.Month, month.Year), month.Income.String(), month.Expenses.String(), month.Net.String()})
+		}
+		p.table([]pdfColumn{{title: "Month", x: pdfMargin, width: 200}, {title: "Income", x: 345, right: true},
+			{title: "Expenses", x: 445, right: true}, {title: "Net", x: pdfPageWidth - pdfMargin, right: true}}, rows)
+	}
+	totals := func(title, name string, totals []CategoryTotal, whole Money) {
+		if len(totals) == 0 {
+			return
+		}
+		p.heading(title)
+		var rows [][]string
+		for _, total := range totals {
+			share := ""
+			if whole.Minor > 0 {
+				share = fmt.Sprintf("%.1f%%", float64(total.Amount.Minor)/float64(whole.Minor)*100)
+			}
+			rows = append(rows, []string{total.Category, share, total.Amount.String()})
+		}
+		p.table([]pdfColumn{{title: name, x: pdfMargin, width: 320}, {title: "Share", x: 445, right: true}, amount}, rows)
+	}
+	totals("Income by Source", "Source", report.IncomeBySource, report.TotalIncome)
+	totals("Expenses by Category", "Category", report.ExpensesByCategory, report.TotalExpenses)
+
+	if len(report.Holdings) > 0 {
+		p.heading("Holdings")
+		var rows [][]string
+		for _, h := range report.Holdings {
+			rows = append(rows, []string{h.Asset, h.Quantity.String(), h.MarketValue.String(), h.CostBasis.String(), h.UnrealizedGain.String()})
+		}
+		p.table([]pdfColumn{{title: "Asset", x: pdfMargin, width: 120}, {title: "Quantity", x: 245, right: true}, {title: "Value", x: 345, right: true},
+			{title: "Cost Basis", x: 445, right: true}, {title: "Unrealized", x: pdfPageWidth - pdfMargin, right: true}}, rows)
+	}
+	if len(report.Budgets) > 0 {
+		p.heading("Budgets")
+		var rows [][]string
+		for _, b := range report.Budgets {
+			rows = append(rows, []string{fmt.Sprintf("%s (%s)", b.Category, b.Period), b.Spent.String(), b.Limit.String(), b.flag()})
+		}
+		p.table([]pdfColumn{{title: "Budget", x: pdfMargin, width: 160}, {title: "Spent", x: 295, right: true}, {title: "Limit", x: 385, right: true},
+			{title: "Status", x: 400, width: pdfPageWidth - pdfMargin - 400}}, rows)
+	}
+
+	if r.Charts {
+		for _, kind := range []ChartKind{ChartIncomeExpense, ChartCategories, ChartInvestments} {
+			chart, err := BuildChart(report, kind)
+			if err != nil {
+				return err
+			}
+			// Monthly charts need at least two months to show a trend.
+			if chart.max() > 0 && (len(chart.Labels) > 1 || kind == ChartCategories) {
+				p.chart(chart)
+			}
+		}
+	}
+	return p.finish(w, "Financial Report for "+report.Label)
+}
@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// pdfObject is an object read back from a PDF: its dictionary and, for
+// streams, the stream data.
+type pdfObject struct {
+	dict   string
+	stream string
+}
+
+var (
+	pdfLengthPattern = regexp.MustCompile(`/Length (\d+)`)
+	pdfRefPattern    = regexp.MustCompile(`(\d+) 0 R`)
+)
+
+// parsePDF reads a PDF through its cross-reference table, checking that
+// every offset points at its object and every stream has its length.
+func parsePDF(t *testing.T, data []byte) (map[int]pdfObject, string) {
+	t.Helper()
+	doc := string(data)
+	if !strings.HasPrefix(doc, "%PDF-1.") || !strings.HasSuffix(doc, "%%EOF\n") {
+		t.Fatalf("Missing PDF header or trailer")
+	}
+	i := strings.LastIndex(doc, "startxref\n")
+	xref, err := strconv.Atoi(strings.TrimSpace(strings.TrimSuffix(doc[i+len("startxref\n"):], "%%EOF\n")))
+	if err != nil || !strings.HasPrefix(doc[xref:], "xref\n0 ") {
+		t.Fatalf("startxref does not point at the xref table: %v", err)
+	}
+	var count int
+	fmt.Sscanf(doc[xref+len("xref\n"):], "0 %d", &count)
+	entries := doc[strings.Index(doc[xref:], "0000000000 65535 f \n")+xref:]
+
+	objects := make(map[int]pdfObject)
+	for n := 1; n < count; n++ {
+		entry := entries[20*n : 20*n+20]
+		offset, err := strconv.Atoi(entry[:10])
+		if err != nil || !strings.HasSuffix(entry, " 00000 n \n") {
+			t.Fatalf("Bad xref entry %d: %q", n, entry)
+		}
+		header := fmt.Sprintf("%d 0 obj\n", n)
+		if !strings.HasPrefix(doc[offset:], header) {
+			t.Fatalf("Object %d is not at offset %d", n, offset)
+		}
+		body := doc[offset+len(header):]
+		var obj pdfObject
+		if m := pdfLengthPattern.FindStringSubmatch(body[:strings.Index(body, "\n")]); m != nil {
+			length, _ := strconv.Atoi(m[1])
+			start := strings.Index(body, "stream\n") + len("stream\n")
+			obj.dict, obj.stream = body[:start], body[start:start+length]
+			if !strings.HasPrefix(body[start+length:], "endstream\nendobj\n") {
+				t.Fatalf("Stream of object %d does not end at its length %d", n, length)
+			}
+		} else {
+			obj.dict = body[:strings.Index(body, "\nendobj\n")]
+		}
+		objects[n] = obj
+	}
+	trailer := doc[strings.Index(doc, "trailer\n"):i]
+	if !strings.Contains(trailer, fmt.Sprintf("/Size %d", count)) {
+		t.Errorf("Expected trailer size %d, got %s", count, trailer)
+	}
+	return objects, trailer
+}
+
+// pdfPages follows the catalog to the page tree and returns the content
+// stream of every page.
+func pdfPages(t *testing.T, objects map[int]pdfObject, trailer string) []string {
+	t.Helper()
+	ref := func(dict, key string) int {
+		m := regexp.MustCompile(key + ` (\d+) 0 R`).FindStringSubmatch(dict)
+		if m == nil {
+			t.Fatalf("Missing %s in %s", key, dict)
+		}
+		n, _ := strconv.Atoi(m[1])
+		return n
+	}
+	catalog := objects[ref(trailer, "/Root")]
+	if !strings.Contains(catalog.dict, "/Type /Catalog") {
+		t.Fatalf("Root is not a catalog: %s", catalog.dict)
+	}
+	tree := objects[ref(catalog.dict, "/Pages")].dict
+	kids := pdfRefPattern.FindAllStringSubmatch(tree[strings.Index(tree, "/Kids"):], -1)
+	if !strings.Contains(tree, fmt.Sprintf("/Count %d", len(kids))) {
+		t.Errorf("Page count does not match the kids in %s", tree)
+	}
+	var contents []string
+	for _, kid := range kids {
+		n, _ := strconv.Atoi(kid[1])
+		page := objects[n].dict
+		if !strings.Contains(page, "/Type /Page ") || !strings.Contains(page, "/F1 3 0 R") {
+			t.Fatalf("Unexpected page object %s", page)
+		}
+		contents = append(contents, objects[ref(page, "/Contents")].stream)
+	}
+	return contents
+}
+
+func TestPDFReport(t *testing.T) {
+	fm := chartFixture(t)
+	q1, _ := QuarterRange(2024, 1)
+	var out bytes.Buffer
+	if err := fm.WriteReport(&out, q1, "pdf"); err != nil {
+		t.Fatalf("Failed to write PDF: %v", err)
+	}
+	objects, trailer := parsePDF(t, out.Bytes())
+	if info := objects[5].dict; !strings.Contains(info, "/Title (Financial Report for Q1 2024)") {
+		t.Errorf("Unexpected document info %s", info)
+	}
+	if font := objects[3].dict; !strings.Contains(font, "/BaseFont /Helvetica /Encoding /WinAnsiEncoding") {
+		t.Errorf("Unexpected font %s", font)
+	}
+
+	content := strings.Join(pdfPages(t, objects, trailer), "")
+	for _, want := range []string{
+		"(Financial Report for Q1 2024) Tj",
+		"(Total Income) Tj", "($8000.00) Tj",
+		"(Expenses by Category) Tj", "(R&D <lab>) Tj", "(88.9%) Tj",
+		"(Income and expenses, Q1 2024) Tj", "(Investment value, Q1 2024) Tj",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("Expected page content to contain %q", want)
+		}
+	}
+	// Three months of two series plus two categories, and their legends.
+	if bars := strings.Count(content, " re f\n"); bars != 6+2+4 {
+		t.Errorf("Expected 12 filled boxes, got %d", bars)
+	}
+	if !regexp.MustCompile(`m( [\d.]+ [\d.]+ l){2} S`).MatchString(content) {
+		t.Error("Expected a line through the three month-end investment values")
+	}
+
+	out.Reset()
+	report, _ := fm.BuildReport(q1)
+	if err := (PDFRenderer{}).Render(&out, report); err != nil {
+		t.Fatalf("Failed to render PDF: %v", err)
+	}
+	objects, trailer = parsePDF(t, out.Bytes())
+	if content := strings.Join(pdfPages(t, objects, trailer), ""); strings.Contains(content, " re f\n") {
+		t.Error("Expected no charts without Charts set")
+	}
+}
+
+func TestPDFReportPages(t *testing.T) {
+	fm := NewFinanceManager()
+	for i := 0; i < 80; i++ {
+		fm.AddExpense(date(2024, 3, 1+i%28), fmt.Sprintf("Category %02d", i), usd("10.00"))
+	}
+	var out bytes.Buffer
+	if err := fm.WriteMonthlyReport(&out, 2024, 3, "pdf"); err != nil {
+		t.Fatalf("Failed to write PDF: %v", err)
+	}
+	objects, trailer := parsePDF(t, out.Bytes())
+	pages := pdfPages(t, objects, trailer)
+	if len(pages) < 2 {
+		t.Fatalf("Expected 80 categories to need more than one page, got %d", len(pages))
+	}
+	if !strings.Contains(pages[1], "(Category) Tj") || !strings.Contains(pages[len(pages)-1], "(Expenses by category, March 2024) Tj") {
+		t.Error("Expected the table header to repeat and the chart to follow the tables")
+	}
+	if !strings.Contains(pages[len(pages)-1], "(Other) Tj") {
+		t.Error("Expected the small categories to be combined in the chart")
+	}
+}
+
+func TestPDFReportCutsLongNames(t *testing.T) {
+	fm := NewFinanceManager()
+	asset := "Extremely Long Global Equity Index Fund Accumulating"
+	category := "Household Maintenance and Repairs"
+	fm.AddInvestment(date(2024, 3, 1), asset, usd("1000.00"))
+	fm.AddExpense(date(2024, 3, 2), category, usd("50.00"))
+	if err := fm.SetBudget(category, BudgetMonthly, usd("100.00")); err != nil {
+		t.Fatalf("Failed to set budget: %v", err)
+	}
+	var out bytes.Buffer
+	if err := fm.WriteMonthlyReport(&out, 2024, 3, "pdf"); err != nil {
+		t.Fatalf("Failed to write PDF: %v", err)
+	}
+	objects, trailer := parsePDF(t, out.Bytes())
+	content := strings.Join(pdfPages(t, objects, trailer), "")
+
+	// The names must end before the right-aligned column next to them.
+	for name, width := range map[string]float64{asset: 120, category + " (monthly)": 160} {
+		cut := pdfFit(name, 10, false, width)
+		if !strings.HasSuffix(cut, "...") || !strings.Contains(content, pdfString(cut)+" Tj") {
+			t.Errorf("Expected %q to be cut to %q", name, cut)
+		}
+	}
+}
+
+func TestPDFString(t *testing.T) {
+	tests := map[string]string{
+		"Net (loss)": `(Net \(loss\))`,
+		`C:\tmp`:     `(C:\\tmp)`,
+		"€12 – Café": `(\20012 \226 Caf\351)`,
+		"日本 savings": "(?? savings)",
+	}
+	for input, want := range tests {
+		if got := pdfString(input); got != want {
+			t.Errorf("%q: expected %s, got %s", input, want, got)
+		}
+	}
+}
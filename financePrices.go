@@ -0,0 +1,226 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// AssetPrice is the quoted price of one unit of Asset on Date.
+type AssetPrice struct {
+	Date  time.Time `json:"date"`
+	Asset string    `json:"asset"`
+	Price Money     `json:"price"`
+}
+
+func validatePrice(price AssetPrice) error {
+	if strings.TrimSpace(price.Asset) == "" {
+		return fmt.Errorf("asset is required")
+	}
+	if err := validateCurrency(price.Price.Currency); err != nil {
+		return err
+	}
+	if !price.Price.IsPositive() {
+		return fmt.Errorf("price must be positive")
+	}
+	return nil
+}
+
+// ParsePriceCSV reads quotes with the columns date,asset,price and an
+// optional currency, e.g. "2024-01-31,ACME,131.20,USD". Prices without a
+// currency are in currency.
+func ParsePriceCSV(r io.Reader, currency string) ([]AssetPrice, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var prices []AssetPrice
+	line := 0
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		line++
+		if line == 1 && strings.EqualFold(record[0], "date") {
+			continue
+		}
+		if len(record) != 3 && len(record) != 4 {
+			return nil, fmt.Errorf("line %d: expected date,asset,price[,currency]", line)
+		}
+
+		price := AssetPrice{Asset: strings.TrimSpace(record[1])}
+		if price.Date, err = time.ParseInLocation("2006-01-02", record[0], time.Local); err != nil {
+			return nil, fmt.Errorf("line %d: invalid date %q", line, record[0])
+		}
+		priceCurrency := currency
+		if len(record) == 4 {
+			priceCurrency = strings.ToUpper(strings.TrimSpace(record[3]))
+		}
+		if price.Price, err = ParseMoney(record[2], priceCurrency); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := validatePrice(price); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		prices = append(prices, price)
+	}
+	return prices, nil
+}
+
+func ReadPriceFile(path, currency string) ([]AssetPrice, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	prices, err := ParsePriceCSV(f, currency)
+	if err != nil {
+		return nil, fmt.Errorf("loading prices from %s: %w", path, err)
+	}
+	return prices, nil
+}
+
+// AddPrice records a quote, replacing any earlier quote for the same asset
+// and day.
+func (fm *FinanceManager) AddPrice(date time.Time, asset string, price Money) error {
+	_, err := fm.AddPrices([]AssetPrice{{Date: date, Asset: asset, Price: price}})
+	return err
+}
+
+// AddPrices stores quotes in a single save and returns how many were new
+// or changed. Loading the same file twice changes nothing.
+func (fm *FinanceManager) AddPrices(prices []AssetPrice) (int, error) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	for _, price := range prices {
+		if err := validatePrice(price); err != nil {
+			return 0, invalid(fmt.Errorf("%s %s: %w", price.Date.Format("2006-01-02"), price.Asset, err))
+		}
+	}
+
+	old := fm.prices
+	updated := append([]AssetPrice(nil), old...)
+	changed := 0
+	for _, price := range prices {
+		price.Asset = strings.TrimSpace(price.Asset)
+		i := priceIndex(updated, price.Asset, price.Date)
+		switch {
+		case i < 0:
+			updated = append(updated, price)
+		case updated[i].Price == price.Price:
+			continue
+		default:
+			updated[i] = price
+		}
+		changed++
+	}
+	if changed == 0 {
+		return 0, nil
+	}
+	sort.SliceStable(updated, func(i, j int) bool { return updated[i].Date.Before(updated[j].Date) })
+
+	fm.prices = updated
+	if err := fm.commit(func() { fm.prices = old }); err != nil {
+		return 0, err
+	}
+	return changed, nil
+}
+
+func priceIndex(prices []AssetPrice, asset string, date time.Time) int {
+	y, m, d := date.Date()
+	for i, price := range prices {
+		if py, pm, pd := price.Date.Date(); strings.EqualFold(price.Asset, asset) && py == y && pm == m && pd == d {
+			return i
+		}
+	}
+	return -1
+}
+
+// ListPrices returns the quotes for asset, or for every asset when asset
+// is empty, oldest first.
+func (fm *FinanceManager) ListPrices(asset string) []AssetPrice {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+	var prices []AssetPrice
+	for _, price := range fm.prices {
+		if asset == "" || strings.EqualFold(price.Asset, asset) {
+			prices = append(prices, price)
+		}
+	}
+	return prices
+}
+
+// quote returns the latest price of asset dated before end, or the latest
+// of all when end is zero.
+func (fm *FinanceManager) quote(asset string, end time.Time) (AssetPrice, bool) {
+	for i := len(fm.prices) - 1; i >= 0; i-- {
+		price := fm.prices[i]
+		if (end.IsZero() || price.Date.Before(end)) && strings.EqualFold(price.Asset, asset) {
+			return price, true
+		}
+	}
+	return AssetPrice{}, false
+}
+
+// Valuation is the value of every holding at one moment, in the reporting
+// currency.
+type Valuation struct {
+	Date           time.Time `json:"date"`
+	Currency       string    `json:"currency"`
+	Holdings       []Holding `json:"holdings"`
+	MarketValue    Money     `json:"market_value"`
+	CostBasis      Money     `json:"cost_basis"`
+	UnrealizedGain Money     `json:"unrealized_gain"`
+}
+
+// ValueAt values the holdings from trades up to and including asOf, each at
+// the latest quote or trade price known by then.
+func (fm *FinanceManager) ValueAt(asOf time.Time) (*Valuation, error) {
+	fm = fm.view()
+	holdings, err := fm.Holdings(asOf.Add(time.Nanosecond))
+	if err != nil {
+		return nil, err
+	}
+
+	v := &Valuation{
+		Date:           asOf,
+		Currency:       fm.currency,
+		MarketValue:    Money{Currency: fm.currency},
+		CostBasis:      Money{Currency: fm.currency},
+		UnrealizedGain: Money{Currency: fm.currency},
+	}
+	for _, h := range holdings {
+		converted := Holding{Asset: h.Asset, Quantity: h.Quantity, PriceDate: h.PriceDate}
+		for _, field := range []struct{ from, to *Money }{
+			{&h.Price, &converted.Price},
+			{&h.CostBasis, &converted.CostBasis},
+			{&h.MarketValue, &converted.MarketValue},
+			{&h.UnrealizedGain, &converted.UnrealizedGain},
+		} {
+			if *field.to, err = fm.toReporting(*field.from, asOf); err != nil {
+				return nil, err
+			}
+		}
+		if v.MarketValue, err = v.MarketValue.Add(converted.MarketValue); err != nil {
+			return nil, err
+		}
+		if v.CostBasis, err = v.CostBasis.Add(converted.CostBasis); err != nil {
+			return nil, err
+		}
+		if v.UnrealizedGain, err = v.UnrealizedGain.Add(converted.UnrealizedGain); err != nil {
+			return nil, err
+		}
+		v.Holdings = append(v.Holdings, converted)
+	}
+	return v, nil
+}
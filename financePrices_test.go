@@ -0,0 +1,73 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePriceCSV(t *testing.T) {
+	prices, err := ParsePriceCSV(strings.NewReader(`date,asset,price,currency
+2024-01-31,ACME,120.00
+2024-01-31,Fonds,98.10,EUR
+`), "USD")
+	if err != nil {
+		t.Fatalf("Failed to parse prices: %v", err)
+	}
+	if len(prices) != 2 || prices[0].Price != usd("120.00") || prices[1].Price != NewMoney(9810, "EUR") {
+		t.Errorf("Unexpected prices %+v", prices)
+	}
+	if _, err := ParsePriceCSV(strings.NewReader("2024-01-31,ACME,-1\n"), "USD"); err == nil {
+		t.Error("Expected error for a negative price")
+	}
+
+	fm := NewFinanceManager()
+	if changed, err := fm.AddPrices(prices); err != nil || changed != 2 {
+		t.Fatalf("Expected 2 new prices, got %d (%v)", changed, err)
+	}
+	if changed, _ := fm.AddPrices(prices); changed != 0 {
+		t.Errorf("Expected reloading the same prices to change nothing, got %d", changed)
+	}
+}
+
+func TestValueAt(t *testing.T) {
+	fm := NewFinanceManager()
+	fm.BuyInvestment(date(2024, 1, 10), "ACME", 10*oneUnit, usd("100.00"))
+	fm.AddPrice(date(2024, 1, 31), "ACME", usd("110.00"))
+	fm.AddPrice(date(2024, 2, 29), "ACME", usd("90.00"))
+	fm.AddPrice(date(2024, 3, 29), "ACME", usd("120.00"))
+
+	for _, tt := range []struct {
+		asOf time.Time
+		want Money
+	}{
+		{date(2024, 1, 5), usd("0.00")},
+		{date(2024, 1, 20), usd("1000.00")},
+		{date(2024, 2, 15), usd("1100.00")},
+		{date(2024, 3, 1), usd("900.00")},
+	} {
+		v, err := fm.ValueAt(tt.asOf)
+		if err != nil || v.MarketValue != tt.want {
+			t.Errorf("ValueAt(%s) = %v (%v); want %s", tt.asOf.Format("2006-01-02"), v.MarketValue, err, tt.want)
+		}
+	}
+
+	total, err := fm.GetTotalInvestments()
+	if err != nil || total != usd("1200.00") {
+		t.Errorf("Expected current value $1200.00 at the latest price, got %s (%v)", total, err)
+	}
+
+	report, err := fm.BuildReport(MonthRange(2024, time.February))
+	if err != nil {
+		t.Fatalf("Failed to build report: %v", err)
+	}
+	if report.InvestmentValue != usd("900.00") || report.PreviousInvestmentValue != usd("1100.00") ||
+		report.InvestmentChange != usd("-200.00") {
+		t.Errorf("Expected February value $900.00, down $200.00 from $1100.00, got %s, %s, %s",
+			report.InvestmentValue, report.InvestmentChange, report.PreviousInvestmentValue)
+	}
+	text := formatReportText(report)
+	if !strings.Contains(text, "$900.00 (-$200.00 vs previous period)") {
+		t.Errorf("Expected report to show the change in value:\n%s", text)
+	}
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Length limits for text typed at the interactive prompts.
+const (
+	maxNameLength  = 64
+	maxNotesLength = 500
+)
+
+// errInputClosed means the input ended before a question was answered.
+var errInputClosed = errors.New("input closed")
+
+// prompter asks questions on out and reads the answers from in. Typed
+// questions ask again until the answer is valid.
+type prompter struct {
+	in       *bufio.Reader
+	out      io.Writer
+	terminal *os.File
+}
+
+func newPrompter(in io.Reader, out io.Writer) *prompter {
+	p := &prompter{in: bufio.NewReader(in), out: out}
+	if f, ok := in.(*os.File); ok {
+		if info, err := f.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+			p.terminal = f
+		}
+	}
+	return p
+}
+
+// line asks once and returns the trimmed answer. A last line without a
+// newline still counts; once the input is exhausted it returns
+// errInputClosed.
+func (p *prompter) line(label string) (string, error) {
+	input, err := p.rawLine(label)
+	return strings.TrimSpace(input), err
+}
+
+// rawLine is line without the trimming; only the line ending is dropped.
+func (p *prompter) rawLine(label string) (string, error) {
+	fmt.Fprint(p.out, label)
+	input, err := p.in.ReadString('\n')
+	if err == io.EOF && input != "" {
+		err = nil
+	}
+	if err == io.EOF {
+		fmt.Fprintln(p.out)
+		return "", errInputClosed
+	}
+	if err != nil {
+		return "", err
+	}
+	input = strings.TrimSuffix(input, "\n")
+	return strings.TrimSuffix(input, "\r"), nil
+}
+
+// promptFor asks until parse accepts the answer, saying why each rejected
+// answer was wrong. It only fails when the input does.
+func promptFor[T any](p *prompter, label string, parse func(input string) (T, error)) (T, error) {
+	for {
+		input, err := p.line(label)
+		if err != nil {
+			var zero T
+			return zero, err
+		}
+		value, err := parse(input)
+		if err == nil {
+			return value, nil
+		}
+		fmt.Fprintln(p.out, "Invalid input:", err)
+	}
+}
+
+func checkText(input string, required bool, maxLen int) (string, error) {
+	if required && input == "" {
+		return "", fmt.Errorf("a value is required")
+	}
+	if n := utf8.RuneCountInString(input); n > maxLen {
+		return "", fmt.Errorf("at most %d characters allowed, got %d", maxLen, n)
+	}
+	return input, nil
+}
+
+// text asks for at most maxLen characters; required rejects a blank answer.
+func (p *prompter) text(label string, required bool, maxLen int) (string, error) {
+	return promptFor(p, label+": ", func(input string) (string, error) {
+		return checkText(input, required, maxLen)
+	})
+}
+
+// textDefault keeps current when the answer is blank.
+func (p *prompter) textDefault(label, current string, maxLen int) (string, error) {
+	return promptFor(p, fmt.Sprintf("%s [%s]: ", label, current), func(input string) (string, error) {
+		if input == "" {
+			return current, nil
+		}
+		return checkText(input, true, maxLen)
+	})
+}
+
+// parseDayInput reads a date in any format parseDate accepts, or today or
+// yesterday relative to now. A blank answer is def.
+func parseDayInput(input string, def, now time.Time) (time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	switch strings.ToLower(input) {
+	case "":
+		return def, nil
+	case "today":
+		return today, nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	}
+	return parseDate(input)
+}
+
+// date asks for a day, with def as the answer for a blank line.
+func (p *prompter) date(label string, def time.Time) (time.Time, error) {
+	return promptFor(p, fmt.Sprintf("%s [%s]: ", label, def.Format("2006-01-02")), func(input string) (time.Time, error) {
+		return parseDayInput(input, def, time.Now())
+	})
+}
+
+// amount asks for a positive amount in currency unless another is given.
+func (p *prompter) amount(label, currency string) (Money, error) {
+	return promptFor(p, fmt.Sprintf("%s (%s unless another currency is given): ", label, currency), func(input string) (Money, error) {
+		return parsePositiveMoney(input, currency)
+	})
+}
+
+// amountDefault keeps current when the answer is blank.
+func (p *prompter) amountDefault(label string, current Money) (Money, error) {
+	return promptFor(p, fmt.Sprintf("%s [%s]: ", label, current.Decimal()), func(input string) (Money, error) {
+		if input == "" {
+			return current, nil
+		}
+		return parsePositiveMoney(input, current.Currency)
+	})
+}
+
+func parsePositiveMoney(input, currency string) (Money, error) {
+	amount, err := ParseMoney(input, currency)
+	if err != nil {
+		return Money{}, err
+	}
+	if !amount.IsPositive() {
+		return Money{}, fmt.Errorf("amount must be positive")
+	}
+	return amount, nil
+}
+
+// quantity asks for a positive number of units; a blank answer is def
+// unless def is zero.
+func (p *prompter) quantity(label string, def Quantity) (Quantity, error) {
+	if def > 0 {
+		label = fmt.Sprintf("%s [%s]", label, def)
+	}
+	return promptFor(p, label+": ", func(input string) (Quantity, error) {
+		if input == "" && def > 0 {
+			return def, nil
+		}
+		quantity, err := ParseQuantity(input)
+		if err != nil {
+			return 0, err
+		}
+		if quantity <= 0 {
+			return 0, fmt.Errorf("quantity must be positive")
+		}
+		return quantity, nil
+	})
+}
+
+// choice asks for one of options, ignoring case. A blank answer is def,
+// which may be empty to mean none.
+func (p *prompter) choice(label string, options []string, def string) (string, error) {
+	shown := def
+	if shown == "" {
+		shown = "none"
+	}
+	return promptFor(p, fmt.Sprintf("%s (%s) [%s]: ", label, strings.Join(options, ", "), shown), func(input string) (string, error) {
+		if input == "" {
+			return def, nil
+		}
+		for _, option := range options {
+			if strings.EqualFold(input, option) {
+				return option, nil
+			}
+		}
+		return "", fmt.Errorf("expected one of %s", strings.Join(options, ", "))
+	})
+}
+
+// confirm asks a yes or no question that defaults to no.
+func (p *prompter) confirm(label string) (bool, error) {
+	answer, err := promptFor(p, label+" [y/N]: ", func(input string) (string, error) {
+		switch strings.ToLower(input) {
+		case "", "n", "no":
+			return "n", nil
+		case "y", "yes":
+			return "y", nil
+		}
+		return "", fmt.Errorf("answer y or n")
+	})
+	return answer == "y", err
+}
+
+// tags asks for comma separated tags. A blank answer keeps current.
+func (p *prompter) tags(label string, current []string) ([]string, error) {
+	if len(current) > 0 {
+		label = fmt.Sprintf("%s [%s]", label, strings.Join(current, ","))
+	}
+	return promptFor(p, label+": ", func(input string) ([]string, error) {
+		if input == "" {
+			return current, nil
+		}
+		tags := splitTags(input)
+		return tags, validateTags(tags)
+	})
+}
+
+// echo turns the terminal's echo on or off through stty. It does nothing
+// when the input is not a terminal.
+func (p *prompter) echo(on bool) error {
+	if p.terminal == nil {
+		return nil
+	}
+	mode := "echo"
+	if !on {
+		mode = "-echo"
+	}
+	cmd := exec.Command("stty", mode)
+	cmd.Stdin = p.terminal
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("stty %s: %w: %s", mode, err, bytes.TrimSpace(output))
+	}
+	return nil
+}
+
+// password asks for a secret without echoing it on a terminal, warning
+// when echo cannot be turned off. Spaces are part of the answer. With
+// confirm set it asks twice and starts over when the answers differ.
+func (p *prompter) password(label string, confirm bool) (string, error) {
+	secret := func(label string) (string, error) {
+		if err := p.echo(false); err != nil {
+			fmt.Fprintf(p.out, "Warning: the password will be visible (%v)\n", err)
+			return p.rawLine(label)
+		}
+		defer func() {
+			if err := p.echo(true); err != nil {
+				fmt.Fprintln(p.out, "\nWarning: could not turn echo back on:", err)
+			}
+			if p.terminal != nil {
+				fmt.Fprintln(p.out)
+			}
+		}()
+		return p.rawLine(label)
+	}
+	for {
+		password, err := secret(label + ": ")
+		if err != nil {
+			return "", err
+		}
+		if password == "" {
+			fmt.Fprintln(p.out, "Invalid input: a value is required")
+			continue
+		}
+		if !confirm {
+			return password, nil
+		}
+		again, err := secret("Repeat " + strings.ToLower(label) + ": ")
+		if err != nil {
+			return "", err
+		}
+		if again == password {
+			return password, nil
+		}
+		fmt.Fprintln(p.out, "Invalid input: the passwords do not match")
+	}
+}
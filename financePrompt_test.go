@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// quietStdout discards what the interactive handlers print.
+func quietStdout(t *testing.T) {
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatalf("Failed to open %s: %v", os.DevNull, err)
+	}
+	stdout := os.Stdout
+	os.Stdout = devNull
+	t.Cleanup(func() {
+		os.Stdout = stdout
+		devNull.Close()
+	})
+}
+
+func TestPromptRepeatsUntilValid(t *testing.T) {
+	var out bytes.Buffer
+	p := newPrompter(strings.NewReader("\n"+strings.Repeat("x", maxNameLength+1)+"\nSalary\n-5\nabc\n12.50\n"), &out)
+
+	source, err := p.text("Enter source", true, maxNameLength)
+	if err != nil || source != "Salary" {
+		t.Errorf("Expected Salary, got %q (%v)", source, err)
+	}
+	amount, err := p.amount("Enter amount", "USD")
+	if err != nil || amount != usd("12.50") {
+		t.Errorf("Expected $12.50, got %s (%v)", amount, err)
+	}
+	if got := strings.Count(out.String(), "Invalid input"); got != 4 {
+		t.Errorf("Expected 4 rejected answers, got %d:\n%s", got, out.String())
+	}
+	if !strings.Contains(out.String(), "a value is required") || !strings.Contains(out.String(), "amount must be positive") {
+		t.Errorf("Expected reasons for the rejected answers, got:\n%s", out.String())
+	}
+}
+
+func TestPromptInputClosed(t *testing.T) {
+	var out bytes.Buffer
+	p := newPrompter(strings.NewReader("\n\n"), &out)
+
+	if _, err := p.text("Enter category", true, maxNameLength); !errors.Is(err, errInputClosed) {
+		t.Errorf("Expected errInputClosed while re-prompting, got %v", err)
+	}
+
+	p = newPrompter(strings.NewReader("sell"), &out)
+	kind, err := p.choice("Buy or sell?", []string{"buy", "sell"}, "buy")
+	if err != nil || kind != "sell" {
+		t.Errorf("Expected a last line without newline to count, got %q (%v)", kind, err)
+	}
+	if _, err := p.line("Choose an option: "); !errors.Is(err, errInputClosed) {
+		t.Errorf("Expected errInputClosed after the last line, got %v", err)
+	}
+}
+
+func TestParseDayInput(t *testing.T) {
+	now := time.Date(2024, 3, 15, 18, 30, 0, 0, time.Local)
+	def := date(2024, 1, 1)
+	tests := []struct {
+		input string
+		want  time.Time
+	}{
+		{"", def},
+		{"today", date(2024, 3, 15)},
+		{"Yesterday", date(2024, 3, 14)},
+		{"2024-02-29", date(2024, 2, 29)},
+		{"2024/02/29", date(2024, 2, 29)},
+		{"29.02.2024", date(2024, 2, 29)},
+		{"29 Feb 2024", date(2024, 2, 29)},
+		{"February 29, 2024", date(2024, 2, 29)},
+	}
+	for _, tc := range tests {
+		got, err := parseDayInput(tc.input, def, now)
+		if err != nil || !got.Equal(tc.want) {
+			t.Errorf("%q: expected %s, got %s (%v)", tc.input, tc.want.Format("2006-01-02"), got.Format("2006-01-02"), err)
+		}
+	}
+	for _, input := range []string{"2024-02-30", "02/29/2024", "soon"} {
+		if _, err := parseDayInput(input, def, now); err == nil {
+			t.Errorf("Expected error for %q", input)
+		}
+	}
+}
+
+func TestInteractiveAddAndExitOnEOF(t *testing.T) {
+	quietStdout(t)
+	fm := NewFinanceManager()
+	input := strings.Join([]string{
+		"1", "01.03.2024", "", "Salary", "5000", "work", "",
+		"2", "bad date", "2024-03-02", "Rent", "Landlord", "1200", "", "First month",
+		"3", "", "ACME", "sell", "0", "2", "10",
+		"2", "today", "Food",
+	}, "\n")
+	done := make(chan struct{})
+	go func() {
+		runInteractive(fm, newPrompter(strings.NewReader(input), &bytes.Buffer{}))
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the menu to exit at the end of the input")
+	}
+
+	incomes := fm.ListIncomes()
+	if len(incomes) != 1 || !incomes[0].Date.Equal(date(2024, 3, 1)) || incomes[0].Source != "Salary" || len(incomes[0].Tags) != 1 {
+		t.Errorf("Unexpected incomes %+v", incomes)
+	}
+	expenses := fm.ListExpenses()
+	if len(expenses) != 1 || !expenses[0].Date.Equal(date(2024, 3, 2)) || expenses[0].Payee != "Landlord" || expenses[0].Notes != "First month" {
+		t.Errorf("Expected only the complete expense to be added, got %+v", expenses)
+	}
+	// Selling ACME with no holdings fails, but the answers are still read.
+	if len(fm.ListInvestments()) != 0 {
+		t.Errorf("Expected the sale to be rejected, got %+v", fm.ListInvestments())
+	}
+}
+
+func TestPromptPasswordKeepsSpaces(t *testing.T) {
+	var out bytes.Buffer
+	p := newPrompter(strings.NewReader("\r\n  two words \r\n  two words \r\n"), &out)
+	password, err := p.password("Password", true)
+	if err != nil || password != "  two words " {
+		t.Errorf("Expected the password with its spaces, got %q (%v)", password, err)
+	}
+}
+
+func TestInteractiveExpenseCategorizedByRules(t *testing.T) {
+	quietStdout(t)
+	fm := NewFinanceManager()
+	fm.SetCategoryRules(loadTestCategoryRules(t))
+	input := strings.Join([]string{"2", "2024-03-02", "", "Landlord Ltd", "950", "", ""}, "\n") + "\n"
+	runInteractive(fm, newPrompter(strings.NewReader(input), &bytes.Buffer{}))
+
+	if expenses := fm.ListExpenses(); len(expenses) != 1 || expenses[0].Category != "Housing" {
+		t.Errorf("Expected the blank category to be filled in by the rules, got %+v", expenses)
+	}
+}
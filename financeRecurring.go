@@ -0,0 +1,323 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const recurringPrefix = "rec"
+
+type RecurrenceFrequency string
+
+const (
+	RecurDaily   RecurrenceFrequency = "daily"
+	RecurWeekly  RecurrenceFrequency = "weekly"
+	RecurMonthly RecurrenceFrequency = "monthly"
+	RecurYearly  RecurrenceFrequency = "yearly"
+)
+
+// RecurrenceRule repeats every Interval periods from a series' start date.
+// Monthly and yearly series keep the start's day of month, moving to the
+// last day in shorter months; LastBusinessDay instead picks the last
+// weekday of each month.
+type RecurrenceRule struct {
+	Frequency       RecurrenceFrequency `json:"frequency"`
+	Interval        int                 `json:"interval,omitempty"`
+	LastBusinessDay bool                `json:"last_business_day,omitempty"`
+}
+
+// RecurringTemplate describes a series of income or expense entries.
+// Occurrences up to MaterializedThrough already exist as entries; editing or
+// ending the series only changes occurrences after it.
+type RecurringTemplate struct {
+	ID                  string         `json:"id"`
+	Type                string         `json:"type"`
+	Source              string         `json:"source,omitempty"`
+	Category            string         `json:"category,omitempty"`
+	Payee               string         `json:"payee,omitempty"`
+	Amount              Money          `json:"amount"`
+	Account             string         `json:"account,omitempty"`
+	Rule                RecurrenceRule `json:"rule"`
+	Start               time.Time      `json:"start"`
+	End                 time.Time      `json:"end"`
+	MaterializedThrough time.Time      `json:"materialized_through"`
+}
+
+func parseRecurrenceFrequency(value string) (RecurrenceFrequency, error) {
+	switch frequency := RecurrenceFrequency(strings.ToLower(value)); frequency {
+	case RecurDaily, RecurWeekly, RecurMonthly, RecurYearly:
+		return frequency, nil
+	}
+	return "", fmt.Errorf("invalid frequency %q, expected daily, weekly, monthly or yearly", value)
+}
+
+func validateRecurring(t RecurringTemplate) error {
+	switch {
+	case t.Type == "income" && strings.TrimSpace(t.Source) == "":
+		return fmt.Errorf("source is required")
+	case t.Type == "expense" && strings.TrimSpace(t.Category) == "":
+		return fmt.Errorf("category is required")
+	case t.Type != "income" && t.Type != "expense":
+		return fmt.Errorf("invalid recurring type %q, expected income or expense", t.Type)
+	}
+	if _, err := parseRecurrenceFrequency(string(t.Rule.Frequency)); err != nil {
+		return err
+	}
+	if t.Rule.Interval < 0 {
+		return fmt.Errorf("interval must not be negative")
+	}
+	if t.Rule.LastBusinessDay && t.Rule.Frequency != RecurMonthly {
+		return fmt.Errorf("last business day only applies to monthly series")
+	}
+	if t.Start.IsZero() {
+		return fmt.Errorf("start date is required")
+	}
+	if !t.End.IsZero() && t.End.Before(t.Start) {
+		return fmt.Errorf("end date must not be before start date")
+	}
+	if err := validateCurrency(t.Amount.Currency); err != nil {
+		return err
+	}
+	if !t.Amount.IsPositive() {
+		return fmt.Errorf("amount must be positive")
+	}
+	return nil
+}
+
+// occurrence returns the n-th date of the series, counting from zero.
+// Dates are derived from the start each time so short months never shift
+// later occurrences.
+func (t RecurringTemplate) occurrence(n int) time.Time {
+	interval := t.Rule.Interval
+	if interval == 0 {
+		interval = 1
+	}
+	start := t.Start
+	switch t.Rule.Frequency {
+	case RecurDaily:
+		return start.AddDate(0, 0, n*interval)
+	case RecurWeekly:
+		return start.AddDate(0, 0, 7*n*interval)
+	case RecurYearly:
+		return clampedDate(start.Year()+n*interval, start.Month(), start)
+	}
+
+	first := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, start.Location()).AddDate(0, n*interval, 0)
+	if t.Rule.LastBusinessDay {
+		return lastBusinessDay(first.Year(), first.Month(), start)
+	}
+	return clampedDate(first.Year(), first.Month(), start)
+}
+
+// clampedDate puts like's day and time of day in the given month, using
+// the month's last day when it is shorter.
+// nextDay returns midnight at the start of the day after t.
+func nextDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+}
+
+func clampedDate(year int, month time.Month, like time.Time) time.Time {
+	day := like.Day()
+	if last := daysIn(year, month, like.Location()); day > last {
+		day = last
+	}
+	return time.Date(year, month, day, like.Hour(), like.Minute(), like.Second(), 0, like.Location())
+}
+
+func daysIn(year int, month time.Month, loc *time.Location) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, loc).Day()
+}
+
+func lastBusinessDay(year int, month time.Month, like time.Time) time.Time {
+	date := time.Date(year, month, daysIn(year, month, like.Location()), like.Hour(), like.Minute(), like.Second(), 0, like.Location())
+	for date.Weekday() == time.Saturday || date.Weekday() == time.Sunday {
+		date = date.AddDate(0, 0, -1)
+	}
+	return date
+}
+
+func (fm *FinanceManager) CreateRecurring(t RecurringTemplate) (RecurringTemplate, error) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	if err := validateRecurring(t); err != nil {
+		return RecurringTemplate{}, invalid(err)
+	}
+	t.ID = fm.newID(recurringPrefix)
+	t.MaterializedThrough = time.Time{}
+	fm.recurring = append(fm.recurring, t)
+	err := fm.commit(func() { fm.recurring = fm.recurring[:len(fm.recurring)-1] })
+	if err != nil {
+		return RecurringTemplate{}, err
+	}
+	return t, nil
+}
+
+func (fm *FinanceManager) GetRecurring(id string) (RecurringTemplate, error) {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+	i := fm.recurringIndex(id)
+	if i < 0 {
+		return RecurringTemplate{}, &NotFoundError{Kind: "recurring series", ID: id}
+	}
+	return fm.recurring[i], nil
+}
+
+func (fm *FinanceManager) ListRecurring() []RecurringTemplate {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+	return append([]RecurringTemplate(nil), fm.recurring...)
+}
+
+// UpdateRecurring changes a series from its next unmaterialized occurrence
+// on; entries created so far are left as they are.
+func (fm *FinanceManager) UpdateRecurring(t RecurringTemplate) error {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	return fm.updateRecurring(t)
+}
+
+func (fm *FinanceManager) updateRecurring(t RecurringTemplate) error {
+	i := fm.recurringIndex(t.ID)
+	if i < 0 {
+		return &NotFoundError{Kind: "recurring series", ID: t.ID}
+	}
+	if err := validateRecurring(t); err != nil {
+		return invalid(err)
+	}
+	old := fm.recurring[i]
+	t.MaterializedThrough = old.MaterializedThrough
+	fm.recurring[i] = t
+	return fm.commit(func() { fm.recurring[i] = old })
+}
+
+// EndRecurring stops a series after end without touching existing entries.
+func (fm *FinanceManager) EndRecurring(id string, end time.Time) error {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	i := fm.recurringIndex(id)
+	if i < 0 {
+		return &NotFoundError{Kind: "recurring series", ID: id}
+	}
+	t := fm.recurring[i]
+	t.End = end
+	return fm.updateRecurring(t)
+}
+
+// DeleteRecurring removes the series; entries it already created remain.
+func (fm *FinanceManager) DeleteRecurring(id string) error {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	i := fm.recurringIndex(id)
+	if i < 0 {
+		return &NotFoundError{Kind: "recurring series", ID: id}
+	}
+	old := fm.recurring
+	fm.recurring = append(append([]RecurringTemplate(nil), old[:i]...), old[i+1:]...)
+	return fm.commit(func() { fm.recurring = old })
+}
+
+func (fm *FinanceManager) recurringIndex(id string) int {
+	for i, t := range fm.recurring {
+		if t.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// MaterializeRecurring creates the entries of every series due up to and
+// including through's day. It is idempotent: running it again for the same
+// day adds nothing and saves nothing. It returns the number of entries
+// created.
+func (fm *FinanceManager) MaterializeRecurring(through time.Time) (int, error) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	if len(fm.recurring) == 0 {
+		return 0, nil
+	}
+	through = time.Date(through.Year(), through.Month(), through.Day(), 0, 0, 0, 0, through.Location())
+
+	existing := make(map[string]bool)
+	for _, income := range fm.incomes {
+		if income.RecurringID != "" {
+			existing[income.RecurringID+"|"+income.Date.Format("2006-01-02")] = true
+		}
+	}
+	for _, expense := range fm.expenses {
+		if expense.RecurringID != "" {
+			existing[expense.RecurringID+"|"+expense.Date.Format("2006-01-02")] = true
+		}
+	}
+
+	oldIncomes, oldExpenses := fm.incomes, fm.expenses
+	oldRecurring := append([]RecurringTemplate(nil), fm.recurring...)
+	created, advanced := 0, false
+	for i := range fm.recurring {
+		t := &fm.recurring[i]
+		limit := through
+		if !t.End.IsZero() && t.End.Before(limit) {
+			limit = t.End
+		}
+		// Occurrences keep Start's time of day, so they are compared
+		// with the days after limit and MaterializedThrough.
+		reached := false
+		for n := 0; ; n++ {
+			date := t.occurrence(n)
+			if !date.Before(nextDay(limit)) {
+				break
+			}
+			if !t.MaterializedThrough.IsZero() && date.Before(nextDay(t.MaterializedThrough)) {
+				continue
+			}
+			reached = true
+			key := t.ID + "|" + date.Format("2006-01-02")
+			if existing[key] {
+				continue
+			}
+			existing[key] = true
+
+			if err := fm.materialize(*t, date); err != nil {
+				fm.incomes, fm.expenses, fm.recurring = oldIncomes, oldExpenses, oldRecurring
+				return 0, fmt.Errorf("recurring series %s: %w", t.ID, err)
+			}
+			created++
+		}
+		// Only a passed occurrence moves the mark, so days without one
+		// leave nothing to save.
+		if reached && limit.After(t.MaterializedThrough) {
+			t.MaterializedThrough = limit
+			advanced = true
+		}
+	}
+	if created == 0 && !advanced {
+		return 0, nil
+	}
+
+	err := fm.commit(func() { fm.incomes, fm.expenses, fm.recurring = oldIncomes, oldExpenses, oldRecurring })
+	if err != nil {
+		return 0, err
+	}
+	return created, nil
+}
+
+func (fm *FinanceManager) materialize(t RecurringTemplate, date time.Time) error {
+	if t.Type == "income" {
+		income := Income{Date: date, Source: t.Source, Amount: t.Amount, Account: t.Account, RecurringID: t.ID}
+		if err := validateIncome(income); err != nil {
+			return err
+		}
+		income.ID = fm.newID(incomePrefix)
+		fm.incomes = append(fm.incomes, income)
+		fm.book(income.ID)
+		return nil
+	}
+	expense := fm.categorize(Expense{Date: date, Category: t.Category, Payee: t.Payee, Amount: t.Amount, Account: t.Account, RecurringID: t.ID})
+	if err := validateExpense(expense); err != nil {
+		return err
+	}
+	expense.ID = fm.newID(expensePrefix)
+	fm.expenses = append(fm.expenses, expense)
+	fm.book(expense.ID)
+	return nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+}
+
+func TestRecurrenceOccurrences(t *testing.T) {
+	monthly := RecurringTemplate{Rule: RecurrenceRule{Frequency: RecurMonthly}, Start: date(2024, 1, 31)}
+	for n, want := range []time.Time{date(2024, 1, 31), date(2024, 2, 29), date(2024, 3, 31), date(2024, 4, 30)} {
+		if got := monthly.occurrence(n); !got.Equal(want) {
+			t.Errorf("Monthly occurrence %d = %s; want %s", n, got.Format("2006-01-02"), want.Format("2006-01-02"))
+		}
+	}
+
+	// Last weekday: March 2024 ends on a Sunday, June 2024 on a Sunday too.
+	business := RecurringTemplate{Rule: RecurrenceRule{Frequency: RecurMonthly, LastBusinessDay: true}, Start: date(2024, 3, 1)}
+	for n, want := range []time.Time{date(2024, 3, 29), date(2024, 4, 30), date(2024, 5, 31), date(2024, 6, 28)} {
+		if got := business.occurrence(n); !got.Equal(want) {
+			t.Errorf("Business occurrence %d = %s; want %s", n, got.Format("2006-01-02"), want.Format("2006-01-02"))
+		}
+	}
+
+	weekly := RecurringTemplate{Rule: RecurrenceRule{Frequency: RecurWeekly, Interval: 2}, Start: date(2024, 1, 1)}
+	if got := weekly.occurrence(2); !got.Equal(date(2024, 1, 29)) {
+		t.Errorf("Expected fortnightly occurrence on 2024-01-29, got %s", got.Format("2006-01-02"))
+	}
+
+	yearly := RecurringTemplate{Rule: RecurrenceRule{Frequency: RecurYearly}, Start: date(2024, 2, 29)}
+	if got := yearly.occurrence(1); !got.Equal(date(2025, 2, 28)) {
+		t.Errorf("Expected yearly occurrence on 2025-02-28, got %s", got.Format("2006-01-02"))
+	}
+}
+
+func TestMaterializeRecurring(t *testing.T) {
+	fm := NewFinanceManager()
+	salary, err := fm.CreateRecurring(RecurringTemplate{
+		Type:   "income",
+		Source: "Salary",
+		Amount: usd("3000.00"),
+		Rule:   RecurrenceRule{Frequency: RecurMonthly, LastBusinessDay: true},
+		Start:  date(2024, 1, 1),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create series: %v", err)
+	}
+	fm.CreateRecurring(RecurringTemplate{
+		Type:     "expense",
+		Category: "Rent",
+		Amount:   usd("1000.00"),
+		Rule:     RecurrenceRule{Frequency: RecurMonthly},
+		Start:    date(2024, 1, 1),
+	})
+
+	created, err := fm.MaterializeRecurring(date(2024, 3, 15))
+	if err != nil || created != 5 {
+		t.Fatalf("Expected 5 entries through March 15, got %d (%v)", created, err)
+	}
+	events := len(fm.History())
+	if created, _ := fm.MaterializeRecurring(date(2024, 3, 15).Add(18 * time.Hour)); created != 0 {
+		t.Errorf("Expected materializing twice to add nothing, got %d", created)
+	}
+	if len(fm.History()) != events {
+		t.Errorf("Expected materializing the same day again not to be recorded, got %d new events", len(fm.History())-events)
+	}
+
+	// March's salary falls after the 15th, so a raise now starts with it and
+	// leaves January and February untouched.
+	salary, _ = fm.GetRecurring(salary.ID)
+	salary.Amount = usd("3300.00")
+	if err := fm.UpdateRecurring(salary); err != nil {
+		t.Fatalf("Failed to update series: %v", err)
+	}
+	fm.EndRecurring(salary.ID, date(2024, 4, 30))
+	fm.MaterializeRecurring(date(2024, 6, 30))
+
+	incomes := fm.ListIncomes()
+	if len(incomes) != 4 {
+		t.Fatalf("Expected 4 salary entries, got %d", len(incomes))
+	}
+	if incomes[1].Amount != usd("3000.00") || incomes[2].Amount != usd("3300.00") {
+		t.Errorf("Expected history to keep old amounts, got %s and %s", incomes[1].Amount, incomes[2].Amount)
+	}
+	if len(fm.ListExpenses()) != 6 {
+		t.Errorf("Expected 6 rent entries, got %d", len(fm.ListExpenses()))
+	}
+
+	salary, _ = fm.GetRecurring(salary.ID)
+	if !salary.MaterializedThrough.Equal(date(2024, 4, 30)) {
+		t.Errorf("Expected an ended series to stop at its end, got %s", salary.MaterializedThrough)
+	}
+	events = len(fm.History())
+	if created, _ := fm.MaterializeRecurring(date(2024, 7, 1)); created != 1 || len(fm.History()) != events+1 {
+		t.Errorf("Expected one rent entry and one event for July 1, got %d entries and %d events", created, len(fm.History())-events)
+	}
+	if created, _ := fm.MaterializeRecurring(date(2024, 7, 20)); created != 0 || len(fm.History()) != events+1 {
+		t.Errorf("Expected no change before the next occurrence, got %d entries and %d events", created, len(fm.History())-events)
+	}
+}
+
+func TestMaterializeRecurringStartWithTimeOfDay(t *testing.T) {
+	fm := NewFinanceManager()
+	start := time.Date(2024, 3, 1, 14, 30, 0, 0, time.Local)
+	if _, err := fm.CreateRecurring(RecurringTemplate{Type: "expense", Category: "Rent", Amount: usd("1000.00"),
+		Rule: RecurrenceRule{Frequency: RecurMonthly}, Start: start}); err != nil {
+		t.Fatalf("Failed to create series: %v", err)
+	}
+
+	// Occurrences due later on the through day are still created.
+	created, err := fm.MaterializeRecurring(time.Date(2024, 3, 1, 9, 0, 0, 0, time.Local))
+	if err != nil || created != 1 {
+		t.Fatalf("Expected the March 1 occurrence, got %d (%v)", created, err)
+	}
+	created, err = fm.MaterializeRecurring(time.Date(2024, 4, 1, 20, 0, 0, 0, time.Local))
+	if err != nil || created != 1 {
+		t.Fatalf("Expected the April 1 occurrence, got %d (%v)", created, err)
+	}
+	if created, _ := fm.MaterializeRecurring(time.Date(2024, 4, 1, 23, 0, 0, 0, time.Local)); created != 0 || len(fm.ListExpenses()) != 2 {
+		t.Errorf("Expected March and April once each, got %d more and %+v", created, fm.ListExpenses())
+	}
+}
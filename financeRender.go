@@ -0,0 +1,191 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ReportRenderer writes a PeriodReport in one output format.
+type ReportRenderer interface {
+	Render(w io.Writer, report *PeriodReport) error
+}
+
+type ReportRendererFunc func(w io.Writer, report *PeriodReport) error
+
+func (f ReportRendererFunc) Render(w io.Writer, report *PeriodReport) error {
+	return f(w, report)
+}
+
+var reportRenderers = map[string]ReportRenderer{
+	"text":     ReportRendererFunc(renderReportText),
+	"csv":      ReportRendererFunc(renderReportCSV),
+	"json":     ReportRendererFunc(renderReportJSON),
+	"markdown": ReportRendererFunc(renderReportMarkdown),
+	"pdf":      PDFRenderer{Charts: true},
+}
+
+// RegisterReportRenderer makes a renderer selectable by name.
+func RegisterReportRenderer(name string, renderer ReportRenderer) {
+	reportRenderers[name] = renderer
+}
+
+func reportRenderer(format string) (ReportRenderer, error) {
+	renderer, ok := reportRenderers[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown report format %q (available: %s)", format, reportFormats())
+	}
+	return renderer, nil
+}
+
+func reportFormats() string {
+	names := make([]string, 0, len(reportRenderers))
+	for name := range reportRenderers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// WriteReport renders the report for r in the named format.
+func (fm *FinanceManager) WriteReport(w io.Writer, r ReportRange, format string) error {
+	fm = fm.view()
+	renderer, err := reportRenderer(format)
+	if err != nil {
+		return err
+	}
+	report, err := fm.BuildReport(r)
+	if err != nil {
+		return err
+	}
+	return renderer.Render(w, report)
+}
+
+func (fm *FinanceManager) WriteMonthlyReport(w io.Writer, year int, month time.Month, format string) error {
+	return fm.WriteReport(w, MonthRange(year, month), format)
+}
+
+// writeReportFile renders into memory first so a failed render never
+// leaves a partial file behind.
+func (fm *FinanceManager) writeReportFile(path string, r ReportRange, format string) error {
+	var buf bytes.Buffer
+	if err := fm.WriteReport(&buf, r, format); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, buf.Bytes(), 0644)
+}
+
+func renderReportText(w io.Writer, report *PeriodReport) error {
+	_, err := io.WriteString(w, formatReportText(report))
+	return err
+}
+
+func renderReportJSON(w io.Writer, report *PeriodReport) error {
+	return writeJSON(w, report)
+}
+
+// renderReportCSV writes one figure per row so the output pivots easily in
+// a spreadsheet.
+func renderReportCSV(w io.Writer, report *PeriodReport) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"section", "name", "metric", "amount", "currency"})
+	row := func(section, name, metric string, amount Money) {
+		cw.Write([]string{section, name, metric, amount.Decimal(), amount.Currency})
+	}
+
+	row("summary", report.Label, "income", report.TotalIncome)
+	row("summary", report.Label, "expenses", report.TotalExpenses)
+	row("summary", report.Label, "net", report.NetProfit)
+	row("summary", report.Label, "investment_value", report.InvestmentValue)
+	row("summary", report.Label, "previous_investment_value", report.PreviousInvestmentValue)
+	row("summary", report.Label, "investment_change", report.InvestmentChange)
+	row("summary", report.Label, "realized_gains", report.RealizedGains)
+	row("summary", report.Label, "unrealized_gains", report.UnrealizedGains)
+	for _, month := range report.Months {
+		name := fmt.Sprintf("%d-%02d", month.Year, month.Month)
+		row("month", name, "income", month.Income)
+		row("month", name, "expenses", month.Expenses)
+		row("month", name, "net", month.Net)
+	}
+	for _, total := range report.IncomeBySource {
+		row("income_source", total.Category, "income", total.Amount)
+	}
+	for _, total := range report.ExpensesByCategory {
+		row("expense_category", total.Category, "expenses", total.Amount)
+	}
+	for _, holding := range report.Holdings {
+		row("holding", holding.Asset, "market_value", holding.MarketValue)
+		row("holding", holding.Asset, "cost_basis", holding.CostBasis)
+		row("holding", holding.Asset, "unrealized_gain", holding.UnrealizedGain)
+	}
+	for _, budget := range report.Budgets {
+		name := fmt.Sprintf("%s (%s)", budget.Category, budget.Period)
+		row("budget", name, "limit", budget.Limit)
+		row("budget", name, "spent", budget.Spent)
+		row("budget", name, "projected", budget.Projected)
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func renderReportMarkdown(w io.Writer, report *PeriodReport) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Financial Report for %s\n\n", report.Label)
+	fmt.Fprintf(&b, "All amounts in %s.\n\n", report.Currency)
+	if report.Account != "" {
+		fmt.Fprintf(&b, "Account: %s\n\n", markdownEscape(report.Account))
+	}
+	b.WriteString("| | Amount |\n|---|---:|\n")
+	fmt.Fprintf(&b, "| Total Income | %s |\n", report.TotalIncome)
+	fmt.Fprintf(&b, "| Total Expenses | %s |\n", report.TotalExpenses)
+	fmt.Fprintf(&b, "| Net Profit/Loss | %s |\n", report.NetProfit)
+	fmt.Fprintf(&b, "| Investment Value | %s |\n", report.InvestmentValue)
+	fmt.Fprintf(&b, "| Change vs Previous Period | %s |\n", signedMoney(report.InvestmentChange))
+	fmt.Fprintf(&b, "| Realized Gains | %s |\n", report.RealizedGains)
+	fmt.Fprintf(&b, "| Unrealized Gains | %s |\n", report.UnrealizedGains)
+
+	if len(report.Months) > 1 {
+		b.WriteString("\n## By Month\n\n| Month | Income | Expenses | Net |\n|---|---:|---:|---:|\n")
+		for _, month := range report.Months {
+			fmt.Fprintf(&b, "| %s %d | %s | %s | %s |\n", month.Month, month.Year, month.Income, month.Expenses, month.Net)
+		}
+	}
+	if len(report.IncomeBySource) > 0 {
+		b.WriteString("\n## Income by Source\n\n| Source | Amount |\n|---|---:|\n")
+		for _, total := range report.IncomeBySource {
+			fmt.Fprintf(&b, "| %s | %s |\n", markdownEscape(total.Category), total.Amount)
+		}
+	}
+	if len(report.ExpensesByCategory) > 0 {
+		b.WriteString("\n## Expenses by Category\n\n| Category | Amount |\n|---|---:|\n")
+		for _, total := range report.ExpensesByCategory {
+			fmt.Fprintf(&b, "| %s | %s |\n", markdownEscape(total.Category), total.Amount)
+		}
+	}
+	if len(report.Holdings) > 0 {
+		b.WriteString("\n## Holdings\n\n| Asset | Quantity | Price | Value | Cost Basis | Unrealized Gain |\n|---|---:|---:|---:|---:|---:|\n")
+		for _, h := range report.Holdings {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s |\n", markdownEscape(h.Asset), h.Quantity,
+				h.Price, h.MarketValue, h.CostBasis, h.UnrealizedGain)
+		}
+	}
+	if len(report.Budgets) > 0 {
+		b.WriteString("\n## Budgets\n\n| Category | Period | Spent | Limit | Projected | Status |\n|---|---|---:|---:|---:|---|\n")
+		for _, budget := range report.Budgets {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s |\n", markdownEscape(budget.Category), budget.Period,
+				budget.Spent, budget.Limit, budget.Projected, budget.flag())
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func markdownEscape(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newReportTestManager() *FinanceManager {
+	fm := NewFinanceManager()
+	fm.AddIncome(time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local), "Salary", usd("5000.00"))
+	fm.AddExpense(time.Date(2024, 3, 2, 0, 0, 0, 0, time.Local), "Rent", usd("1000.00"))
+	fm.AddExpense(time.Date(2024, 3, 9, 0, 0, 0, 0, time.Local), "Food | Drinks", usd("120.50"))
+	return fm
+}
+
+func TestReportRenderers(t *testing.T) {
+	fm := newReportTestManager()
+
+	var buf bytes.Buffer
+	if err := fm.WriteMonthlyReport(&buf, 2024, time.March, "json"); err != nil {
+		t.Fatalf("json render failed: %v", err)
+	}
+	var report PeriodReport
+	if err := json.Unmarshal(buf.Bytes(), &report); err != nil {
+		t.Fatalf("Invalid JSON report: %v", err)
+	}
+	if report.NetProfit != usd("3879.50") {
+		t.Errorf("Expected net profit $3879.50, got %s", report.NetProfit)
+	}
+
+	buf.Reset()
+	if err := fm.WriteMonthlyReport(&buf, 2024, time.March, "csv"); err != nil {
+		t.Fatalf("csv render failed: %v", err)
+	}
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("Invalid CSV report: %v", err)
+	}
+	if records[1][2] != "income" || records[1][3] != "5000.00" {
+		t.Errorf("Unexpected first CSV row %v", records[1])
+	}
+
+	buf.Reset()
+	if err := fm.WriteMonthlyReport(&buf, 2024, time.March, "markdown"); err != nil {
+		t.Fatalf("markdown render failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), `| Food \| Drinks | $120.50 |`) {
+		t.Errorf("Expected escaped category row in markdown:\n%s", buf.String())
+	}
+
+	buf.Reset()
+	text, _ := fm.GenerateMonthlyReport(2024, time.March)
+	fm.WriteMonthlyReport(&buf, 2024, time.March, "text")
+	if buf.String() != text {
+		t.Error("Expected text renderer to match GenerateMonthlyReport")
+	}
+
+	if err := fm.WriteMonthlyReport(&buf, 2024, time.March, "xls"); err == nil {
+		t.Error("Expected error for unknown format")
+	}
+}
+
+func TestReportCommandWritesFile(t *testing.T) {
+	fm := newReportTestManager()
+	path := filepath.Join(t.TempDir(), "report.md")
+
+	var out bytes.Buffer
+	err := runFinanceCommand(fm, nil, []string{"report", "-year", "2024", "-month", "3", "-format", "markdown", "-out", path}, &out)
+	if err != nil {
+		t.Fatalf("report command failed: %v", err)
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(content), "# Financial Report for March 2024") {
+		t.Errorf("Unexpected report file:\n%s", content)
+	}
+}
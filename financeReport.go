@@ -0,0 +1,316 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ReportRange is a half-open [Start, End) period: an entry dated exactly at
+// End belongs to the next period. Account, when set, limits a report to the
+// entries of that account.
+type ReportRange struct {
+	Label   string
+	Start   time.Time
+	End     time.Time
+	Account string
+}
+
+func MonthRange(year int, month time.Month) ReportRange {
+	start := time.Date(year, month, 1, 0, 0, 0, 0, time.Local)
+	return ReportRange{
+		Label: fmt.Sprintf("%s %d", month, year),
+		Start: start,
+		End:   start.AddDate(0, 1, 0),
+	}
+}
+
+func QuarterRange(year, quarter int) (ReportRange, error) {
+	if quarter < 1 || quarter > 4 {
+		return ReportRange{}, fmt.Errorf("invalid quarter %d", quarter)
+	}
+	start := time.Date(year, time.Month(3*(quarter-1)+1), 1, 0, 0, 0, 0, time.Local)
+	return ReportRange{
+		Label: fmt.Sprintf("Q%d %d", quarter, year),
+		Start: start,
+		End:   start.AddDate(0, 3, 0),
+	}, nil
+}
+
+func YearRange(year int) ReportRange {
+	start := time.Date(year, time.January, 1, 0, 0, 0, 0, time.Local)
+	return ReportRange{
+		Label: fmt.Sprintf("%d", year),
+		Start: start,
+		End:   start.AddDate(1, 0, 0),
+	}
+}
+
+func CustomRange(start, end time.Time) (ReportRange, error) {
+	if !end.After(start) {
+		return ReportRange{}, fmt.Errorf("report end %s must be after start %s",
+			end.Format("2006-01-02"), start.Format("2006-01-02"))
+	}
+	return ReportRange{
+		Label: fmt.Sprintf("%s to %s", start.Format("2006-01-02"), end.Format("2006-01-02")),
+		Start: start,
+		End:   end,
+	}, nil
+}
+
+func inPeriod(date, start, end time.Time) bool {
+	return !date.Before(start) && date.Before(end)
+}
+
+// MonthTotals is one month of a report, clipped to the report range.
+// InvestmentValue is the market value of the holdings at the month's end.
+type MonthTotals struct {
+	Year            int        `json:"year"`
+	Month           time.Month `json:"month"`
+	Income          Money      `json:"income"`
+	Expenses        Money      `json:"expenses"`
+	Net             Money      `json:"net"`
+	InvestmentValue Money      `json:"investment_value"`
+}
+
+type CategoryTotal struct {
+	Category string `json:"category"`
+	Amount   Money  `json:"amount"`
+}
+
+// PeriodReport holds every figure of a report in the reporting currency.
+type PeriodReport struct {
+	Label                   string          `json:"label"`
+	Start                   time.Time       `json:"start"`
+	End                     time.Time       `json:"end"`
+	Currency                string          `json:"currency"`
+	Account                 string          `json:"account,omitempty"`
+	TotalIncome             Money           `json:"total_income"`
+	TotalExpenses           Money           `json:"total_expenses"`
+	NetProfit               Money           `json:"net_profit"`
+	InvestmentValue         Money           `json:"investment_value"`
+	PreviousInvestmentValue Money           `json:"previous_investment_value"`
+	InvestmentChange        Money           `json:"investment_change"`
+	RealizedGains           Money           `json:"realized_gains"`
+	UnrealizedGains         Money           `json:"unrealized_gains"`
+	Holdings                []Holding       `json:"holdings,omitempty"`
+	Months                  []MonthTotals   `json:"months"`
+	IncomeBySource          []CategoryTotal `json:"income_by_source"`
+	ExpensesByCategory      []CategoryTotal `json:"expenses_by_category"`
+	Budgets                 []BudgetStatus  `json:"budgets,omitempty"`
+}
+
+// BuildReport totals income and expenses in r, broken down by month and by
+// source or category, and values the investments held at its end.
+func (fm *FinanceManager) BuildReport(r ReportRange) (*PeriodReport, error) {
+	fm = fm.view()
+	if r.Account == "" {
+		return fm.buildReport(r)
+	}
+	view, err := fm.forAccount(r.Account)
+	if err != nil {
+		return nil, err
+	}
+	return view.buildReport(r)
+}
+
+func (fm *FinanceManager) buildReport(r ReportRange) (*PeriodReport, error) {
+	report := &PeriodReport{
+		Label:         r.Label,
+		Start:         r.Start,
+		End:           r.End,
+		Currency:      fm.currency,
+		Account:       r.Account,
+		TotalIncome:   Money{Currency: fm.currency},
+		TotalExpenses: Money{Currency: fm.currency},
+	}
+
+	monthIndex := make(map[string]int)
+	for month := time.Date(r.Start.Year(), r.Start.Month(), 1, 0, 0, 0, 0, r.Start.Location()); month.Before(r.End); month = month.AddDate(0, 1, 0) {
+		monthIndex[monthKey(month)] = len(report.Months)
+		report.Months = append(report.Months, MonthTotals{
+			Year:     month.Year(),
+			Month:    month.Month(),
+			Income:   Money{Currency: fm.currency},
+			Expenses: Money{Currency: fm.currency},
+		})
+	}
+
+	incomeBySource := newCategoryTotals(fm.currency)
+	for _, income := range fm.incomes {
+		if !inPeriod(income.Date, r.Start, r.End) {
+			continue
+		}
+		amount, err := fm.toReporting(income.Amount, income.Date)
+		if err != nil {
+			return nil, err
+		}
+		if report.TotalIncome, err = report.TotalIncome.Add(amount); err != nil {
+			return nil, err
+		}
+		month := &report.Months[monthIndex[monthKey(income.Date.In(r.Start.Location()))]]
+		if month.Income, err = month.Income.Add(amount); err != nil {
+			return nil, err
+		}
+		if err := incomeBySource.add(income.Source, amount); err != nil {
+			return nil, err
+		}
+	}
+
+	expensesByCategory := newCategoryTotals(fm.currency)
+	for _, expense := range fm.expenses {
+		if !inPeriod(expense.Date, r.Start, r.End) {
+			continue
+		}
+		amount, err := fm.toReporting(expense.Amount, expense.Date)
+		if err != nil {
+			return nil, err
+		}
+		if report.TotalExpenses, err = report.TotalExpenses.Add(amount); err != nil {
+			return nil, err
+		}
+		month := &report.Months[monthIndex[monthKey(expense.Date.In(r.Start.Location()))]]
+		if month.Expenses, err = month.Expenses.Add(amount); err != nil {
+			return nil, err
+		}
+		if err := expensesByCategory.add(expense.Category, amount); err != nil {
+			return nil, err
+		}
+	}
+
+	var err error
+	for i := range report.Months {
+		month := &report.Months[i]
+		if month.Net, err = month.Income.Sub(month.Expenses); err != nil {
+			return nil, err
+		}
+	}
+	if report.NetProfit, err = report.TotalIncome.Sub(report.TotalExpenses); err != nil {
+		return nil, err
+	}
+	if err := fm.reportInvestments(report, r); err != nil {
+		return nil, err
+	}
+	report.IncomeBySource = incomeBySource.sorted()
+	report.ExpensesByCategory = expensesByCategory.sorted()
+
+	if len(fm.budgets) > 0 {
+		// Past periods are judged on their final totals, the current one on its pace.
+		asOf := time.Now()
+		if last := r.End.Add(-time.Nanosecond); asOf.After(last) {
+			asOf = last
+		} else if asOf.Before(r.Start) {
+			asOf = r.Start
+		}
+		if report.Budgets, err = fm.BudgetProgress(asOf); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// signedMoney formats a change with an explicit sign.
+func signedMoney(m Money) string {
+	if m.IsNegative() {
+		return m.String()
+	}
+	return "+" + m.String()
+}
+
+func monthKey(date time.Time) string {
+	return date.Format("2006-01")
+}
+
+// categoryTotals groups amounts by name, ignoring case and surrounding space.
+type categoryTotals struct {
+	currency string
+	index    map[string]int
+	totals   []CategoryTotal
+}
+
+func newCategoryTotals(currency string) *categoryTotals {
+	return &categoryTotals{currency: currency, index: make(map[string]int)}
+}
+
+func (c *categoryTotals) add(name string, amount Money) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Uncategorized"
+	}
+	key := strings.ToLower(name)
+	i, ok := c.index[key]
+	if !ok {
+		i = len(c.totals)
+		c.index[key] = i
+		c.totals = append(c.totals, CategoryTotal{Category: name, Amount: Money{Currency: c.currency}})
+	}
+	var err error
+	c.totals[i].Amount, err = c.totals[i].Amount.Add(amount)
+	return err
+}
+
+// sorted returns the totals from largest to smallest.
+func (c *categoryTotals) sorted() []CategoryTotal {
+	totals := append([]CategoryTotal{}, c.totals...)
+	sort.SliceStable(totals, func(i, j int) bool { return totals[i].Amount.Minor > totals[j].Amount.Minor })
+	return totals
+}
+
+// GenerateReport renders the report for r as plain text.
+func (fm *FinanceManager) GenerateReport(r ReportRange) (string, error) {
+	fm = fm.view()
+	report, err := fm.BuildReport(r)
+	if err != nil {
+		return "", err
+	}
+	return formatReportText(report), nil
+}
+
+func formatReportText(report *PeriodReport) string {
+	var b strings.Builder
+	currency := report.Currency
+	if report.Account != "" {
+		currency += ", account " + report.Account
+	}
+	fmt.Fprintf(&b, `
+Financial Report for %s (%s)
+-------------------------
+Total Income:     %s
+Total Expenses:   %s
+Net Profit/Loss:  %s
+Investment Value: %s (%s vs previous period)
+Realized Gains:   %s
+Unrealized Gains: %s
+`, report.Label, currency, report.TotalIncome, report.TotalExpenses, report.NetProfit,
+		report.InvestmentValue, signedMoney(report.InvestmentChange), report.RealizedGains, report.UnrealizedGains)
+
+	if len(report.Months) > 1 {
+		b.WriteString("\nBy Month\n-------------------------\n")
+		for _, month := range report.Months {
+			fmt.Fprintf(&b, "%-9s %d  income %s  expenses %s  net %s\n",
+				month.Month, month.Year, month.Income, month.Expenses, month.Net)
+		}
+	}
+	if len(report.IncomeBySource) > 0 {
+		b.WriteString("\nIncome by Source\n-------------------------\n")
+		for _, total := range report.IncomeBySource {
+			fmt.Fprintf(&b, "%-20s %s\n", total.Category, total.Amount)
+		}
+	}
+	if len(report.ExpensesByCategory) > 0 {
+		b.WriteString("\nExpenses by Category\n-------------------------\n")
+		for _, total := range report.ExpensesByCategory {
+			fmt.Fprintf(&b, "%-20s %s\n", total.Category, total.Amount)
+		}
+	}
+	if len(report.Holdings) > 0 {
+		b.WriteString("\nHoldings\n-------------------------\n")
+		formatHoldings(&b, report.Holdings)
+	}
+	if len(report.Budgets) > 0 {
+		b.WriteString("\nBudgets\n-------------------------\n")
+		b.WriteString(formatBudgetStatuses(report.Budgets))
+	}
+	return b.String()
+}
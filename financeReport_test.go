@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMonthlyReportIncludesLastDay(t *testing.T) {
+	fm := NewFinanceManager()
+	fm.AddIncome(time.Date(2024, 1, 31, 18, 30, 0, 0, time.Local), "Salary", usd("3000.00"))
+	fm.AddIncome(time.Date(2024, 2, 1, 0, 0, 0, 0, time.Local), "Salary", usd("3000.00"))
+
+	report, err := fm.BuildReport(MonthRange(2024, time.January))
+	if err != nil {
+		t.Fatalf("Failed to build report: %v", err)
+	}
+	if report.TotalIncome != usd("3000.00") {
+		t.Errorf("Expected only the January 31 income, got %s", report.TotalIncome)
+	}
+}
+
+func TestYearlyReportBreakdown(t *testing.T) {
+	fm := NewFinanceManager()
+	fm.AddIncome(time.Date(2024, 1, 15, 0, 0, 0, 0, time.Local), "Salary", usd("3000.00"))
+	fm.AddIncome(time.Date(2024, 6, 15, 0, 0, 0, 0, time.Local), "Salary", usd("3000.00"))
+	fm.AddExpense(time.Date(2024, 1, 3, 0, 0, 0, 0, time.Local), "Rent", usd("1000.00"))
+	fm.AddExpense(time.Date(2024, 6, 3, 0, 0, 0, 0, time.Local), "rent", usd("1000.00"))
+	fm.AddExpense(time.Date(2024, 6, 9, 0, 0, 0, 0, time.Local), "Food", usd("250.00"))
+	fm.AddExpense(time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local), "Food", usd("99.00"))
+
+	report, err := fm.BuildReport(YearRange(2024))
+	if err != nil {
+		t.Fatalf("Failed to build report: %v", err)
+	}
+	if len(report.Months) != 12 {
+		t.Fatalf("Expected 12 months, got %d", len(report.Months))
+	}
+	if june := report.Months[5]; june.Income != usd("3000.00") || june.Expenses != usd("1250.00") || june.Net != usd("1750.00") {
+		t.Errorf("Unexpected June totals %+v", june)
+	}
+	if report.NetProfit != usd("3750.00") {
+		t.Errorf("Expected net profit $3750.00, got %s", report.NetProfit)
+	}
+	if len(report.ExpensesByCategory) != 2 || report.ExpensesByCategory[0].Category != "Rent" ||
+		report.ExpensesByCategory[0].Amount != usd("2000.00") {
+		t.Errorf("Expected Rent to be grouped case-insensitively, got %+v", report.ExpensesByCategory)
+	}
+
+	q2, _ := QuarterRange(2024, 2)
+	if report, _ := fm.BuildReport(q2); report.TotalExpenses != usd("1250.00") || len(report.Months) != 3 {
+		t.Errorf("Unexpected Q2 report %+v", report)
+	}
+
+	custom, err := CustomRange(time.Date(2024, 1, 10, 0, 0, 0, 0, time.Local), time.Date(2024, 2, 10, 0, 0, 0, 0, time.Local))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report, _ := fm.BuildReport(custom); report.TotalIncome != usd("3000.00") || report.TotalExpenses.Minor != 0 || len(report.Months) != 2 {
+		t.Errorf("Unexpected custom range report %+v", report)
+	}
+	if _, err := CustomRange(custom.End, custom.Start); err == nil {
+		t.Error("Expected error for an inverted range")
+	}
+}
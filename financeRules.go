@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// CategoryRule assigns Category to expenses that meet every condition it
+// sets. Amount bounds are inclusive and read in the expense's currency.
+type CategoryRule struct {
+	Category      string `json:"category"`
+	PayeeContains string `json:"payee_contains,omitempty"`
+	PayeeRegex    string `json:"payee_regex,omitempty"`
+	MinAmount     string `json:"min_amount,omitempty"`
+	MaxAmount     string `json:"max_amount,omitempty"`
+
+	regex *regexp.Regexp
+}
+
+// CategoryRules is the categorization config file, e.g.
+//
+//	{
+//	  "categories": ["Housing", "Groceries"],
+//	  "aliases": {"rent": "Housing"},
+//	  "rules": [{"category": "Groceries", "payee_regex": "(?i)aldi|lidl"}]
+//	}
+//
+// Rules are tried in order and the first match wins.
+type CategoryRules struct {
+	Categories []string          `json:"categories"`
+	Aliases    map[string]string `json:"aliases"`
+	Rules      []CategoryRule    `json:"rules"`
+
+	canonical map[string]string
+}
+
+func LoadCategoryRules(path string) (*CategoryRules, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	rules := &CategoryRules{}
+	if err := json.Unmarshal(content, rules); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", path, err)
+	}
+	if err := rules.compile(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// compile validates the config and prepares it for matching.
+func (r *CategoryRules) compile() error {
+	r.canonical = make(map[string]string)
+	for _, category := range r.Categories {
+		r.canonical[categoryKey(category)] = strings.TrimSpace(category)
+	}
+	for alias, category := range r.Aliases {
+		target, ok := r.canonical[categoryKey(category)]
+		if !ok && len(r.Categories) > 0 {
+			return fmt.Errorf("alias %q points to unknown category %q", alias, category)
+		}
+		if !ok {
+			target = strings.TrimSpace(category)
+		}
+		r.canonical[categoryKey(alias)] = target
+	}
+
+	for i := range r.Rules {
+		rule := &r.Rules[i]
+		if _, ok := r.canonical[categoryKey(rule.Category)]; !ok && len(r.Categories) > 0 {
+			return fmt.Errorf("rule %d: unknown category %q", i+1, rule.Category)
+		}
+		if rule.PayeeContains == "" && rule.PayeeRegex == "" && rule.MinAmount == "" && rule.MaxAmount == "" {
+			return fmt.Errorf("rule %d: no conditions", i+1)
+		}
+		if rule.PayeeRegex != "" {
+			regex, err := regexp.Compile(rule.PayeeRegex)
+			if err != nil {
+				return fmt.Errorf("rule %d: %w", i+1, err)
+			}
+			rule.regex = regex
+		}
+		for _, bound := range []string{rule.MinAmount, rule.MaxAmount} {
+			if bound != "" {
+				if _, err := ParseMoney(bound, defaultCurrency); err != nil {
+					return fmt.Errorf("rule %d: %w", i+1, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func categoryKey(category string) string {
+	return strings.ToLower(strings.TrimSpace(category))
+}
+
+// Normalize maps category onto the managed list through case-insensitive
+// names and aliases. known is false when category is not on the list.
+func (r *CategoryRules) Normalize(category string) (normalized string, known bool) {
+	category = strings.TrimSpace(category)
+	if canonical, ok := r.canonical[categoryKey(category)]; ok {
+		return canonical, true
+	}
+	return category, len(r.Categories) == 0 && category != ""
+}
+
+// Categorize returns the category of the first rule expense matches.
+func (r *CategoryRules) Categorize(expense Expense) (string, bool) {
+	for _, rule := range r.Rules {
+		if rule.matches(expense) {
+			category, _ := r.Normalize(rule.Category)
+			return category, true
+		}
+	}
+	return "", false
+}
+
+func (rule CategoryRule) matches(expense Expense) bool {
+	payee := expense.Payee
+	if rule.PayeeContains != "" && !strings.Contains(strings.ToLower(payee), strings.ToLower(rule.PayeeContains)) {
+		return false
+	}
+	if rule.regex != nil && !rule.regex.MatchString(payee) {
+		return false
+	}
+	if rule.MinAmount != "" {
+		min, err := ParseMoney(rule.MinAmount, expense.Amount.Currency)
+		if err != nil || expense.Amount.Minor < min.Minor {
+			return false
+		}
+	}
+	if rule.MaxAmount != "" {
+		max, err := ParseMoney(rule.MaxAmount, expense.Amount.Currency)
+		if err != nil || expense.Amount.Minor > max.Minor {
+			return false
+		}
+	}
+	return true
+}
+
+func (fm *FinanceManager) SetCategoryRules(rules *CategoryRules) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	fm.categoryRules = rules
+}
+
+// HasCategoryRules reports whether expenses without a category can get
+// one from the rules.
+func (fm *FinanceManager) HasCategoryRules() bool {
+	fm.mu.RLock()
+	defer fm.mu.RUnlock()
+	return fm.categoryRules != nil
+}
+
+// categorize fills in a missing category from the rules and normalizes it.
+func (fm *FinanceManager) categorize(expense Expense) Expense {
+	if fm.categoryRules == nil {
+		return expense
+	}
+	if strings.TrimSpace(expense.Category) == "" {
+		if category, ok := fm.categoryRules.Categorize(expense); ok {
+			expense.Category = category
+			return expense
+		}
+	}
+	expense.Category, _ = fm.categoryRules.Normalize(expense.Category)
+	return expense
+}
+
+// RecategorizeExpenses re-runs the rules over stored expenses. Only
+// expenses without a category on the managed list are re-assigned unless
+// overwrite is set; every category is normalized. It returns how many
+// expenses changed.
+func (fm *FinanceManager) RecategorizeExpenses(overwrite bool) (int, error) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	if fm.categoryRules == nil {
+		return 0, fmt.Errorf("no category rules loaded")
+	}
+
+	old := fm.expenses
+	updated := append([]Expense(nil), old...)
+	changed := 0
+	for i, expense := range updated {
+		category, known := fm.categoryRules.Normalize(expense.Category)
+		if overwrite || !known {
+			if ruleCategory, ok := fm.categoryRules.Categorize(expense); ok {
+				category = ruleCategory
+			}
+		}
+		if category != expense.Category {
+			updated[i].Category = category
+			fm.book(expense.ID)
+			changed++
+		}
+	}
+	if changed == 0 {
+		return 0, nil
+	}
+
+	fm.expenses = updated
+	if err := fm.commit(func() { fm.expenses = old }); err != nil {
+		return 0, err
+	}
+	return changed, nil
+}
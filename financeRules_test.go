@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testCategoryRules = `{
+  "categories": ["Housing", "Groceries", "Subscriptions"],
+  "aliases": {"rent": "Housing", "food": "Groceries"},
+  "rules": [
+    {"category": "Housing", "payee_contains": "landlord"},
+    {"category": "Groceries", "payee_regex": "(?i)^(aldi|lidl)\\b"},
+    {"category": "Subscriptions", "payee_contains": "stream", "max_amount": "20"}
+  ]
+}`
+
+func loadTestCategoryRules(t *testing.T) *CategoryRules {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(path, []byte(testCategoryRules), 0600); err != nil {
+		t.Fatal(err)
+	}
+	rules, err := LoadCategoryRules(path)
+	if err != nil {
+		t.Fatalf("Failed to load rules: %v", err)
+	}
+	return rules
+}
+
+func TestCategoryRules(t *testing.T) {
+	rules := loadTestCategoryRules(t)
+
+	for input, want := range map[string]string{"rent": "Housing", " housing ": "Housing", "FOOD": "Groceries"} {
+		if got, known := rules.Normalize(input); got != want || !known {
+			t.Errorf("Normalize(%q) = %q, %v; want %q", input, got, known, want)
+		}
+	}
+	if _, known := rules.Normalize("Misc"); known {
+		t.Error("Expected Misc to be outside the managed list")
+	}
+
+	tests := []struct {
+		payee  string
+		amount Money
+		want   string
+	}{
+		{"Monthly rent - Landlord Ltd", usd("950.00"), "Housing"},
+		{"LIDL 1234", usd("40.10"), "Groceries"},
+		{"Streamflix", usd("12.99"), "Subscriptions"},
+		{"Streamflix annual", usd("120.00"), ""},
+	}
+	for _, tt := range tests {
+		got, _ := rules.Categorize(Expense{Payee: tt.payee, Amount: tt.amount})
+		if got != tt.want {
+			t.Errorf("Categorize(%q, %s) = %q; want %q", tt.payee, tt.amount, got, tt.want)
+		}
+	}
+}
+
+func TestRecategorizeExpenses(t *testing.T) {
+	fm := NewFinanceManager()
+	date := time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)
+	fm.CreateExpense(Expense{Date: date, Category: "rent", Payee: "Landlord", Amount: usd("950.00")})
+	fm.CreateExpense(Expense{Date: date, Category: "Misc", Payee: "ALDI Sued", Amount: usd("30.00")})
+
+	fm.SetCategoryRules(loadTestCategoryRules(t))
+	created, _ := fm.CreateExpense(Expense{Date: date, Payee: "Lidl", Amount: usd("20.00")})
+	if created.Category != "Groceries" {
+		t.Errorf("Expected new expense to be categorized as Groceries, got %q", created.Category)
+	}
+
+	changed, err := fm.RecategorizeExpenses(false)
+	if err != nil || changed != 2 {
+		t.Fatalf("Expected 2 changed expenses, got %d (%v)", changed, err)
+	}
+	expenses := fm.ListExpenses()
+	if expenses[0].Category != "Housing" || expenses[1].Category != "Groceries" {
+		t.Errorf("Unexpected categories %q and %q", expenses[0].Category, expenses[1].Category)
+	}
+}
+
+func TestAddExpenseCommandCategorizes(t *testing.T) {
+	fm := NewFinanceManager()
+	fm.SetCategoryRules(loadTestCategoryRules(t))
+	var out bytes.Buffer
+	err := runFinanceCommand(fm, nil, []string{"add-expense", "-date", "2024-03-02", "-amount", "950", "-payee", "Landlord Ltd"}, &out)
+	if err != nil {
+		t.Fatalf("add-expense failed: %v", err)
+	}
+	if expenses := fm.ListExpenses(); len(expenses) != 1 || expenses[0].Category != "Housing" {
+		t.Errorf("Expected the landlord rule to assign Housing, got %+v", expenses)
+	}
+
+	err = runFinanceCommand(fm, nil, []string{"add-expense", "-date", "2024-03-02", "-amount", "5", "-payee", "Kiosk"}, &out)
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Errorf("Expected a validation error when no rule matches, got %v", err)
+	}
+}
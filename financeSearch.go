@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+const maxTagLength = 40
+
+func validateTags(tags []string) error {
+	for _, tag := range tags {
+		tag = strings.TrimSpace(tag)
+		if strings.Contains(tag, ",") {
+			return fmt.Errorf("tag %q must not contain a comma", tag)
+		}
+		if len(tag) > maxTagLength {
+			return fmt.Errorf("tag %q is longer than %d characters", tag, maxTagLength)
+		}
+	}
+	return nil
+}
+
+// normalizeTags lower-cases tags and drops blanks and duplicates, so
+// "Berlin" and "berlin " are the same tag.
+func normalizeTags(tags []string) []string {
+	var normalized []string
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" && !hasTag(normalized, tag) {
+			normalized = append(normalized, tag)
+		}
+	}
+	return normalized
+}
+
+// splitTags parses a comma separated tag list as typed on the command line.
+func splitTags(input string) []string {
+	if strings.TrimSpace(input) == "" {
+		return nil
+	}
+	return normalizeTags(strings.Split(input, ","))
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// EntryQuery selects entries for Search. Zero fields do not filter: Text
+// matches every word anywhere in the description, payee, notes or tags, an
+// entry must carry all of Tags, and amounts are compared in the reporting
+// currency. Start and End form a half-open range like ReportRange.
+type EntryQuery struct {
+	Text      string
+	Tags      []string
+	Type      string
+	Account   string
+	MinAmount *Money
+	MaxAmount *Money
+	Start     time.Time
+	End       time.Time
+
+	// Sort is date, amount, description or type, prefixed with "-" for
+	// descending order. The default is date.
+	Sort   string
+	Offset int
+	Limit  int
+}
+
+// SearchResult holds one page of matches. Total counts every match.
+type SearchResult struct {
+	Total   int        `json:"total"`
+	Offset  int        `json:"offset"`
+	Entries []entryRow `json:"entries"`
+}
+
+type searchEntry struct {
+	row    entryRow
+	text   string
+	amount Money
+}
+
+// Search returns the entries matching q, sorted and paginated.
+func (fm *FinanceManager) Search(q EntryQuery) (*SearchResult, error) {
+	fm = fm.view()
+	less, err := searchOrder(q.Sort)
+	if err != nil {
+		return nil, err
+	}
+	if q.Offset < 0 || q.Limit < 0 {
+		return nil, fmt.Errorf("offset and limit must not be negative")
+	}
+	if !q.Start.IsZero() && !q.End.IsZero() && !q.End.After(q.Start) {
+		return nil, fmt.Errorf("search end %s must be after start %s", q.End.Format("2006-01-02"), q.Start.Format("2006-01-02"))
+	}
+	for _, bound := range []*Money{q.MinAmount, q.MaxAmount} {
+		if bound != nil && bound.Currency != fm.currency {
+			return nil, fmt.Errorf("amount bounds must be in %s", fm.currency)
+		}
+	}
+
+	view := fm
+	if q.Account != "" {
+		if view, err = fm.forAccount(q.Account); err != nil {
+			return nil, err
+		}
+	}
+	kind := q.Type
+	if kind == "" {
+		kind = "all"
+	}
+	rows, err := view.entryRows(kind)
+	if err != nil {
+		return nil, err
+	}
+	payees := make(map[string]string)
+	for _, expense := range view.expenses {
+		payees[expense.ID] = expense.Payee
+	}
+
+	words := strings.Fields(strings.ToLower(q.Text))
+	var matches []searchEntry
+	for _, row := range rows {
+		if row.Date.Before(q.Start) || (!q.End.IsZero() && !row.Date.Before(q.End)) {
+			continue
+		}
+		match := true
+		for _, tag := range q.Tags {
+			if tag = strings.TrimSpace(tag); tag != "" && !hasTag(row.Tags, tag) {
+				match = false
+				break
+			}
+		}
+		if !match {
+			continue
+		}
+
+		fields := append([]string{row.Description, payees[row.ID], row.Notes}, row.Tags...)
+		entry := searchEntry{row: row, text: strings.ToLower(strings.Join(fields, " "))}
+		for _, word := range words {
+			if !strings.Contains(entry.text, word) {
+				match = false
+				break
+			}
+		}
+		if !match {
+			continue
+		}
+
+		if entry.amount, err = fm.toReporting(row.Amount, row.Date); err != nil {
+			return nil, err
+		}
+		if q.MinAmount != nil && entry.amount.Minor < q.MinAmount.Minor {
+			continue
+		}
+		if q.MaxAmount != nil && entry.amount.Minor > q.MaxAmount.Minor {
+			continue
+		}
+		matches = append(matches, entry)
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return less(matches[i], matches[j]) })
+
+	result := &SearchResult{Total: len(matches), Offset: q.Offset, Entries: []entryRow{}}
+	for i := q.Offset; i < len(matches); i++ {
+		if q.Limit > 0 && len(result.Entries) == q.Limit {
+			break
+		}
+		result.Entries = append(result.Entries, matches[i].row)
+	}
+	return result, nil
+}
+
+// searchOrder returns the comparison for a Sort value.
+func searchOrder(order string) (func(a, b searchEntry) bool, error) {
+	descending := strings.HasPrefix(order, "-")
+	var less func(a, b searchEntry) bool
+	switch strings.TrimPrefix(order, "-") {
+	case "", "date":
+		less = func(a, b searchEntry) bool { return a.row.Date.Before(b.row.Date) }
+	case "amount":
+		less = func(a, b searchEntry) bool { return a.amount.Minor < b.amount.Minor }
+	case "description":
+		less = func(a, b searchEntry) bool {
+			return strings.ToLower(a.row.Description) < strings.ToLower(b.row.Description)
+		}
+	case "type":
+		less = func(a, b searchEntry) bool { return a.row.Type < b.row.Type }
+	default:
+		return nil, fmt.Errorf("unknown sort %q, expected date, amount, description or type", order)
+	}
+	if descending {
+		return func(a, b searchEntry) bool { return less(b, a) }, nil
+	}
+	return less, nil
+}
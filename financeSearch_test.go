@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func searchFixture(t *testing.T) *FinanceManager {
+	t.Helper()
+	fm := NewFinanceManager()
+	entries := []Expense{
+		{Date: date(2024, 5, 2), Category: "Travel", Payee: "Deutsche Bahn", Amount: usd("89.00"), Tags: []string{"Berlin", "trip", "berlin"}},
+		{Date: date(2024, 5, 3), Category: "Food", Payee: "Curry 36", Amount: usd("12.50"), Tags: []string{"berlin"}, Notes: "Late dinner after the museum"},
+		{Date: date(2024, 5, 4), Category: "Lodging", Payee: "Hotel Adlon", Amount: usd("420.00"), Tags: []string{"berlin", "trip"}},
+		{Date: date(2024, 6, 1), Category: "Food", Payee: "Grocer", Amount: usd("64.20")},
+	}
+	for _, expense := range entries {
+		if _, err := fm.CreateExpense(expense); err != nil {
+			t.Fatalf("Failed to add expense: %v", err)
+		}
+	}
+	fm.CreateIncome(Income{Date: date(2024, 5, 31), Source: "Salary", Amount: usd("3000.00"), Tags: []string{"trip"}})
+	return fm
+}
+
+func TestSearchFilters(t *testing.T) {
+	fm := searchFixture(t)
+	if expense, _ := fm.GetExpense("exp-1"); len(expense.Tags) != 2 || expense.Tags[0] != "berlin" {
+		t.Errorf("Expected tags to be normalized, got %q", expense.Tags)
+	}
+
+	low, high := usd("10.00"), usd("100.00")
+	tests := []struct {
+		name string
+		q    EntryQuery
+		want []string
+	}{
+		{"tag", EntryQuery{Tags: []string{"BERLIN"}}, []string{"exp-1", "exp-2", "exp-3"}},
+		{"all tags", EntryQuery{Tags: []string{"berlin", "trip"}}, []string{"exp-1", "exp-3"}},
+		{"type", EntryQuery{Tags: []string{"trip"}, Type: "income"}, []string{"inc-5"}},
+		{"payee text", EntryQuery{Text: "adlon"}, []string{"exp-3"}},
+		{"notes text", EntryQuery{Text: "museum dinner"}, []string{"exp-2"}},
+		{"amount range", EntryQuery{MinAmount: &low, MaxAmount: &high}, []string{"exp-1", "exp-2", "exp-4"}},
+		{"date range", EntryQuery{Start: date(2024, 5, 3), End: date(2024, 6, 1)}, []string{"exp-2", "exp-3", "inc-5"}},
+		{"sort by amount", EntryQuery{Type: "expense", Sort: "-amount"}, []string{"exp-3", "exp-1", "exp-4", "exp-2"}},
+		{"page", EntryQuery{Sort: "date", Offset: 1, Limit: 2}, []string{"exp-2", "exp-3"}},
+	}
+	for _, tc := range tests {
+		result, err := fm.Search(tc.q)
+		if err != nil {
+			t.Fatalf("%s: search failed: %v", tc.name, err)
+		}
+		var got []string
+		for _, row := range result.Entries {
+			got = append(got, row.ID)
+		}
+		if len(got) != len(tc.want) {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, got)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("%s: expected %v, got %v", tc.name, tc.want, got)
+				break
+			}
+		}
+	}
+
+	result, _ := fm.Search(EntryQuery{Limit: 2})
+	if result.Total != 5 || len(result.Entries) != 2 {
+		t.Errorf("Expected 2 of 5 matches, got %d of %d", len(result.Entries), result.Total)
+	}
+	if _, err := fm.Search(EntryQuery{Sort: "payee"}); err == nil {
+		t.Error("Expected error for an unknown sort")
+	}
+	if _, err := fm.CreateExpense(Expense{Date: date(2024, 1, 1), Category: "Food", Amount: usd("1.00"), Tags: []string{"a,b"}}); err == nil {
+		t.Error("Expected error for a tag with a comma")
+	}
+}
+
+func TestSearchCommand(t *testing.T) {
+	fm := searchFixture(t)
+	var out bytes.Buffer
+	err := runFinanceCommand(fm, nil, []string{"add-expense", "-date", "2024-05-05", "-amount", "30", "-category", "Museum",
+		"-tags", "Berlin, culture", "-notes", "Pergamon"}, &out)
+	if err != nil {
+		t.Fatalf("add-expense failed: %v", err)
+	}
+
+	out.Reset()
+	err = runFinanceCommand(fm, nil, []string{"search", "-tag", "berlin", "-min", "20", "-sort", "-amount", "-limit", "2", "-format", "json"}, &out)
+	if err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	var result SearchResult
+	if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+		t.Fatalf("search output is not JSON: %v\n%s", err, out.String())
+	}
+	if result.Total != 3 || len(result.Entries) != 2 || result.Entries[0].Description != "Lodging" {
+		t.Errorf("Unexpected search result %+v", result)
+	}
+
+	out.Reset()
+	if err := runFinanceCommand(fm, nil, []string{"search", "-text", "pergamon"}, &out); err != nil {
+		t.Fatalf("search failed: %v", err)
+	}
+	if !bytes.Contains(out.Bytes(), []byte("berlin,culture")) || !bytes.Contains(out.Bytes(), []byte("1 of 1 matches")) {
+		t.Errorf("Expected the museum entry with its tags, got:\n%s", out.String())
+	}
+}
@@ -0,0 +1,288 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// entryResource connects one kind of entry to the REST routes
+// /<name> and /<name>/{id}. create and update decode the request body.
+type entryResource struct {
+	name   string
+	list   func() any
+	get    func(id string) (any, error)
+	create func(body io.Reader) (id string, err error)
+	update func(id string, body io.Reader) error
+	remove func(id string) error
+}
+
+// NewFinanceAPI serves fm as a JSON REST API under /v1:
+//
+//	GET    /v1/incomes             list
+//	POST   /v1/incomes             create, returns 201 and the entry
+//	GET    /v1/incomes/{id}        read
+//	PUT    /v1/incomes/{id}        replace
+//	DELETE /v1/incomes/{id}        delete, returns 204
+//	GET    /v1/reports?year=2024&month=3
+//
+// and the same for expenses and investments. Reports take the periods of
+// the report command: year, month, quarter or from and to, plus account.
+func NewFinanceAPI(fm *FinanceManager) http.Handler {
+	router := http.NewServeMux()
+	for _, res := range fm.entryResources() {
+		registerEntryResource(router, res)
+	}
+	router.HandleFunc(fmt.Sprintf("%s %s", http.MethodGet, "/reports"), func(w http.ResponseWriter, r *http.Request) {
+		period, err := parseReportQuery(r.URL.Query())
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		report, err := fm.BuildReport(period)
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		writeAPIJSON(w, http.StatusOK, report)
+	})
+
+	stack := middlewareStack(logger2, limitRequestBody)
+
+	v1 := http.NewServeMux()
+	v1.Handle("/v1/", http.StripPrefix("/v1", router))
+	return stack(v1)
+}
+
+const maxRequestBody = 1 << 20
+
+func limitRequestBody(next http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, maxRequestBody)
+			next.ServeHTTP(w, r)
+		},
+	)
+}
+
+func (fm *FinanceManager) entryResources() []entryResource {
+	return []entryResource{
+		{
+			name: "incomes",
+			list: func() any { return fm.ListIncomes() },
+			get:  func(id string) (any, error) { return fm.GetIncome(id) },
+			create: func(body io.Reader) (string, error) {
+				var income Income
+				if err := decodeAPIBody(body, &income); err != nil {
+					return "", err
+				}
+				income, err := fm.CreateIncome(income)
+				return income.ID, err
+			},
+			update: func(id string, body io.Reader) error {
+				var income Income
+				if err := decodeAPIBody(body, &income); err != nil {
+					return err
+				}
+				income.ID = id
+				return fm.UpdateIncome(income)
+			},
+			remove: fm.DeleteIncome,
+		},
+		{
+			name: "expenses",
+			list: func() any { return fm.ListExpenses() },
+			get:  func(id string) (any, error) { return fm.GetExpense(id) },
+			create: func(body io.Reader) (string, error) {
+				var expense Expense
+				if err := decodeAPIBody(body, &expense); err != nil {
+					return "", err
+				}
+				expense, err := fm.CreateExpense(expense)
+				return expense.ID, err
+			},
+			update: func(id string, body io.Reader) error {
+				var expense Expense
+				if err := decodeAPIBody(body, &expense); err != nil {
+					return err
+				}
+				expense.ID = id
+				return fm.UpdateExpense(expense)
+			},
+			remove: fm.DeleteExpense,
+		},
+		{
+			name: "investments",
+			list: func() any { return fm.ListInvestments() },
+			get:  func(id string) (any, error) { return fm.GetInvestment(id) },
+			create: func(body io.Reader) (string, error) {
+				var investment Investment
+				if err := decodeAPIBody(body, &investment); err != nil {
+					return "", err
+				}
+				investment, err := fm.CreateInvestment(investment)
+				return investment.ID, err
+			},
+			update: func(id string, body io.Reader) error {
+				var investment Investment
+				if err := decodeAPIBody(body, &investment); err != nil {
+					return err
+				}
+				investment.ID = id
+				return fm.UpdateInvestment(investment)
+			},
+			remove: fm.DeleteInvestment,
+		},
+	}
+}
+
+func registerEntryResource(router *http.ServeMux, res entryResource) {
+	collection := "/" + res.name
+	item := collection + "/{id}"
+
+	router.HandleFunc(fmt.Sprintf("%s %s", http.MethodGet, collection), func(w http.ResponseWriter, r *http.Request) {
+		writeAPIJSON(w, http.StatusOK, res.list())
+	})
+	router.HandleFunc(fmt.Sprintf("%s %s", http.MethodPost, collection), func(w http.ResponseWriter, r *http.Request) {
+		id, err := res.create(r.Body)
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		entry, err := res.get(id)
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		w.Header().Set("Location", "/v1"+collection+"/"+id)
+		writeAPIJSON(w, http.StatusCreated, entry)
+	})
+	router.HandleFunc(fmt.Sprintf("%s %s", http.MethodGet, item), func(w http.ResponseWriter, r *http.Request) {
+		entry, err := res.get(r.PathValue("id"))
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		writeAPIJSON(w, http.StatusOK, entry)
+	})
+	router.HandleFunc(fmt.Sprintf("%s %s", http.MethodPut, item), func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		if err := res.update(id, r.Body); err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		entry, err := res.get(id)
+		if err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		writeAPIJSON(w, http.StatusOK, entry)
+	})
+	router.HandleFunc(fmt.Sprintf("%s %s", http.MethodDelete, item), func(w http.ResponseWriter, r *http.Request) {
+		if err := res.remove(r.PathValue("id")); err != nil {
+			writeAPIError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// decodeAPIBody reads one JSON value into v, rejecting unknown fields.
+func decodeAPIBody(body io.Reader, v any) error {
+	decoder := json.NewDecoder(body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return invalid(fmt.Errorf("invalid request body: %w", err))
+	}
+	return nil
+}
+
+// parseReportQuery reads a report period the way the report command reads
+// its flags; without any it is the current month.
+func parseReportQuery(query url.Values) (ReportRange, error) {
+	number := func(name string, fallback int) (int, error) {
+		value := query.Get(name)
+		if value == "" {
+			return fallback, nil
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, invalid(fmt.Errorf("invalid %s %q", name, value))
+		}
+		return n, nil
+	}
+
+	now := time.Now()
+	year, err := number("year", now.Year())
+	if err != nil {
+		return ReportRange{}, err
+	}
+	month, err := number("month", int(now.Month()))
+	if err != nil {
+		return ReportRange{}, err
+	}
+	quarter, err := number("quarter", 0)
+	if err != nil {
+		return ReportRange{}, err
+	}
+
+	var r ReportRange
+	switch {
+	case query.Has("from") || query.Has("to"):
+		start, err := parseDate(query.Get("from"))
+		if err != nil {
+			return ReportRange{}, invalid(err)
+		}
+		end, err := parseDate(query.Get("to"))
+		if err != nil {
+			return ReportRange{}, invalid(err)
+		}
+		if r, err = CustomRange(start, end); err != nil {
+			return ReportRange{}, invalid(err)
+		}
+	case query.Has("quarter"):
+		if r, err = QuarterRange(year, quarter); err != nil {
+			return ReportRange{}, invalid(err)
+		}
+	case query.Has("year") && !query.Has("month"):
+		r = YearRange(year)
+	default:
+		if month < 1 || month > 12 {
+			return ReportRange{}, invalid(fmt.Errorf("invalid month %d", month))
+		}
+		r = MonthRange(year, time.Month(month))
+	}
+	r.Account = query.Get("account")
+	return r, nil
+}
+
+func writeAPIJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeAPIError maps rejected input to 400 and unknown IDs to 404; any
+// other error is the server's fault.
+func writeAPIError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var notFound *NotFoundError
+	var validation *ValidationError
+	switch {
+	case errors.As(err, &notFound):
+		status = http.StatusNotFound
+	case errors.As(err, &validation):
+		status = http.StatusBadRequest
+	}
+	writeAPIJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+func serveFinanceAPI(fm *FinanceManager, addr string, out io.Writer) error {
+	fmt.Fprintf(out, "Finance API listening on %s\n", addr)
+	return http.ListenAndServe(addr, NewFinanceAPI(fm))
+}
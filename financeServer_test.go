@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// quietLog silences the request logging middleware for the test.
+func quietLog(t *testing.T) {
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+}
+
+func apiRequest(t *testing.T, handler http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestFinanceAPIEntries(t *testing.T) {
+	quietLog(t)
+	fm := NewFinanceManager()
+	api := NewFinanceAPI(fm)
+
+	rec := apiRequest(t, api, http.MethodPost, "/v1/expenses",
+		`{"date":"2024-03-02T00:00:00Z","category":"Rent","amount":{"amount":"950.50","currency":"USD"}}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var expense Expense
+	if err := json.Unmarshal(rec.Body.Bytes(), &expense); err != nil {
+		t.Fatalf("Response is not an expense: %v", err)
+	}
+	if expense.ID == "" || expense.Amount != usd("950.50") || rec.Header().Get("Location") != "/v1/expenses/"+expense.ID {
+		t.Errorf("Unexpected created expense %+v at %q", expense, rec.Header().Get("Location"))
+	}
+
+	rec = apiRequest(t, api, http.MethodPut, "/v1/expenses/"+expense.ID,
+		`{"date":"2024-03-02T00:00:00Z","category":"Rent","amount":{"amount":"975.00","currency":"USD"}}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"975.00"`) {
+		t.Errorf("Expected updated expense, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = apiRequest(t, api, http.MethodGet, "/v1/expenses", "")
+	var expenses []Expense
+	if err := json.Unmarshal(rec.Body.Bytes(), &expenses); err != nil || len(expenses) != 1 {
+		t.Errorf("Expected one expense, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = apiRequest(t, api, http.MethodDelete, "/v1/expenses/"+expense.ID, "")
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d", rec.Code)
+	}
+	if len(fm.ListExpenses()) != 0 {
+		t.Error("Expected expense to be deleted")
+	}
+}
+
+func TestFinanceAPIErrors(t *testing.T) {
+	quietLog(t)
+	fm := NewFinanceManager()
+	fm.AddIncome(date(2024, 3, 1), "Salary", usd("5000.00"))
+	api := NewFinanceAPI(fm)
+
+	tests := []struct {
+		method, path, body string
+		want               int
+	}{
+		{http.MethodGet, "/v1/incomes/inc-99", "", http.StatusNotFound},
+		{http.MethodDelete, "/v1/investments/inv-1", "", http.StatusNotFound},
+		{http.MethodPut, "/v1/incomes/inc-99", `{"source":"X","amount":{"amount":"1","currency":"USD"}}`, http.StatusNotFound},
+		{http.MethodPost, "/v1/incomes", `{"source":"Gift","amount":{"amount":"-5","currency":"USD"}}`, http.StatusBadRequest},
+		{http.MethodPost, "/v1/incomes", `{"source":"Gift","amount":{"amount":"5","currency":"USD"},"account":"Missing"}`, http.StatusBadRequest},
+		{http.MethodPost, "/v1/incomes", `{"source":"Gift","bogus":true}`, http.StatusBadRequest},
+		{http.MethodPost, "/v1/incomes", `{"source":"Gift","amount":{"amount":"5","currency":"USD"}}`, http.StatusBadRequest},
+		{http.MethodPost, "/v1/incomes", `{"date":"2024-03-02T00:00:00Z","source":" ","amount":{"amount":"5","currency":"USD"}}`, http.StatusBadRequest},
+		{http.MethodPost, "/v1/expenses", `{"date":"2024-03-02T00:00:00Z","amount":{"amount":"5","currency":"USD"}}`, http.StatusBadRequest},
+		{http.MethodPost, "/v1/incomes", `not json`, http.StatusBadRequest},
+		{http.MethodPost, "/v1/investments", `{"asset":"ACME","quantity":"5","value":{"amount":"50","currency":"USD"}}`, http.StatusBadRequest},
+		{http.MethodPost, "/v1/investments", `{"date":"2024-03-02T00:00:00Z","asset":" ","quantity":"5","value":{"amount":"50","currency":"USD"}}`, http.StatusBadRequest},
+		{http.MethodPost, "/v1/investments", `{"asset":"ACME","type":"sell","quantity":"5","value":{"amount":"50","currency":"USD"}}`, http.StatusBadRequest},
+		{http.MethodGet, "/v1/reports?month=13", "", http.StatusBadRequest},
+		{http.MethodGet, "/v1/reports?from=2024-03-01", "", http.StatusBadRequest},
+		{http.MethodGet, "/v1/reports?year=2024&month=3&account=Missing", "", http.StatusNotFound},
+		{http.MethodPatch, "/v1/incomes/inc-1", "", http.StatusMethodNotAllowed},
+	}
+	for _, tc := range tests {
+		rec := apiRequest(t, api, tc.method, tc.path, tc.body)
+		if rec.Code != tc.want {
+			t.Errorf("%s %s: expected %d, got %d: %s", tc.method, tc.path, tc.want, rec.Code, rec.Body.String())
+		}
+		if tc.want != http.StatusMethodNotAllowed && !strings.Contains(rec.Body.String(), `"error"`) {
+			t.Errorf("%s %s: expected a JSON error, got %s", tc.method, tc.path, rec.Body.String())
+		}
+	}
+
+	rec := apiRequest(t, api, http.MethodGet, "/v1/reports?year=2024&month=3", "")
+	var report PeriodReport
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Report is not JSON: %v\n%s", err, rec.Body.String())
+	}
+	if rec.Code != http.StatusOK || report.TotalIncome != usd("5000.00") {
+		t.Errorf("Expected March report with $5000.00 income, got %d: %+v", rec.Code, report)
+	}
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// FinanceData is the persisted state of a FinanceManager.
+type FinanceData struct {
+	Incomes     []Income            `json:"incomes"`
+	Expenses    []Expense           `json:"expenses"`
+	Investments []Investment        `json:"investments"`
+	Budgets     []Budget            `json:"budgets"`
+	Recurring   []RecurringTemplate `json:"recurring"`
+	Prices      []AssetPrice        `json:"prices"`
+	Ledger      *LedgerData         `json:"ledger,omitempty"`
+	Accounts    []Account           `json:"accounts"`
+	NextID      int                 `json:"next_id"`
+	History     []AuditEvent        `json:"history,omitempty"`
+}
+
+// FinanceStore loads and saves the state behind a FinanceManager.
+// Save is called after every mutation with the complete state.
+type FinanceStore interface {
+	Load() (*FinanceData, error)
+	Save(data *FinanceData) error
+}
+
+func newFinanceStore(kind, path string) (FinanceStore, error) {
+	switch kind {
+	case "json":
+		return NewJSONFileStore(path), nil
+	case "journal":
+		return NewJournalStore(path), nil
+	default:
+		return nil, fmt.Errorf("unknown store %q", kind)
+	}
+}
+
+// JSON file store
+
+// JSONFileStore keeps the whole state in a single JSON document. Writes go
+// to a temporary file that is renamed over the original, so a crash
+// mid-write leaves the previous version intact.
+type JSONFileStore struct {
+	path string
+}
+
+func NewJSONFileStore(path string) *JSONFileStore {
+	return &JSONFileStore{path: path}
+}
+
+func (s *JSONFileStore) Load() (*FinanceData, error) {
+	content, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &FinanceData{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	data := &FinanceData{}
+	if err := json.Unmarshal(content, data); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", s.path, err)
+	}
+	return data, nil
+}
+
+func (s *JSONFileStore) Save(data *FinanceData) error {
+	content, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, content, 0600)
+}
+
+// writeFileAtomic writes content to a temporary file in the same directory,
+// syncs it and renames it over path.
+func writeFileAtomic(path string, content []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	// Persist the rename itself; not every platform supports syncing a directory.
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+// Journal store
+
+const defaultJournalCompactAfter = 100
+
+const historyAppend = "history_append"
+
+// journalEntry holds the top-level sections of FinanceData that changed
+// since the previous entry, keyed by their JSON names. A section that
+// became empty is written as null. History events are only ever added, so
+// new events go under historyAppend instead of repeating the whole history.
+type journalEntry struct {
+	Seq  int             `json:"seq"`
+	CRC  uint32          `json:"crc"`
+	Data json.RawMessage `json:"data"`
+}
+
+// JournalStore appends a checksummed entry with the changed sections of
+// the state on every Save, so adding an expense does not rewrite the
+// incomes. Load replays the entries in order onto an empty state and stops
+// at the first one that is torn or fails its checksum, so a crash
+// mid-append only loses that write. Once the journal holds compactAfter
+// entries it is replaced, by atomic rename, with one entry holding every
+// section.
+type JournalStore struct {
+	path         string
+	seq          int
+	entries      int
+	compactAfter int
+	last         map[string]json.RawMessage
+}
+
+func NewJournalStore(path string) *JournalStore {
+	return &JournalStore{path: path, compactAfter: defaultJournalCompactAfter}
+}
+
+func (s *JournalStore) Load() (*FinanceData, error) {
+	s.seq, s.entries, s.last = 0, 0, nil
+	f, err := os.Open(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &FinanceData{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sections := make(map[string]json.RawMessage)
+	var validSize int64
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if err == io.EOF {
+			// A line without a newline is a torn write.
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		var entry journalEntry
+		var changed map[string]json.RawMessage
+		if json.Unmarshal(line, &entry) != nil || crc32.ChecksumIEEE(entry.Data) != entry.CRC ||
+			json.Unmarshal(entry.Data, &changed) != nil {
+			break
+		}
+		for name, value := range changed {
+			if name == historyAppend {
+				sections["history"] = appendJSONArray(sections["history"], value)
+				continue
+			}
+			if string(value) == "null" {
+				delete(sections, name)
+			} else {
+				sections[name] = value
+			}
+		}
+		s.seq = entry.Seq
+		s.entries++
+		validSize += int64(len(line))
+	}
+
+	// Drop anything after the last intact entry so new entries append cleanly.
+	if info, err := f.Stat(); err == nil && info.Size() > validSize {
+		if err := os.Truncate(s.path, validSize); err != nil {
+			return nil, err
+		}
+	}
+
+	data := &FinanceData{}
+	if s.entries > 0 {
+		raw, err := json.Marshal(sections)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(raw, data); err != nil {
+			return nil, fmt.Errorf("decoding %s: %w", s.path, err)
+		}
+		s.last = sections
+	}
+	return data, nil
+}
+
+func (s *JournalStore) Save(data *FinanceData) error {
+	sections, err := journalSections(data)
+	if err != nil {
+		return err
+	}
+
+	if s.last == nil || (s.compactAfter > 0 && s.entries >= s.compactAfter) {
+		line, err := s.encode(sections)
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(s.path, line, 0600); err != nil {
+			return err
+		}
+		s.entries, s.last = 1, sections
+		return nil
+	}
+
+	changed := make(map[string]json.RawMessage)
+	for name, value := range sections {
+		if !bytes.Equal(s.last[name], value) {
+			changed[name] = value
+		}
+	}
+	for name := range s.last {
+		if _, ok := sections[name]; !ok {
+			changed[name] = json.RawMessage("null")
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	if events, ok := newJSONArrayItems(s.last["history"], changed["history"]); ok {
+		delete(changed, "history")
+		changed[historyAppend] = events
+	}
+	line, err := s.encode(changed)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(line); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	s.entries++
+	s.last = sections
+	return nil
+}
+
+// journalSections splits the JSON form of data into its top-level fields.
+func journalSections(data *FinanceData) (map[string]json.RawMessage, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var sections map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &sections); err != nil {
+		return nil, err
+	}
+	return sections, nil
+}
+
+// newJSONArrayItems returns the items that updated adds at the end of the
+// JSON array old, as an array of their own, if updated starts with old.
+func newJSONArrayItems(old, updated json.RawMessage) (json.RawMessage, bool) {
+	if len(old) < 2 || len(updated) <= len(old) || old[0] != '[' {
+		return nil, false
+	}
+	head := old[:len(old)-1]
+	if !bytes.HasPrefix(updated, head) || updated[len(head)] != ',' {
+		return nil, false
+	}
+	return append(json.RawMessage("["), updated[len(head)+1:]...), true
+}
+
+// appendJSONArray joins two JSON arrays.
+func appendJSONArray(array, items json.RawMessage) json.RawMessage {
+	if len(array) < 2 || string(array) == "null" || string(array) == "[]" {
+		return items
+	}
+	joined := append(json.RawMessage(nil), array[:len(array)-1]...)
+	return append(append(joined, ','), items[1:]...)
+}
+
+func (s *JournalStore) encode(sections map[string]json.RawMessage) ([]byte, error) {
+	raw, err := json.Marshal(sections)
+	if err != nil {
+		return nil, err
+	}
+	s.seq++
+	line, err := json.Marshal(journalEntry{Seq: s.seq, CRC: crc32.ChecksumIEEE(raw), Data: raw})
+	if err != nil {
+		return nil, err
+	}
+	return append(line, '\n'), nil
+}
+
+// memoryStore discards everything; it backs managers created without a store.
+type memoryStore struct{}
+
+func (memoryStore) Load() (*FinanceData, error) { return &FinanceData{}, nil }
+func (memoryStore) Save(*FinanceData) error     { return nil }
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "finance.json")
+
+	fm, err := NewFinanceManagerWithStore(NewJSONFileStore(path))
+	if err != nil {
+		t.Fatalf("Failed to open store: %v", err)
+	}
+	fm.AddIncome(time.Now(), "Salary", usd("5000.00"))
+	fm.AddExpense(time.Now(), "Rent", usd("1000.00"))
+	fm.AddInvestment(time.Now(), "Stocks", usd("2000.00"))
+
+	reopened, err := NewFinanceManagerWithStore(NewJSONFileStore(path))
+	if err != nil {
+		t.Fatalf("Failed to reopen store: %v", err)
+	}
+	if len(reopened.incomes) != 1 || len(reopened.expenses) != 1 || len(reopened.investments) != 1 {
+		t.Errorf("Expected one entry of each kind, got %d/%d/%d",
+			len(reopened.incomes), len(reopened.expenses), len(reopened.investments))
+	}
+	if reopened.incomes[0].Source != "Salary" {
+		t.Errorf("Expected income source Salary, got %q", reopened.incomes[0].Source)
+	}
+}
+
+func TestJournalStoreReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "finance.journal")
+
+	fm, err := NewFinanceManagerWithStore(NewJournalStore(path))
+	if err != nil {
+		t.Fatalf("Failed to open journal: %v", err)
+	}
+	fm.AddIncome(time.Now(), "Salary", usd("5000.00"))
+	fm.AddExpense(time.Now(), "Rent", usd("1000.00"))
+
+	// Simulate a crash in the middle of writing the next entry.
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"seq":3,"crc":1,"data":{"incomes":[`)
+	f.Close()
+
+	reopened, err := NewFinanceManagerWithStore(NewJournalStore(path))
+	if err != nil {
+		t.Fatalf("Failed to replay journal: %v", err)
+	}
+	if len(reopened.incomes) != 1 || len(reopened.expenses) != 1 {
+		t.Fatalf("Expected state before the torn write, got %d incomes and %d expenses",
+			len(reopened.incomes), len(reopened.expenses))
+	}
+
+	// The journal must keep accepting writes after the torn tail is dropped.
+	if err := reopened.AddExpense(time.Now(), "Food", usd("50.00")); err != nil {
+		t.Fatalf("Failed to append after replay: %v", err)
+	}
+	again, err := NewFinanceManagerWithStore(NewJournalStore(path))
+	if err != nil {
+		t.Fatalf("Failed to replay journal: %v", err)
+	}
+	if len(again.expenses) != 2 {
+		t.Errorf("Expected 2 expenses after replay, got %d", len(again.expenses))
+	}
+}
+
+func TestJournalStoreCompaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "finance.journal")
+	store := NewJournalStore(path)
+	store.compactAfter = 3
+
+	fm, err := NewFinanceManagerWithStore(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		fm.AddIncome(time.Now(), "Salary", usd("100.00"))
+	}
+
+	reopened := NewJournalStore(path)
+	data, err := reopened.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data.Incomes) != 5 {
+		t.Errorf("Expected 5 incomes after compaction, got %d", len(data.Incomes))
+	}
+	if reopened.entries >= 5 {
+		t.Errorf("Expected journal to be compacted, found %d entries", reopened.entries)
+	}
+}
+
+func TestJournalStoreWritesChangedSections(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "finance.journal")
+	fm, err := NewFinanceManagerWithStore(NewJournalStore(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fm.AddIncome(time.Now(), "Salary", usd("5000.00"))
+	fm.AddExpense(time.Now(), "Rent", usd("1000.00"))
+	income := fm.incomes[0]
+	if err := fm.DeleteIncome(income.ID); err != nil {
+		t.Fatalf("Failed to delete income: %v", err)
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected one entry per change, got %d", len(lines))
+	}
+	if strings.Contains(lines[1], `"incomes"`) || !strings.Contains(lines[1], `"expenses"`) {
+		t.Errorf("Expected the second entry to hold only the expenses, got %s", lines[1])
+	}
+
+	reopened, err := NewFinanceManagerWithStore(NewJournalStore(path))
+	if err != nil {
+		t.Fatalf("Failed to replay journal: %v", err)
+	}
+	if len(reopened.incomes) != 0 || len(reopened.expenses) != 1 {
+		t.Errorf("Expected the deletion to be replayed, got %d incomes and %d expenses",
+			len(reopened.incomes), len(reopened.expenses))
+	}
+}
+
+func TestJournalStoreAppendsHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "finance.journal")
+	fm, err := NewFinanceManagerWithStore(NewJournalStore(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	fm.AddIncome(time.Now(), "Salary", usd("5000.00"))
+	fm.AddExpense(time.Now(), "Rent", usd("1000.00"))
+	fm.AddExpense(time.Now(), "Food", usd("50.00"))
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if last := lines[len(lines)-1]; !strings.Contains(last, historyAppend) || strings.Contains(last, "Salary") {
+		t.Errorf("Expected the last entry to add only the newest event, got %s", last)
+	}
+
+	reopened, err := NewFinanceManagerWithStore(NewJournalStore(path))
+	if err != nil {
+		t.Fatalf("Failed to replay journal: %v", err)
+	}
+	history := reopened.History()
+	if len(history) != 3 || history[0].Changes[0].ID != fm.incomes[0].ID || history[2].Seq != 3 {
+		t.Errorf("Expected the three events in order, got %+v", history)
+	}
+	reopened.AddExpense(time.Now(), "Fuel", usd("40.00"))
+	if again, _ := NewJournalStore(path).Load(); len(again.History) != 4 {
+		t.Errorf("Expected 4 events after appending to a replayed journal, got %d", len(again.History))
+	}
+}